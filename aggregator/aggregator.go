@@ -3,6 +3,7 @@ package aggregator
 import (
 	"fmt"
 	"github.com/boreq/flightradar-backend/logging"
+	"github.com/boreq/flightradar-backend/movements"
 	"github.com/boreq/flightradar-backend/storage"
 	"os"
 	"time"
@@ -11,11 +12,22 @@ import (
 var log = logging.GetLogger("aggregator")
 
 func New(s storage.Storage) Aggregator {
+	return NewWithDetector(s, nil)
+}
+
+// NewWithDetector creates an aggregator which passes the stored data points
+// to the detector and stores the detected movements. The detector can be nil
+// in which case the movements aren't detected. The movements of the tracks
+// which aren't completed when the aggregator is closed are not recorded.
+func NewWithDetector(s storage.Storage, detector *movements.Detector) Aggregator {
 	rv := &aggregator{
-		storage: s,
-		data:    make(chan storage.Data),
-		recent:  make(map[string]storage.StoredData),
-		stored:  make(map[string]storage.StoredData),
+		storage:  s,
+		detector: detector,
+		data:     make(chan storage.Data),
+		recent:   make(map[string]storage.StoredData),
+		stored:   make(map[string]storage.StoredData),
+		history:  make(map[string]*planeHistory),
+		close:    make(chan chan error),
 	}
 	go rv.run()
 	return rv
@@ -36,11 +48,33 @@ const dataTimeoutThreshold = 15 * time.Second
 // same position don't get saved for the given aircraft twice in the row.
 const storedDataTimeoutThreshold = 5 * time.Minute
 
+// The data points are buffered and stored in batches. The buffer is flushed
+// when it reaches storeBufferSize data points or every storeBufferInterval.
+const storeBufferSize = 100
+const storeBufferInterval = 500 * time.Millisecond
+
+// The data points which couldn't be stored are kept in the buffer and stored
+// together with the next batch. If the buffer exceeds maxBufferSize the
+// oldest data points are dropped.
+const maxBufferSize = 100 * storeBufferSize
+
 type aggregator struct {
-	storage storage.Storage
-	data    chan storage.Data
-	recent  map[string]storage.StoredData
-	stored  map[string]storage.StoredData
+	storage  storage.Storage
+	detector *movements.Detector
+	data     chan storage.Data
+	recent   map[string]storage.StoredData
+	stored   map[string]storage.StoredData
+	history  map[string]*planeHistory
+	buffer   []storage.StoredData
+
+	// failed is the number of data points at the start of the buffer which
+	// couldn't be stored during the last flush.
+	failed int
+
+	// rejections are buffered and stored together with the data points.
+	rejections []storage.Rejection
+
+	close chan chan error
 }
 
 func (a *aggregator) GetChannel() chan<- storage.Data {
@@ -51,16 +85,95 @@ func (a *aggregator) run() {
 	cleanupTicker := time.NewTicker(60 * time.Second)
 	defer cleanupTicker.Stop()
 
+	flushTicker := time.NewTicker(storeBufferInterval)
+	defer flushTicker.Stop()
+
 	for {
 		select {
 		case d := <-a.data:
 			a.process(d)
+			if len(a.buffer)-a.failed >= storeBufferSize {
+				a.logFlushError(a.flush())
+			}
+		case <-flushTicker.C:
+			a.logFlushError(a.flush())
 		case <-cleanupTicker.C:
 			a.cleanup()
+		case errC := <-a.close:
+			// The tracks which are still open are dropped instead of
+			// being classified. They would be continued after a restart
+			// and their movements would be counted twice.
+			errC <- a.flush()
+			return
 		}
 	}
 }
 
+// flush stores the buffered rejections and data points.
+func (a *aggregator) flush() error {
+	rejectionsErr := a.flushRejections()
+	if err := a.flushData(); err != nil {
+		return err
+	}
+	return rejectionsErr
+}
+
+// flushRejections stores the buffered rejections. The buffer is cleared even
+// if the rejections couldn't be stored.
+func (a *aggregator) flushRejections() error {
+	if len(a.rejections) == 0 {
+		return nil
+	}
+	err := a.storage.StoreRejections(a.rejections)
+	a.rejections = nil
+	return err
+}
+
+// flushData stores the buffered data points. If the data points couldn't be
+// stored they are kept in the buffer and the oldest ones are dropped so that
+// a persistent error doesn't cause it to grow indefinitely. The movements are
+// detected only in the data points which were stored.
+func (a *aggregator) flushData() error {
+	if len(a.buffer) == 0 {
+		return nil
+	}
+	if err := a.storage.StoreBatch(a.buffer); err != nil {
+		if dropped := len(a.buffer) - maxBufferSize; dropped > 0 {
+			log.Printf("Dropped %d data points which couldn't be stored", dropped)
+			a.buffer = append([]storage.StoredData(nil), a.buffer[dropped:]...)
+		}
+		a.failed = len(a.buffer)
+		return err
+	}
+	buffer := a.buffer
+	a.buffer = nil
+	a.failed = 0
+	if a.detector == nil {
+		return nil
+	}
+
+	var detected []storage.Movement
+	for _, d := range buffer {
+		detected = append(detected, a.detector.Add(d)...)
+	}
+	if len(detected) == 0 {
+		return nil
+	}
+	return a.storage.StoreMovements(detected)
+}
+
+func (a *aggregator) logFlushError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+	}
+}
+
+func (a *aggregator) Close() error {
+	errC := make(chan error)
+	a.close <- errC
+	return <-errC
+}
+
 func (a *aggregator) process(d storage.Data) {
 	// I think it is impossible that this data is missing when using ADS-B,
 	// but check just to be sure.
@@ -68,7 +181,10 @@ func (a *aggregator) process(d storage.Data) {
 		return
 	}
 
+	// Implausible values are removed before the data is used in any way.
 	storedData := storage.StoredData{Data: d, Time: time.Now()}
+	a.rejections = append(a.rejections, a.checkPlausibility(&storedData)...)
+	d = storedData.Data
 	a.recent[*d.Icao] = storedData
 
 	// If the position is set record the data permanently every couple of
@@ -79,9 +195,7 @@ func (a *aggregator) process(d storage.Data) {
 		if !ok || time.Since(lastStoredData.Time) > getStoreEvery(d.Altitude) {
 			if !ok || (*storedData.Data.Latitude != *lastStoredData.Data.Latitude &&
 				*storedData.Data.Longitude != *lastStoredData.Data.Longitude) {
-				if err := a.storage.Store(storedData); err != nil {
-					fmt.Fprintf(os.Stderr, "%s\n", err)
-				}
+				a.buffer = append(a.buffer, storedData)
 				a.stored[*d.Icao] = storedData
 			}
 		}
@@ -101,6 +215,12 @@ func (a *aggregator) cleanup() {
 		}
 	}
 
+	for key, value := range a.history {
+		if time.Since(value.lastSeen()) > storedDataTimeoutThreshold {
+			delete(a.history, key)
+		}
+	}
+
 }
 
 // getStoreEvery calculates how often the data should be stored. The data
@@ -144,3 +264,39 @@ func (a *aggregator) RetrieveTimerange(from time.Time, to time.Time) ([]storage.
 func (a *aggregator) RetrieveAll() ([]storage.StoredData, error) {
 	return a.storage.RetrieveAll()
 }
+
+func (a *aggregator) RetrievePoint(icao string, t time.Time) (*storage.StoredData, error) {
+	return a.storage.RetrievePoint(icao, t)
+}
+
+func (a *aggregator) Iterate(from time.Time, to time.Time, fn func(storage.StoredData) error) error {
+	return a.storage.Iterate(from, to, fn)
+}
+
+func (a *aggregator) RetrieveArea(bbox storage.BoundingBox, from time.Time, to time.Time) ([]storage.StoredData, error) {
+	return a.storage.RetrieveArea(bbox, from, to)
+}
+
+func (a *aggregator) RetrieveByFlightNumber(flightNumber string, from time.Time, to time.Time) ([]storage.StoredData, error) {
+	return a.storage.RetrieveByFlightNumber(flightNumber, from, to)
+}
+
+func (a *aggregator) RetrieveBySquawk(transponderCode int, from time.Time, to time.Time) ([]storage.StoredData, error) {
+	return a.storage.RetrieveBySquawk(transponderCode, from, to)
+}
+
+func (a *aggregator) RetrieveStats(from time.Time, to time.Time) ([]storage.StatsForPeriod, error) {
+	return a.storage.RetrieveStats(from, to)
+}
+
+func (a *aggregator) RetrieveDailyStats(from time.Time, to time.Time) ([]storage.StatsForPeriod, error) {
+	return a.storage.RetrieveDailyStats(from, to)
+}
+
+func (a *aggregator) RetrieveTop(category storage.TopCategory, from time.Time, to time.Time, limit int) ([]storage.TopEntry, error) {
+	return a.storage.RetrieveTop(category, from, to, limit)
+}
+
+func (a *aggregator) RetrieveCoverage() (*storage.Coverage, error) {
+	return a.storage.RetrieveCoverage()
+}
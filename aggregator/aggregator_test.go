@@ -1,34 +1,25 @@
 package aggregator
 
 import (
+	"errors"
+	"github.com/boreq/flightradar-backend/metadata"
+	"github.com/boreq/flightradar-backend/movements"
 	"github.com/boreq/flightradar-backend/storage"
+	"github.com/boreq/flightradar-backend/storage/memory"
 	"testing"
 	"time"
 )
 
-type st struct {
-	counter int
-}
-
-func (s *st) Store(data storage.StoredData) error {
-	s.counter++
-	return nil
-}
-
-func (s *st) Retrieve(icao string) ([]storage.StoredData, error) {
-	return nil, nil
-}
-
-func (s *st) RetrieveTimerange(from time.Time, to time.Time) ([]storage.StoredData, error) {
-	return nil, nil
-}
-
-func (s *st) RetrieveAll() ([]storage.StoredData, error) {
-	return nil, nil
+func countStored(t *testing.T, s storage.Storage) int {
+	data, err := s.RetrieveAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(data)
 }
 
 func TestEnsureDataSavedOnceWhenTooOften(t *testing.T) {
-	s := &st{}
+	s := memory.New(0, storage.Position{})
 
 	aggregator := New(s)
 
@@ -43,24 +34,24 @@ func TestEnsureDataSavedOnceWhenTooOften(t *testing.T) {
 		Longitude: new(float64),
 	}
 	*data1.Icao = "aaaaaaa"
-	*data1.Latitude = 1
-	*data1.Longitude = 1
+	*data1.Latitude = 50.01
+	*data1.Longitude = 19.01
 
 	*data2.Icao = "aaaaaaa"
-	*data2.Latitude = 2
-	*data2.Longitude = 2
+	*data2.Latitude = 50.02
+	*data2.Longitude = 19.02
 
 	aggregator.GetChannel() <- data1
 	aggregator.GetChannel() <- data2
 
 	<-time.After(1 * time.Second)
-	if s.counter != 1 {
-		t.Fatalf("Counter was %d", s.counter)
+	if counter := countStored(t, s); counter != 1 {
+		t.Fatalf("Counter was %d", counter)
 	}
 }
 
 func TestEnsureDataSavedOnceWhenIdentical(t *testing.T) {
-	s := &st{}
+	s := memory.New(0, storage.Position{})
 
 	aggregator := New(s)
 
@@ -70,21 +61,21 @@ func TestEnsureDataSavedOnceWhenIdentical(t *testing.T) {
 		Longitude: new(float64),
 	}
 	*data.Icao = "aaaaaaa"
-	*data.Latitude = 1
-	*data.Longitude = 1
+	*data.Latitude = 50.01
+	*data.Longitude = 19.01
 
 	aggregator.GetChannel() <- data
 	<-time.After(storeEveryTimeMin + 1*time.Second)
 	aggregator.GetChannel() <- data
 
 	<-time.After(1 * time.Second)
-	if s.counter != 1 {
-		t.Fatalf("Counter was %d", s.counter)
+	if counter := countStored(t, s); counter != 1 {
+		t.Fatalf("Counter was %d", counter)
 	}
 }
 
 func TestEnsureDataSavedTwiceWhenDifferent(t *testing.T) {
-	s := &st{}
+	s := memory.New(0, storage.Position{})
 
 	aggregator := New(s)
 
@@ -99,20 +90,82 @@ func TestEnsureDataSavedTwiceWhenDifferent(t *testing.T) {
 		Longitude: new(float64),
 	}
 	*data1.Icao = "aaaaaaa"
-	*data1.Latitude = 1
-	*data1.Longitude = 1
+	*data1.Latitude = 50.01
+	*data1.Longitude = 19.01
 
 	*data2.Icao = "aaaaaaa"
-	*data2.Latitude = 2
-	*data2.Longitude = 2
+	*data2.Latitude = 50.02
+	*data2.Longitude = 19.02
 
 	aggregator.GetChannel() <- data1
 	<-time.After(storeEveryTimeMin + 1*time.Second)
 	aggregator.GetChannel() <- data2
 
 	<-time.After(1 * time.Second)
-	if s.counter != 2 {
-		t.Fatalf("Counter was %d", s.counter)
+	if counter := countStored(t, s); counter != 2 {
+		t.Fatalf("Counter was %d", counter)
+	}
+}
+
+func TestCloseStoresBufferedData(t *testing.T) {
+	s := memory.New(0, storage.Position{})
+
+	aggregator := New(s)
+
+	data := storage.Data{
+		Icao:      new(string),
+		Latitude:  new(float64),
+		Longitude: new(float64),
+	}
+	*data.Icao = "aaaaaaa"
+	*data.Latitude = 50.01
+	*data.Longitude = 19.01
+
+	aggregator.GetChannel() <- data
+	if err := aggregator.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if counter := countStored(t, s); counter != 1 {
+		t.Fatalf("Counter was %d", counter)
+	}
+}
+
+func TestCloseDropsUnfinishedTracks(t *testing.T) {
+	s := memory.New(0, storage.Position{})
+
+	airports := []metadata.Airport{{Icao: "EPKK", Latitude: 50.077702, Longitude: 19.7848}}
+	aggregator := NewWithDetector(s, movements.NewDetector(airports))
+
+	data := storage.Data{
+		Icao:      new(string),
+		Latitude:  new(float64),
+		Longitude: new(float64),
+		Altitude:  new(int),
+	}
+	*data.Icao = "aaaaaaa"
+	*data.Latitude = 50.08
+	*data.Longitude = 19.79
+	*data.Altitude = 10000
+
+	aggregator.GetChannel() <- data
+	if err := aggregator.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	periods, err := s.RetrieveStats(now.Add(-time.Hour), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overflights := 0
+	for _, period := range periods {
+		if airport, ok := period.Stats.Airports["EPKK"]; ok {
+			overflights += airport.Overflights
+		}
+	}
+	if overflights != 0 {
+		t.Fatalf("Overflights was %d", overflights)
 	}
 }
 
@@ -139,3 +192,50 @@ func TestStoreEveryUpperBoundary(t *testing.T) {
 		t.Fatalf("Invalid d: %s != %s", d, storeEveryTimeMax)
 	}
 }
+
+// failingStorage fails to store the batches while fail is set.
+type failingStorage struct {
+	storage.Storage
+	fail bool
+}
+
+func (s *failingStorage) StoreBatch(data []storage.StoredData) error {
+	if s.fail {
+		return errors.New("storage failed")
+	}
+	return s.Storage.StoreBatch(data)
+}
+
+func TestFlushRetriesFailedData(t *testing.T) {
+	s := &failingStorage{Storage: memory.New(0, storage.Position{}), fail: true}
+	a := &aggregator{storage: s}
+
+	icao := "aaaaaa"
+	for i := 0; i < maxBufferSize+10; i++ {
+		a.buffer = append(a.buffer, storage.StoredData{
+			Time: time.Unix(int64(i), 0),
+			Data: storage.Data{Icao: &icao},
+		})
+	}
+
+	if err := a.flushData(); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if len(a.buffer) != maxBufferSize || a.failed != maxBufferSize {
+		t.Fatalf("Wrong buffer length %d or failed %d", len(a.buffer), a.failed)
+	}
+	if !a.buffer[0].Time.Equal(time.Unix(10, 0)) {
+		t.Fatalf("Wrong oldest data point %s", a.buffer[0].Time)
+	}
+
+	s.fail = false
+	if err := a.flushData(); err != nil {
+		t.Fatal(err)
+	}
+	if len(a.buffer) != 0 || a.failed != 0 {
+		t.Fatalf("Wrong buffer length %d or failed %d", len(a.buffer), a.failed)
+	}
+	if counter := countStored(t, s); counter != maxBufferSize {
+		t.Fatalf("Counter was %d", counter)
+	}
+}
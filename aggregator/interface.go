@@ -14,5 +14,11 @@ type Aggregator interface {
 	// the latest data for that aircraft.
 	Newest() map[string]storage.Data
 
+	// Close stores the buffered data points and stops the aggregator. The
+	// data points are buffered for a short time before being stored so
+	// this has to be called before the program exits. The aggregator can't
+	// be used after it is closed.
+	Close() error
+
 	storage.ReadStorage
 }
@@ -0,0 +1,152 @@
+package aggregator
+
+import (
+	"github.com/boreq/flightradar-backend/config"
+	"github.com/boreq/flightradar-backend/geo"
+	"github.com/boreq/flightradar-backend/storage"
+	"math"
+	"time"
+)
+
+// maxSpeed is the highest speed in kilometers per hour which a plane can
+// plausibly reach.
+const maxSpeed = 2000
+
+// positionTolerance is the distance in kilometers which is always allowed
+// between two consecutive positions to account for the imprecision of the
+// decoded positions.
+const positionTolerance = 2
+
+// maxVerticalRate is the highest rate of climb or descent in feet per minute
+// which a plane can plausibly reach.
+const maxVerticalRate = 10000
+
+// altitudeTolerance is the difference in feet which is always allowed
+// between two consecutive altitudes.
+const altitudeTolerance = 500
+
+// maxRejectedInRow is the number of consecutive rejections after which the
+// new values are accepted. It prevents a single implausible value which
+// wasn't rejected eg. because it was the first value received from the plane
+// from causing all further values to be rejected.
+const maxRejectedInRow = 5
+
+// planeHistory holds the last plausible values received from a plane.
+type planeHistory struct {
+	position          *storage.StoredData
+	rejectedPositions int
+
+	altitude          *storage.StoredData
+	rejectedAltitudes int
+}
+
+// lastSeen returns the time at which the last plausible value was received.
+func (h *planeHistory) lastSeen() time.Time {
+	var rv time.Time
+	if h.position != nil {
+		rv = h.position.Time
+	}
+	if h.altitude != nil && h.altitude.Time.After(rv) {
+		rv = h.altitude.Time
+	}
+	return rv
+}
+
+// checkPlausibility compares the data point with the previous plausible data
+// received from the same plane. Implausible positions are removed from the
+// data point. Implausible altitudes are removed as well and the quality of
+// the data point is flagged. The reasons for which the data was rejected are
+// returned.
+func (a *aggregator) checkPlausibility(d *storage.StoredData) []storage.Rejection {
+	history, ok := a.history[*d.Data.Icao]
+	if !ok {
+		history = &planeHistory{}
+		a.history[*d.Data.Icao] = history
+	}
+
+	var rv []storage.Rejection
+	reject := func(reason storage.RejectionReason) {
+		rv = append(rv, storage.Rejection{Icao: *d.Data.Icao, Reason: reason, Time: d.Time})
+	}
+
+	if d.Data.Latitude != nil && d.Data.Longitude != nil {
+		if reason, ok := checkPosition(history, *d); ok {
+			history.position = copyStoredData(d)
+		} else {
+			reject(reason)
+			d.Data.Latitude = nil
+			d.Data.Longitude = nil
+		}
+	}
+
+	if d.Data.Altitude != nil {
+		if checkAltitude(history, *d) {
+			history.altitude = copyStoredData(d)
+		} else {
+			reject(storage.RejectionAltitude)
+			d.Data.Altitude = nil
+			quality := storage.QualityAltitudeRejected
+			if d.Data.Quality != nil {
+				quality |= *d.Data.Quality
+			}
+			d.Data.Quality = &quality
+		}
+	}
+
+	return rv
+}
+
+// checkPosition returns false and the reason if the position of the data
+// point is implausible.
+func checkPosition(history *planeHistory, d storage.StoredData) (storage.RejectionReason, bool) {
+	if receiverRange := config.Config.ReceiverRange; receiverRange > 0 {
+		distance := geo.Distance(config.Config.StationLongitude, config.Config.StationLatitude, *d.Data.Longitude, *d.Data.Latitude)
+		if distance > receiverRange {
+			return storage.RejectionRange, false
+		}
+	}
+
+	if !isRecent(history.position, d) || history.rejectedPositions >= maxRejectedInRow {
+		history.rejectedPositions = 0
+		return "", true
+	}
+
+	previous := history.position.Data
+	distance := geo.Distance(*previous.Longitude, *previous.Latitude, *d.Data.Longitude, *d.Data.Latitude)
+	elapsed := d.Time.Sub(history.position.Time).Hours()
+	if distance > maxSpeed*elapsed+positionTolerance {
+		history.rejectedPositions++
+		return storage.RejectionSpeed, false
+	}
+	history.rejectedPositions = 0
+	return "", true
+}
+
+// checkAltitude returns false if the altitude of the data point is
+// implausible.
+func checkAltitude(history *planeHistory, d storage.StoredData) bool {
+	if !isRecent(history.altitude, d) || history.rejectedAltitudes >= maxRejectedInRow {
+		history.rejectedAltitudes = 0
+		return true
+	}
+
+	difference := math.Abs(float64(*d.Data.Altitude - *history.altitude.Data.Altitude))
+	elapsed := d.Time.Sub(history.altitude.Time).Minutes()
+	if difference > maxVerticalRate*elapsed+altitudeTolerance {
+		history.rejectedAltitudes++
+		return false
+	}
+	history.rejectedAltitudes = 0
+	return true
+}
+
+// isRecent returns true if the previous data point exists and can be used as
+// a reference for the current one.
+func isRecent(previous *storage.StoredData, d storage.StoredData) bool {
+	return previous != nil && d.Time.Sub(previous.Time) <= storedDataTimeoutThreshold
+}
+
+func copyStoredData(d *storage.StoredData) *storage.StoredData {
+	rv := *d
+	return &rv
+}
@@ -0,0 +1,176 @@
+package aggregator
+
+import (
+	"github.com/boreq/flightradar-backend/config"
+	"github.com/boreq/flightradar-backend/storage"
+	"github.com/boreq/flightradar-backend/storage/memory"
+	"testing"
+	"time"
+)
+
+var plausibilityStart = time.Date(2018, 2, 8, 12, 0, 0, 0, time.UTC)
+
+func createPlausibilityData(seconds int, latitude, longitude float64, altitude int) storage.StoredData {
+	d := storage.StoredData{
+		Data: storage.Data{
+			Icao:      new(string),
+			Latitude:  new(float64),
+			Longitude: new(float64),
+			Altitude:  new(int),
+		},
+		Time: plausibilityStart.Add(time.Duration(seconds) * time.Second),
+	}
+	*d.Data.Icao = "aaaaaa"
+	*d.Data.Latitude = latitude
+	*d.Data.Longitude = longitude
+	*d.Data.Altitude = altitude
+	return d
+}
+
+func TestCheckPlausibility(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Data     []storage.StoredData
+		Expected []storage.RejectionReason
+	}{
+		{
+			Name: "plausible",
+			Data: []storage.StoredData{
+				createPlausibilityData(0, 50.00, 19.90, 10000),
+				createPlausibilityData(10, 50.01, 19.91, 10300),
+				createPlausibilityData(70, 50.10, 19.95, 9000),
+			},
+		},
+		{
+			Name: "beyond receiver range",
+			Data: []storage.StoredData{
+				createPlausibilityData(0, 40.00, 10.00, 10000),
+			},
+			Expected: []storage.RejectionReason{storage.RejectionRange},
+		},
+		{
+			Name: "impossible speed",
+			Data: []storage.StoredData{
+				createPlausibilityData(0, 50.00, 19.90, 10000),
+				createPlausibilityData(10, 51.00, 19.90, 10000),
+				createPlausibilityData(20, 50.01, 19.90, 10000),
+			},
+			Expected: []storage.RejectionReason{storage.RejectionSpeed},
+		},
+		{
+			Name: "altitude jump",
+			Data: []storage.StoredData{
+				createPlausibilityData(0, 50.00, 19.90, 10000),
+				createPlausibilityData(10, 50.01, 19.90, 30000),
+				createPlausibilityData(20, 50.02, 19.90, 10100),
+			},
+			Expected: []storage.RejectionReason{storage.RejectionAltitude},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			a := &aggregator{history: make(map[string]*planeHistory)}
+			var rejections []storage.Rejection
+			for i := range testCase.Data {
+				rejections = append(rejections, a.checkPlausibility(&testCase.Data[i])...)
+			}
+			if len(rejections) != len(testCase.Expected) {
+				t.Fatalf("Wrong rejections %+v", rejections)
+			}
+			for i, rejection := range rejections {
+				if rejection.Reason != testCase.Expected[i] {
+					t.Errorf("Wrong rejection %+v", rejection)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckPlausibilityRemovesValues(t *testing.T) {
+	a := &aggregator{history: make(map[string]*planeHistory)}
+
+	first := createPlausibilityData(0, 50.00, 19.90, 10000)
+	a.checkPlausibility(&first)
+
+	second := createPlausibilityData(10, 52.00, 19.90, 30000)
+	a.checkPlausibility(&second)
+	if second.Data.Latitude != nil || second.Data.Longitude != nil {
+		t.Error("Implausible position wasn't removed")
+	}
+	if second.Data.Altitude != nil {
+		t.Error("Implausible altitude wasn't removed")
+	}
+	if second.Data.Quality == nil || *second.Data.Quality&storage.QualityAltitudeRejected == 0 {
+		t.Errorf("Quality wasn't flagged")
+	}
+	if first.Data.Quality != nil {
+		t.Errorf("Plausible data was flagged")
+	}
+}
+
+func TestCheckPlausibilityRecovers(t *testing.T) {
+	a := &aggregator{history: make(map[string]*planeHistory)}
+
+	// The first position is implausible but can't be rejected
+	first := createPlausibilityData(0, 52.00, 19.90, 10000)
+	a.checkPlausibility(&first)
+
+	rejected := 0
+	for i := 1; i <= maxRejectedInRow+2; i++ {
+		d := createPlausibilityData(i, 50.00, 19.90, 10000)
+		rejected += len(a.checkPlausibility(&d))
+	}
+	if rejected != maxRejectedInRow {
+		t.Fatalf("Rejected %d", rejected)
+	}
+}
+
+func TestReceiverRangeDisabled(t *testing.T) {
+	receiverRange := config.Config.ReceiverRange
+	config.Config.ReceiverRange = 0
+	defer func() { config.Config.ReceiverRange = receiverRange }()
+
+	a := &aggregator{history: make(map[string]*planeHistory)}
+	d := createPlausibilityData(0, 40.00, 10.00, 10000)
+	if rejections := a.checkPlausibility(&d); len(rejections) != 0 {
+		t.Fatalf("Wrong rejections %+v", rejections)
+	}
+}
+
+func TestCloseStoresRejections(t *testing.T) {
+	s := memory.New(0, storage.Position{})
+
+	aggregator := New(s)
+
+	data := storage.Data{
+		Icao:      new(string),
+		Latitude:  new(float64),
+		Longitude: new(float64),
+	}
+	*data.Icao = "aaaaaaa"
+	*data.Latitude = 1
+	*data.Longitude = 1
+
+	aggregator.GetChannel() <- data
+	if err := aggregator.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if counter := countStored(t, s); counter != 0 {
+		t.Fatalf("Counter was %d", counter)
+	}
+
+	now := time.Now()
+	periods, err := s.RetrieveStats(now.Add(-time.Hour), now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rejections := 0
+	for _, period := range periods {
+		rejections += period.Stats.Rejections[storage.RejectionRange]
+	}
+	if rejections != 1 {
+		t.Fatalf("Rejections was %d", rejections)
+	}
+}
@@ -0,0 +1,197 @@
+// Package backup creates consistent snapshots of the database.
+package backup
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/boreq/flightradar-backend/logging"
+	"github.com/boreq/flightradar-backend/storage"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+var log = logging.GetLogger("backup")
+
+const ContentType = "application/octet-stream"
+const GzipContentType = "application/gzip"
+
+const filenamePrefix = "database-"
+const filenameTimeFormat = "20060102T150405Z"
+const filenameExtension = ".bolt"
+const gzipExtension = ".gz"
+
+// Write writes a snapshot to the writer, optionally compressing it using
+// gzip.
+func Write(w io.Writer, b storage.Backuper, compress bool) error {
+	if !compress {
+		_, err := b.Backup(w)
+		return err
+	}
+	gzipWriter := gzip.NewWriter(w)
+	if _, err := b.Backup(gzipWriter); err != nil {
+		return err
+	}
+	return gzipWriter.Close()
+}
+
+// WriteFile writes a snapshot to the file located at the given path. The
+// snapshot is written to a temporary file first so that the file located at
+// the given path is never incomplete.
+func WriteFile(b storage.Backuper, path string, compress bool) error {
+	return writeFile(path, func(w io.Writer) error {
+		return Write(w, b, compress)
+	})
+}
+
+// writeFile calls the function with a temporary file located in the same
+// directory as the given path and renames it to the given path if the
+// function succeeds.
+func writeFile(path string, fn func(w io.Writer) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".snapshot-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := fn(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Filename returns the name of a snapshot created at the given time. The
+// names of the snapshots sort chronologically.
+func Filename(t time.Time, compress bool) string {
+	name := filenamePrefix + t.UTC().Format(filenameTimeFormat) + filenameExtension
+	if compress {
+		name += gzipExtension
+	}
+	return name
+}
+
+// Snapshot writes a snapshot to the directory and returns its path.
+func Snapshot(b storage.Backuper, dir string, compress bool) (string, error) {
+	path := filepath.Join(dir, Filename(time.Now(), compress))
+	if err := WriteFile(b, path, compress); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Rotate removes the oldest snapshots from the directory so that at most keep
+// snapshots remain. It returns the paths of the removed snapshots.
+func Rotate(dir string, keep int) ([]string, error) {
+	var paths []string
+	for _, pattern := range []string{"*" + filenameExtension, "*" + filenameExtension + gzipExtension} {
+		matches, err := filepath.Glob(filepath.Join(dir, filenamePrefix+pattern))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Slice(paths, func(i, j int) bool { return filepath.Base(paths[i]) < filepath.Base(paths[j]) })
+
+	var removed []string
+	for len(paths) > keep {
+		if err := os.Remove(paths[0]); err != nil {
+			return removed, err
+		}
+		removed = append(removed, paths[0])
+		paths = paths[1:]
+	}
+	return removed, nil
+}
+
+// Options configures the scheduled snapshots.
+type Options struct {
+	// Directory in which the snapshots are saved.
+	Directory string
+
+	// Interval between the snapshots.
+	Interval time.Duration
+
+	// Keep is the number of the newest snapshots which are kept, the older
+	// snapshots are removed. Zero disables the removal.
+	Keep int
+
+	// Compress specifies if the snapshots should be compressed using gzip.
+	Compress bool
+}
+
+// Scheduler periodically saves snapshots.
+type Scheduler struct {
+	backuper storage.Backuper
+	options  Options
+	close    chan chan struct{}
+}
+
+// NewScheduler starts saving snapshots. The first snapshot is saved after
+// the configured interval.
+func NewScheduler(b storage.Backuper, options Options) (*Scheduler, error) {
+	if options.Interval <= 0 {
+		return nil, fmt.Errorf("Invalid snapshot interval %s", options.Interval)
+	}
+	if err := os.MkdirAll(options.Directory, 0755); err != nil {
+		return nil, err
+	}
+	rv := &Scheduler{
+		backuper: b,
+		options:  options,
+		close:    make(chan chan struct{}),
+	}
+	go rv.run()
+	return rv, nil
+}
+
+func (s *Scheduler) run() {
+	ticker := time.NewTicker(s.options.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.snapshot()
+		case done := <-s.close:
+			close(done)
+			return
+		}
+	}
+}
+
+func (s *Scheduler) snapshot() {
+	path, err := Snapshot(s.backuper, s.options.Directory, s.options.Compress)
+	if err != nil {
+		log.Printf("Snapshot failed: %s", err)
+		return
+	}
+	log.Printf("Saved snapshot %s", path)
+
+	if s.options.Keep > 0 {
+		removed, err := Rotate(s.options.Directory, s.options.Keep)
+		if err != nil {
+			log.Printf("Removing old snapshots failed: %s", err)
+		}
+		for _, path := range removed {
+			log.Debugf("Removed snapshot %s", path)
+		}
+	}
+}
+
+// Close stops saving snapshots. A snapshot which is being saved is
+// completed first.
+func (s *Scheduler) Close() {
+	done := make(chan struct{})
+	s.close <- done
+	<-done
+}
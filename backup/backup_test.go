@@ -0,0 +1,98 @@
+package backup
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type fakeBackuper struct {
+	content []byte
+}
+
+func (f fakeBackuper) Backup(w io.Writer) (int64, error) {
+	n, err := w.Write(f.content)
+	return int64(n), err
+}
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	content := []byte("database")
+
+	for _, compress := range []bool{false, true} {
+		path, err := Snapshot(fakeBackuper{content}, dir, compress)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer file.Close()
+
+		var r io.Reader = file
+		if compress {
+			if r, err = gzip.NewReader(file); err != nil {
+				t.Fatal(err)
+			}
+		}
+		received, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(received, content) {
+			t.Errorf("Wrong content %q", received)
+		}
+	}
+
+	// Temporary files must be removed.
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Errorf("Wrong number of files %d", len(files))
+	}
+}
+
+func TestRotate(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	var names []string
+	for i := 0; i < 5; i++ {
+		name := Filename(start.Add(time.Duration(i)*time.Hour), i%2 == 0)
+		names = append(names, name)
+		if err := ioutil.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "other.bolt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	removed, err := Rotate(dir, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removed) != 3 {
+		t.Fatalf("Wrong number of removed snapshots %d", len(removed))
+	}
+	for i, path := range removed {
+		if filepath.Base(path) != names[i] {
+			t.Errorf("Wrong removed snapshot %s", path)
+		}
+	}
+
+	for _, name := range append(names[3:], "other.bolt") {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("File %s should exist: %s", name, err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package backup
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Download downloads a snapshot from the backup endpoint of a running program
+// and writes it to the writer. The snapshot is compressed using gzip if
+// compress is true. An error is returned if the snapshot wasn't received in
+// its entirety.
+func Download(w io.Writer, address, token string, compress bool) error {
+	url := fmt.Sprintf("http://%s/admin/backup?gzip=%t", address, compress)
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Server returned %s", resp.Status)
+	}
+
+	// The server aborts the connection if the snapshot can't be created
+	// so an incomplete response results in an error here.
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return fmt.Errorf("Downloading the snapshot failed after %d bytes: %s", n, err)
+	}
+	if resp.ContentLength >= 0 && n != resp.ContentLength {
+		return fmt.Errorf("Received %d bytes instead of %d", n, resp.ContentLength)
+	}
+	return nil
+}
+
+// DownloadFile downloads a snapshot to the file located at the given path.
+// The snapshot is written to a temporary file first so that an existing file
+// is replaced only once the snapshot was received in its entirety.
+func DownloadFile(path, address, token string, compress bool) error {
+	return writeFile(path, func(w io.Writer) error {
+		return Download(w, address, token, compress)
+	})
+}
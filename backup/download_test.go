@@ -0,0 +1,94 @@
+package backup
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func serveBackup(t *testing.T, handler http.HandlerFunc) string {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func TestDownloadFile(t *testing.T) {
+	address := serveBackup(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("database"))
+	})
+
+	path := filepath.Join(t.TempDir(), "backup.bolt")
+	if err := DownloadFile(path, address, "secret", false); err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "database" {
+		t.Errorf("Wrong content %q", content)
+	}
+
+	if err := DownloadFile(path, address, "wrong", false); err == nil {
+		t.Error("Expected an error")
+	}
+}
+
+func TestDownloadFileIncomplete(t *testing.T) {
+	testCases := []struct {
+		Name    string
+		Handler http.HandlerFunc
+	}{
+		{
+			Name: "aborted",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("data"))
+				w.(http.Flusher).Flush()
+				panic(http.ErrAbortHandler)
+			},
+		},
+		{
+			Name: "content length",
+			Handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Length", "8")
+				w.Write([]byte("data"))
+			},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			address := serveBackup(t, testCase.Handler)
+
+			path := filepath.Join(t.TempDir(), "backup.bolt")
+			if err := ioutil.WriteFile(path, []byte("previous"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := DownloadFile(path, address, "secret", false); err == nil {
+				t.Fatal("Expected an error")
+			}
+
+			content, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(content) != "previous" {
+				t.Errorf("Previous backup was overwritten with %q", content)
+			}
+			files, err := filepath.Glob(filepath.Join(filepath.Dir(path), "*"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 1 {
+				t.Errorf("Temporary file wasn't removed %v", files)
+			}
+		})
+	}
+}
@@ -7,12 +7,28 @@ import (
 )
 
 type ConfigStruct struct {
-	Debug            bool
-	ServeAddress     string
-	Dump1090Address  string
-	DatabaseFile     string
-	StationLatitude  float64
-	StationLongitude float64
+	Debug                bool
+	ServeAddress         string
+	Dump1090Address      string
+	StorageBackend       string
+	DatabaseFile         string
+	MemoryStorageMaxSize int
+	StationLatitude      float64
+	StationLongitude     float64
+	StatsHistoryDays     int
+	Timezone             string
+	AdminToken           string
+	SnapshotDirectory    string
+	SnapshotInterval     int
+	SnapshotKeep         int
+	SnapshotGzip         bool
+	AircraftDatabaseFile string
+	AirlinesFile         string
+	RoutesFile           string
+	AirportsFile         string
+	RunwaysFile          string
+	AirportsRadius       float64
+	ReceiverRange        float64
 }
 
 // Config points to the current config struct used by the other parts of the
@@ -22,12 +38,28 @@ var Config *ConfigStruct = Default()
 // Default returns the default config.
 func Default() *ConfigStruct {
 	conf := &ConfigStruct{
-		Debug:            false,
-		ServeAddress:     "127.0.0.1:8118",
-		Dump1090Address:  "127.0.0.1:8080",
-		DatabaseFile:     "/tmp/database.bolt",
-		StationLongitude: 19.97605,
-		StationLatitude:  50.08179,
+		Debug:                false,
+		ServeAddress:         "127.0.0.1:8118",
+		Dump1090Address:      "127.0.0.1:8080",
+		StorageBackend:       "bolt",
+		DatabaseFile:         "/tmp/database.bolt",
+		MemoryStorageMaxSize: 100000,
+		StationLongitude:     19.97605,
+		StationLatitude:      50.08179,
+		StatsHistoryDays:     365,
+		Timezone:             "UTC",
+		AdminToken:           "",
+		SnapshotDirectory:    "",
+		SnapshotInterval:     24,
+		SnapshotKeep:         7,
+		SnapshotGzip:         true,
+		AircraftDatabaseFile: "",
+		AirlinesFile:         "",
+		RoutesFile:           "",
+		AirportsFile:         "",
+		RunwaysFile:          "",
+		AirportsRadius:       50,
+		ReceiverRange:        500,
 	}
 	return conf
 }
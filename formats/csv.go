@@ -0,0 +1,263 @@
+package formats
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/boreq/flightradar-backend/storage"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// csvHeader lists the columns of the CSV files. Empty values indicate that
+// the value is unknown. The quality column was added later, the files
+// without it can still be decoded.
+var csvHeader = []string{
+	"time",
+	"icao",
+	"flight_number",
+	"transponder_code",
+	"altitude",
+	"speed",
+	"heading",
+	"latitude",
+	"longitude",
+	"station",
+	"quality",
+}
+
+// csvEncoder writes a header followed by a row per data point. The time is
+// encoded using RFC 3339 with nanoseconds.
+type csvEncoder struct {
+	writer        *csv.Writer
+	headerWritten bool
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	return &csvEncoder{
+		writer: csv.NewWriter(w),
+	}
+}
+
+func (e *csvEncoder) Encode(data storage.StoredData) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	return e.writer.Write([]string{
+		data.Time.UTC().Format(time.RFC3339Nano),
+		formatString(data.Data.Icao),
+		formatString(data.Data.FlightNumber),
+		formatInt(data.Data.TransponderCode),
+		formatInt(data.Data.Altitude),
+		formatInt(data.Data.Speed),
+		formatInt(data.Data.Heading),
+		formatFloat(data.Data.Latitude),
+		formatFloat(data.Data.Longitude),
+		formatString(data.Data.Station),
+		formatInt(data.Data.Quality),
+	})
+}
+
+func (e *csvEncoder) Close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if e.headerWritten {
+		return nil
+	}
+	e.headerWritten = true
+	return e.writer.Write(csvHeader)
+}
+
+func formatString(v *string) string {
+	if v == nil {
+		return ""
+	}
+	return *v
+}
+
+func formatInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func formatFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+// csvDecoder reads the files written by csvEncoder. The columns are matched
+// by the names in the header so their order doesn't matter and unknown
+// columns are ignored. The time can also be provided as a Unix timestamp.
+type csvDecoder struct {
+	reader  *csv.Reader
+	columns map[string]int
+	line    int
+}
+
+func newCSVDecoder(r io.Reader) *csvDecoder {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	return &csvDecoder{
+		reader: reader,
+	}
+}
+
+func (d *csvDecoder) Decode() (storage.StoredData, error) {
+	if d.columns == nil {
+		if err := d.readHeader(); err != nil {
+			return storage.StoredData{}, err
+		}
+	}
+
+	record, err := d.reader.Read()
+	if err != nil {
+		if parseErr, ok := err.(*csv.ParseError); ok {
+			d.line = parseErr.Line
+			return storage.StoredData{}, d.recordError(parseErr.Err)
+		}
+		return storage.StoredData{}, err
+	}
+	d.line, _ = d.reader.FieldPos(0)
+
+	data, err := d.parse(record)
+	if err != nil {
+		return data, d.recordError(err)
+	}
+	if err := Validate(data); err != nil {
+		return data, d.recordError(err)
+	}
+	return data, nil
+}
+
+func (d *csvDecoder) Line() int {
+	return d.line
+}
+
+func (d *csvDecoder) readHeader() error {
+	header, err := d.reader.Read()
+	if err != nil {
+		if err == io.EOF {
+			return err
+		}
+		return fmt.Errorf("Could not read the header: %s", err)
+	}
+	d.line = 1
+	d.columns = make(map[string]int)
+	for i, name := range header {
+		d.columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range []string{"time", "icao"} {
+		if _, ok := d.columns[name]; !ok {
+			return fmt.Errorf("Column %s is missing", name)
+		}
+	}
+	return nil
+}
+
+func (d *csvDecoder) parse(record []string) (storage.StoredData, error) {
+	var data storage.StoredData
+	value := func(name string) string {
+		i, ok := d.columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	t, err := parseTime(value("time"))
+	if err != nil {
+		return data, err
+	}
+	data.Time = t
+
+	data.Data.Icao = parseString(value("icao"))
+	data.Data.FlightNumber = parseString(value("flight_number"))
+	data.Data.Station = parseString(value("station"))
+
+	ints := []struct {
+		Name  string
+		Value **int
+	}{
+		{"transponder_code", &data.Data.TransponderCode},
+		{"altitude", &data.Data.Altitude},
+		{"speed", &data.Data.Speed},
+		{"heading", &data.Data.Heading},
+		{"quality", &data.Data.Quality},
+	}
+	for _, field := range ints {
+		if *field.Value, err = parseInt(value(field.Name)); err != nil {
+			return data, fmt.Errorf("Invalid %s: %s", field.Name, err)
+		}
+	}
+
+	floats := []struct {
+		Name  string
+		Value **float64
+	}{
+		{"latitude", &data.Data.Latitude},
+		{"longitude", &data.Data.Longitude},
+	}
+	for _, field := range floats {
+		if *field.Value, err = parseFloat(value(field.Name)); err != nil {
+			return data, fmt.Errorf("Invalid %s: %s", field.Name, err)
+		}
+	}
+
+	return data, nil
+}
+
+func (d *csvDecoder) recordError(err error) error {
+	return &RecordError{Line: d.line, Err: err}
+}
+
+func parseTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	timestamp, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid time: %s", s)
+	}
+	return time.Unix(timestamp, 0), nil
+}
+
+func parseString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func parseInt(s string) (*int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+func parseFloat(s string) (*float64, error) {
+	if s == "" {
+		return nil, nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
@@ -0,0 +1,227 @@
+// Package formats implements the file formats used to export and import data
+// points. The data points are encoded and decoded one by one so that they
+// don't have to be held in memory.
+package formats
+
+import (
+	"errors"
+	"fmt"
+	"github.com/boreq/flightradar-backend/geojson"
+	"github.com/boreq/flightradar-backend/gpx"
+	"github.com/boreq/flightradar-backend/kml"
+	"github.com/boreq/flightradar-backend/storage"
+	"io"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Encoder encodes a stream of data points.
+type Encoder interface {
+	// Encode encodes a data point. The data points must be provided in
+	// chronological order.
+	Encode(data storage.StoredData) error
+
+	// Close flushes the buffered data and completes the output. It doesn't
+	// close the underlying writer.
+	Close() error
+}
+
+type newEncoder func(w io.Writer) (Encoder, error)
+
+var encoders = map[string]newEncoder{
+	"jsonl": func(w io.Writer) (Encoder, error) {
+		return newJSONLEncoder(w), nil
+	},
+	"csv": func(w io.Writer) (Encoder, error) {
+		return newCSVEncoder(w), nil
+	},
+	"geojson": func(w io.Writer) (Encoder, error) {
+		e := geojson.NewEncoder(w)
+		return newTrackEncoder(func(track []storage.StoredData) error {
+			if feature, ok := geojson.TrackFeature(track); ok {
+				return e.Encode(feature)
+			}
+			return nil
+		}, e.Close), nil
+	},
+	"gpx": func(w io.Writer) (Encoder, error) {
+		e := gpx.NewEncoder(w)
+		return newTrackEncoder(e.EncodeTrack, e.Close), nil
+	},
+	"kml": func(w io.Writer) (Encoder, error) {
+		e := kml.NewEncoder(w, documentName)
+		return newTrackEncoder(e.EncodeTrack, e.Close), nil
+	},
+	"kmz": func(w io.Writer) (Encoder, error) {
+		e, err := kml.NewKMZEncoder(w, documentName)
+		if err != nil {
+			return nil, err
+		}
+		return newTrackEncoder(e.EncodeTrack, e.Close), nil
+	},
+}
+
+// documentName is the name of the exported KML documents.
+const documentName = "Export"
+
+// Names returns the names of the supported formats.
+func Names() []string {
+	var rv []string
+	for name := range encoders {
+		rv = append(rv, name)
+	}
+	sort.Strings(rv)
+	return rv
+}
+
+// IsSupported returns true if the format with the given name exists.
+func IsSupported(format string) bool {
+	_, ok := encoders[format]
+	return ok
+}
+
+// NewEncoder creates an encoder for the format with the given name.
+func NewEncoder(format string, w io.Writer) (Encoder, error) {
+	fn, ok := encoders[format]
+	if !ok {
+		return nil, fmt.Errorf("Unknown format: %s", format)
+	}
+	return fn(w)
+}
+
+// trackEncoder splits the data points into tracks and encodes each track once
+// it is completed.
+type trackEncoder struct {
+	splitter    *storage.TrackSplitter
+	encodeTrack func([]storage.StoredData) error
+	close       func() error
+}
+
+func newTrackEncoder(encodeTrack func([]storage.StoredData) error, close func() error) *trackEncoder {
+	return &trackEncoder{
+		splitter:    storage.NewTrackSplitter(),
+		encodeTrack: encodeTrack,
+		close:       close,
+	}
+}
+
+func (e *trackEncoder) Encode(data storage.StoredData) error {
+	return e.encodeTracks(e.splitter.Add(data))
+}
+
+func (e *trackEncoder) Close() error {
+	if err := e.encodeTracks(e.splitter.Flush()); err != nil {
+		return err
+	}
+	return e.close()
+}
+
+func (e *trackEncoder) encodeTracks(tracks [][]storage.StoredData) error {
+	for _, track := range tracks {
+		if err := e.encodeTrack(track); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decoder decodes a stream of data points.
+type Decoder interface {
+	// Decode returns the next data point. If the data point is invalid a
+	// *RecordError is returned and the decoding can be continued. Any
+	// other error is fatal. io.EOF is returned at the end of the input.
+	Decode() (storage.StoredData, error)
+
+	// Line returns the line number of the last decoded data point.
+	Line() int
+}
+
+// RecordError indicates that a single data point couldn't be decoded.
+type RecordError struct {
+	Line int
+	Err  error
+}
+
+func (e *RecordError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+}
+
+// maxLineLength is the maximum length of a line in the JSON-lines files.
+const maxLineLength = 1024 * 1024
+
+type newDecoder func(r io.Reader) Decoder
+
+var decoders = map[string]newDecoder{
+	"jsonl": func(r io.Reader) Decoder {
+		return newJSONLDecoder(r)
+	},
+	"csv": func(r io.Reader) Decoder {
+		return newCSVDecoder(r)
+	},
+}
+
+// NewDecoder creates a decoder for the format with the given name. Only the
+// jsonl and csv formats can be decoded.
+func NewDecoder(format string, r io.Reader) (Decoder, error) {
+	fn, ok := decoders[format]
+	if !ok {
+		return nil, fmt.Errorf("Format can't be imported: %s", format)
+	}
+	return fn(r), nil
+}
+
+// DetectFormat guesses the format of the file using its extension. Files with
+// the .gz extension are assumed to be compressed using gzip, the extension
+// preceding it is used to determine the format. Files with unknown
+// extensions are assumed to use the jsonl format.
+func DetectFormat(filename string) (format string, compressed bool) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == ".gz" {
+		compressed = true
+		ext = strings.ToLower(filepath.Ext(strings.TrimSuffix(filename, filepath.Ext(filename))))
+	}
+	format = strings.TrimPrefix(ext, ".")
+	if _, ok := decoders[format]; !ok {
+		format = "jsonl"
+	}
+	return format, compressed
+}
+
+// minAltitude and maxAltitude delimit the range of the altitudes in feet
+// which can be reported by the transponders.
+const (
+	minAltitude = -2000
+	maxAltitude = 130000
+)
+
+// Validate checks if the data point can be stored. The decoders return the
+// invalid data points as a *RecordError.
+func Validate(data storage.StoredData) error {
+	if data.Data.Icao == nil || *data.Data.Icao == "" {
+		return errors.New("ICAO is missing")
+	}
+	if data.Time.IsZero() {
+		return errors.New("Time is missing")
+	}
+	if (data.Data.Latitude == nil) != (data.Data.Longitude == nil) {
+		return errors.New("Position is incomplete")
+	}
+	if data.Data.Latitude != nil {
+		latitude, longitude := *data.Data.Latitude, *data.Data.Longitude
+		if math.IsNaN(latitude) || math.IsInf(latitude, 0) || math.IsNaN(longitude) || math.IsInf(longitude, 0) {
+			return errors.New("Position must be finite")
+		}
+		if latitude < -90 || latitude > 90 {
+			return fmt.Errorf("Latitude %f is out of range", latitude)
+		}
+		if longitude < -180 || longitude > 180 {
+			return fmt.Errorf("Longitude %f is out of range", longitude)
+		}
+	}
+	if data.Data.Altitude != nil && (*data.Data.Altitude < minAltitude || *data.Data.Altitude > maxAltitude) {
+		return fmt.Errorf("Altitude %d is out of range", *data.Data.Altitude)
+	}
+	return nil
+}
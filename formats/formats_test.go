@@ -0,0 +1,237 @@
+package formats
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/boreq/flightradar-backend/storage"
+	"io"
+	"testing"
+	"time"
+)
+
+func createData(icao string, t int64) storage.StoredData {
+	altitude := 1000
+	lat := 50.0
+	lon := 20.0
+	return storage.StoredData{
+		Time: time.Unix(t, 0),
+		Data: storage.Data{
+			Icao:      &icao,
+			Altitude:  &altitude,
+			Latitude:  &lat,
+			Longitude: &lon,
+		},
+	}
+}
+
+func TestFormats(t *testing.T) {
+	data := []storage.StoredData{
+		createData("aaaaaa", 0),
+		createData("bbbbbb", 5),
+		createData("aaaaaa", 10),
+	}
+
+	for _, name := range Names() {
+		t.Run(name, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			e, err := NewEncoder(name, buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range data {
+				if err := e.Encode(d); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := e.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if buf.Len() == 0 {
+				t.Fatal("Nothing was written")
+			}
+		})
+	}
+}
+
+func TestUnknownFormat(t *testing.T) {
+	if _, err := NewEncoder("unknown", &bytes.Buffer{}); err == nil {
+		t.Fatal("Unknown format should be rejected")
+	}
+}
+
+func TestCSV(t *testing.T) {
+	buf := &bytes.Buffer{}
+	e := newCSVEncoder(buf)
+	if err := e.Encode(createData("aaaaaa", 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Encode(storage.StoredData{Time: time.Unix(2, 0)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "time,icao,flight_number,transponder_code,altitude,speed,heading,latitude,longitude,station,quality\n" +
+		"1970-01-01T00:00:01Z,aaaaaa,,,1000,,,50,20,,\n" +
+		"1970-01-01T00:00:02Z,,,,,,,,,,\n"
+	if buf.String() != expected {
+		t.Fatalf("Invalid output:\n%s", buf.String())
+	}
+}
+
+func TestCSVWithoutQuality(t *testing.T) {
+	input := "time,icao,flight_number,transponder_code,altitude,speed,heading,latitude,longitude,station\n" +
+		"1970-01-01T00:00:01Z,aaaaaa,,,1000,,,50,20,\n"
+	d := newCSVDecoder(bytes.NewBufferString(input))
+	decoded, err := d.Decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *decoded.Data.Icao != "aaaaaa" || decoded.Data.Quality != nil {
+		t.Errorf("Invalid data point %+v", decoded)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	flightNumber := "LOT3NV"
+	speed := 400
+	quality := storage.QualityAltitudeRejected
+	data := []storage.StoredData{
+		createData("aaaaaa", 1),
+		{Time: time.Unix(2, 500), Data: storage.Data{Icao: &flightNumber, FlightNumber: &flightNumber, Speed: &speed, Quality: &quality}},
+	}
+
+	for format := range decoders {
+		t.Run(format, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			e, err := NewEncoder(format, buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, d := range data {
+				if err := e.Encode(d); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := e.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			d, err := NewDecoder(format, buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range data {
+				decoded, err := d.Decode()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !decoded.Time.Equal(data[i].Time) || *decoded.Data.Icao != *data[i].Data.Icao || formatInt(decoded.Data.Speed) != formatInt(data[i].Data.Speed) || formatInt(decoded.Data.Quality) != formatInt(data[i].Data.Quality) {
+					t.Errorf("Invalid data point %d %+v", i, decoded)
+				}
+			}
+			if _, err := d.Decode(); err != io.EOF {
+				t.Fatalf("Expected EOF, got %v", err)
+			}
+		})
+	}
+}
+
+func TestDecodeInvalidRecords(t *testing.T) {
+	testCases := []struct {
+		Format       string
+		Input        string
+		Lines        []int
+		InvalidLines []int
+	}{
+		{
+			Format: "jsonl",
+			Input: `{"data":{"icao":"aaaaaa"},"time":"1970-01-01T00:00:01Z"}
+invalid
+
+{"data":{},"time":"1970-01-01T00:00:01Z"}
+{"data":{"icao":"aaaaaa"},"time":"1970-01-01T00:00:02Z"}
+{"data":{"icao":"aaaaaa","latitude":91,"longitude":20},"time":"1970-01-01T00:00:03Z"}
+{"data":{"icao":"aaaaaa","latitude":50,"longitude":-181},"time":"1970-01-01T00:00:03Z"}
+{"data":{"icao":"aaaaaa","latitude":50},"time":"1970-01-01T00:00:03Z"}
+{"data":{"icao":"aaaaaa","altitude":500000},"time":"1970-01-01T00:00:03Z"}
+{"data":{"icao":"aaaaaa","latitude":-90,"longitude":180,"altitude":-1000},"time":"1970-01-01T00:00:04Z"}
+`,
+			Lines:        []int{1, 5, 10},
+			InvalidLines: []int{2, 4, 6, 7, 8, 9},
+		},
+		{
+			Format: "csv",
+			Input: `icao,time,latitude,longitude,altitude
+aaaaaa,1,,,
+aaaaaa,invalid,,,
+
+,1,,,
+aaaaaa,2,,,
+aaaaaa,3,NaN,20,
+aaaaaa,3,50,+Inf,
+aaaaaa,3,91,20,
+aaaaaa,3,50,,
+aaaaaa,3,,,-5000
+aaaaaa,4,50,20,45000
+`,
+			Lines:        []int{2, 6, 12},
+			InvalidLines: []int{3, 5, 7, 8, 9, 10, 11},
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Format, func(t *testing.T) {
+			d, err := NewDecoder(testCase.Format, bytes.NewBufferString(testCase.Input))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var lines []int
+			var invalidLines []int
+			for {
+				_, err := d.Decode()
+				if err == io.EOF {
+					break
+				}
+				if recordErr, ok := err.(*RecordError); ok {
+					invalidLines = append(invalidLines, recordErr.Line)
+					continue
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				lines = append(lines, d.Line())
+			}
+
+			if fmt.Sprint(lines) != fmt.Sprint(testCase.Lines) {
+				t.Errorf("Invalid lines %v", lines)
+			}
+			if fmt.Sprint(invalidLines) != fmt.Sprint(testCase.InvalidLines) {
+				t.Errorf("Invalid lines with errors %v", invalidLines)
+			}
+		})
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	testCases := []struct {
+		Filename   string
+		Format     string
+		Compressed bool
+	}{
+		{"export.jsonl", "jsonl", false},
+		{"export", "jsonl", false},
+		{"export.csv", "csv", false},
+		{"export.CSV.gz", "csv", true},
+		{"export.gz", "jsonl", true},
+	}
+
+	for _, testCase := range testCases {
+		format, compressed := DetectFormat(testCase.Filename)
+		if format != testCase.Format || compressed != testCase.Compressed {
+			t.Errorf("Invalid result for %s: %s %t", testCase.Filename, format, compressed)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package formats
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/boreq/flightradar-backend/storage"
+	"io"
+)
+
+// jsonlEncoder writes each data point as a JSON object in a separate line.
+type jsonlEncoder struct {
+	encoder *json.Encoder
+}
+
+func newJSONLEncoder(w io.Writer) *jsonlEncoder {
+	return &jsonlEncoder{
+		encoder: json.NewEncoder(w),
+	}
+}
+
+func (e *jsonlEncoder) Encode(data storage.StoredData) error {
+	return e.encoder.Encode(data)
+}
+
+func (e *jsonlEncoder) Close() error {
+	return nil
+}
+
+// jsonlDecoder reads data points encoded as JSON objects in separate lines.
+// Empty lines are ignored.
+type jsonlDecoder struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func newJSONLDecoder(r io.Reader) *jsonlDecoder {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineLength)
+	return &jsonlDecoder{
+		scanner: scanner,
+	}
+}
+
+func (d *jsonlDecoder) Decode() (storage.StoredData, error) {
+	for d.scanner.Scan() {
+		d.line++
+		if len(bytes.TrimSpace(d.scanner.Bytes())) == 0 {
+			continue
+		}
+		var data storage.StoredData
+		if err := json.Unmarshal(d.scanner.Bytes(), &data); err != nil {
+			return data, d.recordError(err)
+		}
+		if err := Validate(data); err != nil {
+			return data, d.recordError(err)
+		}
+		return data, nil
+	}
+	if err := d.scanner.Err(); err != nil {
+		return storage.StoredData{}, err
+	}
+	return storage.StoredData{}, io.EOF
+}
+
+func (d *jsonlDecoder) Line() int {
+	return d.line
+}
+
+func (d *jsonlDecoder) recordError(err error) error {
+	return &RecordError{Line: d.line, Err: err}
+}
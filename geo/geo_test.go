@@ -0,0 +1,41 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistance(t *testing.T) {
+	// Krakow - Warsaw
+	d := Distance(19.94498, 50.06465, 21.01223, 52.22977)
+	if math.Abs(d-252.1) > 1 {
+		t.Fatalf("Invalid distance %f", d)
+	}
+}
+
+func TestDistanceAlongMeridian(t *testing.T) {
+	d := Distance(20, 50, 20, 51)
+	if math.Abs(d-111.2) > 0.1 {
+		t.Fatalf("Invalid distance %f", d)
+	}
+}
+
+func TestBearing(t *testing.T) {
+	testCases := []struct {
+		Lon      float64
+		Lat      float64
+		Expected float64
+	}{
+		{20, 51, 0},
+		{21, 50, 90},
+		{20, 49, 180},
+		{19, 50, -90},
+	}
+
+	for _, testCase := range testCases {
+		b := Bearing(20, 50, testCase.Lon, testCase.Lat)
+		if math.Abs(b-testCase.Expected) > 1 {
+			t.Errorf("Invalid bearing %f != %f", b, testCase.Expected)
+		}
+	}
+}
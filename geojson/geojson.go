@@ -0,0 +1,223 @@
+// Package geojson encodes the data points as GeoJSON (RFC 7946) so that they
+// can be loaded directly into mapping tools.
+package geojson
+
+import (
+	"encoding/json"
+	"github.com/boreq/flightradar-backend/storage"
+	"io"
+	"time"
+)
+
+// ContentType is the media type of GeoJSON documents.
+const ContentType = "application/geo+json"
+
+// Geometry types.
+const (
+	TypePoint      = "Point"
+	TypeLineString = "LineString"
+	TypePolygon    = "Polygon"
+)
+
+// FeatureCollection is the top level GeoJSON object.
+type FeatureCollection struct {
+	Type     string    `json:"type"`
+	Features []Feature `json:"features"`
+}
+
+// NewFeatureCollection creates a feature collection containing the given
+// features.
+func NewFeatureCollection(features []Feature) FeatureCollection {
+	if features == nil {
+		features = make([]Feature, 0)
+	}
+	return FeatureCollection{
+		Type:     "FeatureCollection",
+		Features: features,
+	}
+}
+
+// ContentType implements the api.RawResponse interface.
+func (c FeatureCollection) ContentType() string {
+	return ContentType
+}
+
+// Write implements the api.RawResponse interface.
+func (c FeatureCollection) Write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(c)
+}
+
+// Encoder writes a feature collection feature by feature.
+type Encoder struct {
+	w       io.Writer
+	started bool
+}
+
+// NewEncoder creates an encoder which writes to w. Close has to be called in
+// order to complete the feature collection.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes a single feature.
+func (e *Encoder) Encode(feature Feature) error {
+	separator := ",\n"
+	if !e.started {
+		e.started = true
+		separator = `{"type":"FeatureCollection","features":[` + "\n"
+	}
+	if _, err := io.WriteString(e.w, separator); err != nil {
+		return err
+	}
+	j, err := json.Marshal(feature)
+	if err != nil {
+		return err
+	}
+	_, err = e.w.Write(j)
+	return err
+}
+
+// Close completes the feature collection. It doesn't close the underlying
+// writer.
+func (e *Encoder) Close() error {
+	if !e.started {
+		return NewFeatureCollection(nil).Write(e.w)
+	}
+	_, err := io.WriteString(e.w, "\n]}\n")
+	return err
+}
+
+// Feature is a geometry with arbitrary properties.
+type Feature struct {
+	Type       string      `json:"type"`
+	Geometry   Geometry    `json:"geometry"`
+	Properties interface{} `json:"properties"`
+}
+
+func newFeature(geometry Geometry, properties interface{}) Feature {
+	return Feature{
+		Type:       "Feature",
+		Geometry:   geometry,
+		Properties: properties,
+	}
+}
+
+// Geometry holds the coordinates of a feature. The positions are encoded as
+// [longitude, latitude] arrays.
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// Position is a GeoJSON position.
+type Position [2]float64
+
+func newPosition(longitude, latitude float64) Position {
+	return Position{longitude, latitude}
+}
+
+// PointFeature returns a Point feature with the data as its properties.
+// False is returned if the data doesn't contain a position.
+func PointFeature(data storage.Data) (Feature, bool) {
+	if data.Latitude == nil || data.Longitude == nil {
+		return Feature{}, false
+	}
+	geometry := Geometry{
+		Type:        TypePoint,
+		Coordinates: newPosition(*data.Longitude, *data.Latitude),
+	}
+	return newFeature(geometry, data), true
+}
+
+// Points returns a collection of Point features, one per data point with a
+// known position.
+func Points(data []storage.Data) FeatureCollection {
+	var features []Feature
+	for _, d := range data {
+		if feature, ok := PointFeature(d); ok {
+			features = append(features, feature)
+		}
+	}
+	return NewFeatureCollection(features)
+}
+
+// TrackProperties are the properties of a track feature. Times and
+// altitudes correspond to the consecutive coordinates of the geometry.
+type TrackProperties struct {
+	Icao         string    `json:"icao"`
+	FlightNumber *string   `json:"flight_number,omitempty"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	Times        []int64   `json:"times"`
+	Altitudes    []*int    `json:"altitudes"`
+}
+
+// TrackFeature returns a LineString feature for a track of a single plane.
+// Data points without a position are skipped. If the track contains only a
+// single position a Point feature is returned instead. False is returned if
+// the track doesn't contain any positions.
+func TrackFeature(track []storage.StoredData) (Feature, bool) {
+	var coordinates []Position
+	properties := TrackProperties{}
+	for _, d := range track {
+		if d.Data.Icao != nil {
+			properties.Icao = *d.Data.Icao
+		}
+		if d.Data.FlightNumber != nil {
+			properties.FlightNumber = d.Data.FlightNumber
+		}
+		if d.Data.Latitude == nil || d.Data.Longitude == nil {
+			continue
+		}
+		if len(coordinates) == 0 {
+			properties.From = d.Time
+		}
+		properties.To = d.Time
+		coordinates = append(coordinates, newPosition(*d.Data.Longitude, *d.Data.Latitude))
+		properties.Times = append(properties.Times, d.Time.Unix())
+		properties.Altitudes = append(properties.Altitudes, d.Data.Altitude)
+	}
+
+	switch len(coordinates) {
+	case 0:
+		return Feature{}, false
+	case 1:
+		return newFeature(Geometry{Type: TypePoint, Coordinates: coordinates[0]}, properties), true
+	default:
+		return newFeature(Geometry{Type: TypeLineString, Coordinates: coordinates}, properties), true
+	}
+}
+
+// Tracks splits the data points into tracks and returns a collection with a
+// feature per track.
+func Tracks(data []storage.StoredData) FeatureCollection {
+	var features []Feature
+	for _, track := range storage.SplitTracks(data) {
+		if feature, ok := TrackFeature(track); ok {
+			features = append(features, feature)
+		}
+	}
+	return NewFeatureCollection(features)
+}
+
+// PolygonFeature returns a Polygon feature with a single ring formed by the
+// given positions. The ring is closed automatically. False is returned if
+// there are not enough positions to form a ring.
+func PolygonFeature(positions []Position, properties interface{}) (Feature, bool) {
+	if len(positions) < 3 {
+		return Feature{}, false
+	}
+	ring := make([]Position, 0, len(positions)+1)
+	ring = append(ring, positions...)
+	ring = append(ring, positions[0])
+	return newFeature(Geometry{Type: TypePolygon, Coordinates: [][]Position{ring}}, properties), true
+}
+
+// Outline returns the positions of the coverage points.
+func Outline(points []storage.CoveragePoint) []Position {
+	var rv []Position
+	for _, point := range points {
+		rv = append(rv, newPosition(point.Longitude, point.Latitude))
+	}
+	return rv
+}
@@ -0,0 +1,120 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/boreq/flightradar-backend/storage"
+	"testing"
+	"time"
+)
+
+func createData(icao string, t int64, lat, lon float64) storage.StoredData {
+	return storage.StoredData{
+		Time: time.Unix(t, 0),
+		Data: storage.Data{
+			Icao:      &icao,
+			Latitude:  &lat,
+			Longitude: &lon,
+		},
+	}
+}
+
+func TestTracks(t *testing.T) {
+	icao := "aaaaaa"
+	data := []storage.StoredData{
+		createData("aaaaaa", 0, 50, 20),
+		createData("aaaaaa", 10, 50.1, 20.1),
+		createData("bbbbbb", 0, 51, 21),
+		{Time: time.Unix(5, 0), Data: storage.Data{Icao: &icao}},
+	}
+
+	collection := Tracks(data)
+	if len(collection.Features) != 2 {
+		t.Fatalf("Wrong number of features %d", len(collection.Features))
+	}
+
+	line := collection.Features[0]
+	if line.Geometry.Type != TypeLineString {
+		t.Fatalf("Wrong geometry %s", line.Geometry.Type)
+	}
+	coordinates := line.Geometry.Coordinates.([]Position)
+	if len(coordinates) != 2 || coordinates[1] != (Position{20.1, 50.1}) {
+		t.Errorf("Wrong coordinates %v", coordinates)
+	}
+
+	if collection.Features[1].Geometry.Type != TypePoint {
+		t.Errorf("Single position should be encoded as a point")
+	}
+}
+
+func TestPolygonFeature(t *testing.T) {
+	if _, ok := PolygonFeature([]Position{{0, 0}, {1, 1}}, nil); ok {
+		t.Fatal("Polygon with two positions should be rejected")
+	}
+
+	feature, ok := PolygonFeature([]Position{{0, 0}, {1, 0}, {1, 1}}, nil)
+	if !ok {
+		t.Fatal("Polygon should be created")
+	}
+	ring := feature.Geometry.Coordinates.([][]Position)[0]
+	if len(ring) != 4 || ring[0] != ring[3] {
+		t.Errorf("Ring is not closed %v", ring)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	collection := Points([]storage.Data{createData("aaaaaa", 0, 50, 20).Data, {}})
+
+	buf := &bytes.Buffer{}
+	if err := collection.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct {
+		Type     string `json:"type"`
+		Features []struct {
+			Geometry struct {
+				Type        string    `json:"type"`
+				Coordinates []float64 `json:"coordinates"`
+			} `json:"geometry"`
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Type != "FeatureCollection" || len(decoded.Features) != 1 {
+		t.Fatalf("Wrong collection %+v", decoded)
+	}
+	feature := decoded.Features[0]
+	if feature.Geometry.Coordinates[0] != 20 || feature.Geometry.Coordinates[1] != 50 {
+		t.Errorf("Wrong coordinates %v", feature.Geometry.Coordinates)
+	}
+	if feature.Properties["icao"] != "aaaaaa" {
+		t.Errorf("Wrong properties %v", feature.Properties)
+	}
+}
+
+func TestEncoder(t *testing.T) {
+	for _, n := range []int{0, 1, 3} {
+		buf := &bytes.Buffer{}
+		e := NewEncoder(buf)
+		for i := 0; i < n; i++ {
+			feature, _ := PointFeature(createData("aaaaaa", 0, 50, 20).Data)
+			if err := e.Encode(feature); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := e.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		var decoded FeatureCollection
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Type != "FeatureCollection" || len(decoded.Features) != n {
+			t.Errorf("Invalid collection for %d features %+v", n, decoded)
+		}
+	}
+}
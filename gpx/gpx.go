@@ -0,0 +1,118 @@
+// Package gpx encodes the tracks of the planes as GPX 1.1 documents.
+package gpx
+
+import (
+	"encoding/xml"
+	"github.com/boreq/flightradar-backend/storage"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// ContentType is the media type of GPX documents.
+const ContentType = "application/gpx+xml"
+
+const namespace = "http://www.topografix.com/GPX/1/1"
+
+const creator = "flightradar-backend"
+
+const feetToMeters = 0.3048
+
+type track struct {
+	XMLName xml.Name     `xml:"trk"`
+	Name    string       `xml:"name"`
+	Points  []trackPoint `xml:"trkseg>trkpt"`
+}
+
+type trackPoint struct {
+	Latitude  float64  `xml:"lat,attr"`
+	Longitude float64  `xml:"lon,attr"`
+	Elevation *float64 `xml:"ele,omitempty"`
+	Time      string   `xml:"time"`
+}
+
+// Encoder writes a GPX document track by track.
+type Encoder struct {
+	encoder *xml.Encoder
+	w       io.Writer
+	started bool
+}
+
+// NewEncoder creates an encoder which writes to w. Close has to be called
+// in order to complete the document.
+func NewEncoder(w io.Writer) *Encoder {
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return &Encoder{
+		encoder: encoder,
+		w:       w,
+	}
+}
+
+func (e *Encoder) start() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	if _, err := io.WriteString(e.w, xml.Header); err != nil {
+		return err
+	}
+	return e.encoder.EncodeToken(xml.StartElement{
+		Name: xml.Name{Local: "gpx"},
+		Attr: []xml.Attr{
+			{Name: xml.Name{Local: "version"}, Value: "1.1"},
+			{Name: xml.Name{Local: "creator"}, Value: creator},
+			{Name: xml.Name{Local: "xmlns"}, Value: namespace},
+		},
+	})
+}
+
+// EncodeTrack writes a track of a single plane. Data points without a
+// position are skipped and tracks without any positions are omitted. The
+// altitude is converted to meters.
+func (e *Encoder) EncodeTrack(data []storage.StoredData) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+
+	var icao, flightNumber string
+	t := track{}
+	for _, d := range data {
+		if d.Data.Icao != nil {
+			icao = *d.Data.Icao
+		}
+		if d.Data.FlightNumber != nil {
+			flightNumber = *d.Data.FlightNumber
+		}
+		if d.Data.Latitude == nil || d.Data.Longitude == nil {
+			continue
+		}
+		point := trackPoint{
+			Latitude:  *d.Data.Latitude,
+			Longitude: *d.Data.Longitude,
+			Time:      d.Time.UTC().Format(time.RFC3339Nano),
+		}
+		if d.Data.Altitude != nil {
+			elevation := math.Round(float64(*d.Data.Altitude) * feetToMeters)
+			point.Elevation = &elevation
+		}
+		t.Points = append(t.Points, point)
+	}
+	if len(t.Points) == 0 {
+		return nil
+	}
+	t.Name = strings.TrimSpace(icao + " " + flightNumber)
+	return e.encoder.Encode(t)
+}
+
+// Close completes the document. It doesn't close the underlying writer.
+func (e *Encoder) Close() error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	if err := e.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: "gpx"}}); err != nil {
+		return err
+	}
+	return e.encoder.Flush()
+}
@@ -0,0 +1,70 @@
+package gpx
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/boreq/flightradar-backend/storage"
+	"testing"
+	"time"
+)
+
+func TestEncoder(t *testing.T) {
+	icao := "aaaaaa"
+	altitude := 1000
+	lat := 50.0
+	lon := 20.0
+	data := []storage.StoredData{
+		{Time: time.Unix(0, 0), Data: storage.Data{Icao: &icao, Altitude: &altitude, Latitude: &lat, Longitude: &lon}},
+		{Time: time.Unix(10, 0), Data: storage.Data{Icao: &icao, Latitude: &lat, Longitude: &lon}},
+		{Time: time.Unix(20, 0), Data: storage.Data{Icao: &icao}},
+	}
+
+	buf := &bytes.Buffer{}
+	e := NewEncoder(buf)
+	if err := e.EncodeTrack(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.EncodeTrack(data[2:]); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Version string  `xml:"version,attr"`
+		Tracks  []track `xml:"trk"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Version != "1.1" || len(doc.Tracks) != 1 {
+		t.Fatalf("Invalid document %+v", doc)
+	}
+	points := doc.Tracks[0].Points
+	if len(points) != 2 {
+		t.Fatalf("Invalid number of points %d", len(points))
+	}
+	if points[0].Elevation == nil || *points[0].Elevation != 305 || points[1].Elevation != nil {
+		t.Errorf("Invalid elevation %v %v", points[0].Elevation, points[1].Elevation)
+	}
+	if points[0].Time != "1970-01-01T00:00:00Z" {
+		t.Errorf("Invalid time %s", points[0].Time)
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	buf := &bytes.Buffer{}
+	if err := NewEncoder(buf).Close(); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.XMLName.Local != "gpx" {
+		t.Fatalf("Invalid root element %s", doc.XMLName.Local)
+	}
+}
@@ -0,0 +1,81 @@
+// Package heatmap aggregates the positions of the data points in order to
+// visualise the traffic density.
+package heatmap
+
+import (
+	"github.com/boreq/flightradar-backend/storage"
+	"math"
+)
+
+// Cell is a cell of a grid identified by its indices.
+type Cell struct {
+	X int
+	Y int
+}
+
+// Grid counts the data points located in each cell of a grid.
+type Grid struct {
+	Cells map[Cell]int
+}
+
+// NewGrid creates an empty grid.
+func NewGrid() *Grid {
+	return &Grid{
+		Cells: make(map[Cell]int),
+	}
+}
+
+// Add increases the counter of the given cell.
+func (g *Grid) Add(cell Cell) {
+	g.Cells[cell]++
+}
+
+// Max returns the highest value of the counters.
+func (g *Grid) Max() int {
+	max := 0
+	for _, v := range g.Cells {
+		if v > max {
+			max = v
+		}
+	}
+	return max
+}
+
+// DegreesGrid creates a grid with cells of the given size in degrees. The X
+// index of a cell is based on the longitude and the Y index is based on the
+// latitude. Data points without a position are ignored.
+func DegreesGrid(data []storage.StoredData, cellSize float64) *Grid {
+	g := NewGrid()
+	for _, d := range data {
+		if d.Data.Latitude == nil || d.Data.Longitude == nil {
+			continue
+		}
+		g.Add(Cell{
+			X: int(math.Floor(*d.Data.Longitude / cellSize)),
+			Y: int(math.Floor(*d.Data.Latitude / cellSize)),
+		})
+	}
+	return g
+}
+
+// TileGrid creates a grid which divides the tile into size by size cells.
+// The indices of the cells increase from the top left corner of the tile.
+// Data points located outside of the tile or without a position are
+// ignored.
+func TileGrid(data []storage.StoredData, tile Tile, size int) *Grid {
+	g := NewGrid()
+	for _, d := range data {
+		if d.Data.Latitude == nil || d.Data.Longitude == nil {
+			continue
+		}
+		x, y := tile.Position(*d.Data.Latitude, *d.Data.Longitude)
+		if x < 0 || x >= 1 || y < 0 || y >= 1 {
+			continue
+		}
+		g.Add(Cell{
+			X: int(x * float64(size)),
+			Y: int(y * float64(size)),
+		})
+	}
+	return g
+}
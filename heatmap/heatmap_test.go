@@ -0,0 +1,105 @@
+package heatmap
+
+import (
+	"bytes"
+	"github.com/boreq/flightradar-backend/storage"
+	"image/png"
+	"math"
+	"testing"
+	"time"
+)
+
+func createData(latitude, longitude float64) storage.StoredData {
+	icao := "aaaaaa"
+	return storage.StoredData{
+		Time: time.Unix(0, 0),
+		Data: storage.Data{
+			Icao:      &icao,
+			Latitude:  &latitude,
+			Longitude: &longitude,
+		},
+	}
+}
+
+func TestTileBoundingBox(t *testing.T) {
+	// Tile containing Krakow.
+	tile, err := NewTile(10, 568, 347)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bbox := tile.BoundingBox()
+	if !bbox.Contains(50.06, 19.94) {
+		t.Fatalf("Bounding box doesn't contain Krakow %v", bbox)
+	}
+	if math.Abs(bbox.MaxLongitude-bbox.MinLongitude-360.0/1024) > 1e-9 {
+		t.Fatalf("Invalid bounding box width %v", bbox)
+	}
+}
+
+func TestTilePosition(t *testing.T) {
+	tile, err := NewTile(10, 568, 347)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bbox := tile.BoundingBox()
+
+	x, y := tile.Position(bbox.MaxLatitude, bbox.MinLongitude)
+	if math.Abs(x) > 1e-9 || math.Abs(y) > 1e-9 {
+		t.Errorf("Invalid top left corner %f %f", x, y)
+	}
+	x, y = tile.Position(bbox.MinLatitude, bbox.MaxLongitude)
+	if math.Abs(x-1) > 1e-9 || math.Abs(y-1) > 1e-9 {
+		t.Errorf("Invalid bottom right corner %f %f", x, y)
+	}
+}
+
+func TestNewTileInvalid(t *testing.T) {
+	for _, c := range [][3]int{{-1, 0, 0}, {MaxZoom + 1, 0, 0}, {1, 2, 0}, {1, 0, -1}} {
+		if _, err := NewTile(c[0], c[1], c[2]); err == nil {
+			t.Errorf("Expected an error for %v", c)
+		}
+	}
+}
+
+func TestDegreesGrid(t *testing.T) {
+	data := []storage.StoredData{
+		createData(50.01, 20.01),
+		createData(50.09, 20.09),
+		createData(50.11, 20.01),
+		createData(-0.01, -0.01),
+	}
+	g := DegreesGrid(data, 0.1)
+	expected := map[Cell]int{
+		{200, 500}: 2,
+		{200, 501}: 1,
+		{-1, -1}:   1,
+	}
+	if len(g.Cells) != len(expected) {
+		t.Fatalf("Invalid cells %v", g.Cells)
+	}
+	for cell, n := range expected {
+		if g.Cells[cell] != n {
+			t.Errorf("Invalid count for %v: %d", cell, g.Cells[cell])
+		}
+	}
+}
+
+func TestRenderTile(t *testing.T) {
+	tile, err := NewTile(10, 568, 347)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := RenderTile([]storage.StoredData{createData(50.06, 19.94)}, tile)
+
+	buf := &bytes.Buffer{}
+	if err := EncodeTile(buf, img); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Bounds().Dx() != TileSize || decoded.Bounds().Dy() != TileSize {
+		t.Fatalf("Invalid size %v", decoded.Bounds())
+	}
+}
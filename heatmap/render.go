@@ -0,0 +1,101 @@
+package heatmap
+
+import (
+	"github.com/boreq/flightradar-backend/storage"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+)
+
+// TileSize is the width and height of the rendered tiles in pixels.
+const TileSize = 256
+
+// pointRadius is the radius in pixels of the area affected by a single data
+// point.
+const pointRadius = 3
+
+// RenderTile draws a heatmap of the data points located within the tile.
+func RenderTile(data []storage.StoredData, tile Tile) image.Image {
+	g := TileGrid(data, tile, TileSize)
+
+	// Spread each data point over the neighbouring pixels.
+	intensity := make([]float64, TileSize*TileSize)
+	for cell, n := range g.Cells {
+		for dy := -pointRadius; dy <= pointRadius; dy++ {
+			for dx := -pointRadius; dx <= pointRadius; dx++ {
+				x := cell.X + dx
+				y := cell.Y + dy
+				if x < 0 || x >= TileSize || y < 0 || y >= TileSize {
+					continue
+				}
+				d := math.Sqrt(float64(dx*dx + dy*dy))
+				if d > pointRadius {
+					continue
+				}
+				intensity[y*TileSize+x] += float64(n) * (1 - d/(pointRadius+1))
+			}
+		}
+	}
+
+	max := 0.0
+	for _, v := range intensity {
+		if v > max {
+			max = v
+		}
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, TileSize, TileSize))
+	if max == 0 {
+		return img
+	}
+	for y := 0; y < TileSize; y++ {
+		for x := 0; x < TileSize; x++ {
+			v := intensity[y*TileSize+x]
+			if v == 0 {
+				continue
+			}
+			// Logarithmic scale makes the less busy areas visible.
+			img.SetNRGBA(x, y, colorRamp(math.Log1p(v)/math.Log1p(max)))
+		}
+	}
+	return img
+}
+
+// EncodeTile writes the image in the PNG format.
+func EncodeTile(w io.Writer, img image.Image) error {
+	return png.Encode(w, img)
+}
+
+// colorRamp maps a value in the range [0, 1] to a color changing from
+// translucent blue through green and yellow to opaque red.
+func colorRamp(v float64) color.NRGBA {
+	stops := []struct {
+		V float64
+		C color.NRGBA
+	}{
+		{0, color.NRGBA{0, 0, 255, 64}},
+		{0.33, color.NRGBA{0, 255, 0, 128}},
+		{0.66, color.NRGBA{255, 255, 0, 192}},
+		{1, color.NRGBA{255, 0, 0, 255}},
+	}
+	for i := 1; i < len(stops); i++ {
+		if v <= stops[i].V {
+			a := stops[i-1]
+			b := stops[i]
+			f := (v - a.V) / (b.V - a.V)
+			return color.NRGBA{
+				R: interpolate(a.C.R, b.C.R, f),
+				G: interpolate(a.C.G, b.C.G, f),
+				B: interpolate(a.C.B, b.C.B, f),
+				A: interpolate(a.C.A, b.C.A, f),
+			}
+		}
+	}
+	return stops[len(stops)-1].C
+}
+
+func interpolate(a, b uint8, f float64) uint8 {
+	return uint8(float64(a) + (float64(b)-float64(a))*f)
+}
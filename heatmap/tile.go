@@ -0,0 +1,55 @@
+package heatmap
+
+import (
+	"errors"
+	"github.com/boreq/flightradar-backend/storage"
+	"math"
+)
+
+// MaxZoom is the highest supported zoom level.
+const MaxZoom = 18
+
+// Tile identifies a slippy map tile using the Web Mercator projection.
+type Tile struct {
+	Z int
+	X int
+	Y int
+}
+
+// NewTile validates the tile coordinates.
+func NewTile(z, x, y int) (Tile, error) {
+	if z < 0 || z > MaxZoom {
+		return Tile{}, errors.New("invalid zoom level")
+	}
+	n := 1 << uint(z)
+	if x < 0 || x >= n || y < 0 || y >= n {
+		return Tile{}, errors.New("invalid tile coordinates")
+	}
+	return Tile{Z: z, X: x, Y: y}, nil
+}
+
+// BoundingBox returns the area covered by the tile.
+func (t Tile) BoundingBox() storage.BoundingBox {
+	n := float64(int(1) << uint(t.Z))
+	return storage.BoundingBox{
+		MinLatitude:  tileLatitude(float64(t.Y+1), n),
+		MaxLatitude:  tileLatitude(float64(t.Y), n),
+		MinLongitude: float64(t.X)/n*360 - 180,
+		MaxLongitude: float64(t.X+1)/n*360 - 180,
+	}
+}
+
+// Position returns the position within the tile expressed as a fraction of
+// its width and height measured from its top left corner. Positions located
+// outside of the tile are outside of the [0, 1) range.
+func (t Tile) Position(latitude, longitude float64) (float64, float64) {
+	n := float64(int(1) << uint(t.Z))
+	x := (longitude + 180) / 360 * n
+	latRad := latitude * math.Pi / 180
+	y := (1 - math.Log(math.Tan(latRad)+1/math.Cos(latRad))/math.Pi) / 2 * n
+	return x - float64(t.X), y - float64(t.Y)
+}
+
+func tileLatitude(y, n float64) float64 {
+	return math.Atan(math.Sinh(math.Pi*(1-2*y/n))) * 180 / math.Pi
+}
@@ -0,0 +1,239 @@
+package icao
+
+// countryBlocks lists the blocks of addresses allocated to the states of
+// registry as defined in ICAO Annex 10, Volume III, Chapter 9.
+var countryBlocks = []block{
+	{0x004000, 0x0043ff, "Zimbabwe", "ZW"},
+	{0x006000, 0x006fff, "Mozambique", "MZ"},
+	{0x008000, 0x00ffff, "South Africa", "ZA"},
+	{0x010000, 0x017fff, "Egypt", "EG"},
+	{0x018000, 0x01ffff, "Libya", "LY"},
+	{0x020000, 0x027fff, "Morocco", "MA"},
+	{0x028000, 0x02ffff, "Tunisia", "TN"},
+	{0x030000, 0x0303ff, "Botswana", "BW"},
+	{0x032000, 0x032fff, "Burundi", "BI"},
+	{0x034000, 0x034fff, "Cameroon", "CM"},
+	{0x035000, 0x0353ff, "Comoros", "KM"},
+	{0x036000, 0x036fff, "Congo", "CG"},
+	{0x038000, 0x038fff, "Côte d'Ivoire", "CI"},
+	{0x03e000, 0x03efff, "Gabon", "GA"},
+	{0x040000, 0x040fff, "Ethiopia", "ET"},
+	{0x042000, 0x042fff, "Equatorial Guinea", "GQ"},
+	{0x044000, 0x044fff, "Ghana", "GH"},
+	{0x046000, 0x046fff, "Guinea", "GN"},
+	{0x048000, 0x0483ff, "Guinea-Bissau", "GW"},
+	{0x04a000, 0x04a3ff, "Lesotho", "LS"},
+	{0x04c000, 0x04cfff, "Kenya", "KE"},
+	{0x050000, 0x050fff, "Liberia", "LR"},
+	{0x054000, 0x054fff, "Madagascar", "MG"},
+	{0x058000, 0x058fff, "Malawi", "MW"},
+	{0x05a000, 0x05a3ff, "Maldives", "MV"},
+	{0x05c000, 0x05cfff, "Mali", "ML"},
+	{0x05e000, 0x05e3ff, "Mauritania", "MR"},
+	{0x060000, 0x0603ff, "Mauritius", "MU"},
+	{0x062000, 0x062fff, "Niger", "NE"},
+	{0x064000, 0x064fff, "Nigeria", "NG"},
+	{0x068000, 0x068fff, "Uganda", "UG"},
+	{0x06a000, 0x06a3ff, "Qatar", "QA"},
+	{0x06c000, 0x06cfff, "Central African Republic", "CF"},
+	{0x06e000, 0x06efff, "Rwanda", "RW"},
+	{0x070000, 0x070fff, "Senegal", "SN"},
+	{0x074000, 0x0743ff, "Seychelles", "SC"},
+	{0x076000, 0x0763ff, "Sierra Leone", "SL"},
+	{0x078000, 0x078fff, "Somalia", "SO"},
+	{0x07a000, 0x07a3ff, "Eswatini", "SZ"},
+	{0x07c000, 0x07cfff, "Sudan", "SD"},
+	{0x080000, 0x080fff, "Tanzania", "TZ"},
+	{0x084000, 0x084fff, "Chad", "TD"},
+	{0x088000, 0x088fff, "Togo", "TG"},
+	{0x08a000, 0x08afff, "Zambia", "ZM"},
+	{0x08c000, 0x08cfff, "Democratic Republic of the Congo", "CD"},
+	{0x090000, 0x090fff, "Angola", "AO"},
+	{0x094000, 0x0943ff, "Benin", "BJ"},
+	{0x096000, 0x0963ff, "Cabo Verde", "CV"},
+	{0x098000, 0x0983ff, "Djibouti", "DJ"},
+	{0x09a000, 0x09afff, "Gambia", "GM"},
+	{0x09c000, 0x09cfff, "Burkina Faso", "BF"},
+	{0x09e000, 0x09e3ff, "Sao Tome and Principe", "ST"},
+	{0x0a0000, 0x0a7fff, "Algeria", "DZ"},
+	{0x0a8000, 0x0a8fff, "Bahamas", "BS"},
+	{0x0aa000, 0x0aa3ff, "Barbados", "BB"},
+	{0x0ab000, 0x0ab3ff, "Belize", "BZ"},
+	{0x0ac000, 0x0acfff, "Colombia", "CO"},
+	{0x0ae000, 0x0aefff, "Costa Rica", "CR"},
+	{0x0b0000, 0x0b0fff, "Cuba", "CU"},
+	{0x0b2000, 0x0b2fff, "El Salvador", "SV"},
+	{0x0b4000, 0x0b4fff, "Guatemala", "GT"},
+	{0x0b6000, 0x0b6fff, "Guyana", "GY"},
+	{0x0b8000, 0x0b8fff, "Haiti", "HT"},
+	{0x0ba000, 0x0bafff, "Honduras", "HN"},
+	{0x0bc000, 0x0bc3ff, "Saint Vincent and the Grenadines", "VC"},
+	{0x0be000, 0x0befff, "Jamaica", "JM"},
+	{0x0c0000, 0x0c0fff, "Nicaragua", "NI"},
+	{0x0c2000, 0x0c2fff, "Panama", "PA"},
+	{0x0c4000, 0x0c4fff, "Dominican Republic", "DO"},
+	{0x0c6000, 0x0c6fff, "Trinidad and Tobago", "TT"},
+	{0x0c8000, 0x0c8fff, "Suriname", "SR"},
+	{0x0ca000, 0x0ca3ff, "Antigua and Barbuda", "AG"},
+	{0x0cc000, 0x0cc3ff, "Grenada", "GD"},
+	{0x0d0000, 0x0d7fff, "Mexico", "MX"},
+	{0x0d8000, 0x0dffff, "Venezuela", "VE"},
+	{0x100000, 0x1fffff, "Russia", "RU"},
+	{0x201000, 0x2013ff, "Namibia", "NA"},
+	{0x202000, 0x2023ff, "Eritrea", "ER"},
+	{0x300000, 0x33ffff, "Italy", "IT"},
+	{0x340000, 0x37ffff, "Spain", "ES"},
+	{0x380000, 0x3bffff, "France", "FR"},
+	{0x3c0000, 0x3fffff, "Germany", "DE"},
+	{0x400000, 0x43ffff, "United Kingdom", "GB"},
+	{0x440000, 0x447fff, "Austria", "AT"},
+	{0x448000, 0x44ffff, "Belgium", "BE"},
+	{0x450000, 0x457fff, "Bulgaria", "BG"},
+	{0x458000, 0x45ffff, "Denmark", "DK"},
+	{0x460000, 0x467fff, "Finland", "FI"},
+	{0x468000, 0x46ffff, "Greece", "GR"},
+	{0x470000, 0x477fff, "Hungary", "HU"},
+	{0x478000, 0x47ffff, "Norway", "NO"},
+	{0x480000, 0x487fff, "Netherlands", "NL"},
+	{0x488000, 0x48ffff, "Poland", "PL"},
+	{0x490000, 0x497fff, "Portugal", "PT"},
+	{0x498000, 0x49ffff, "Czechia", "CZ"},
+	{0x4a0000, 0x4a7fff, "Romania", "RO"},
+	{0x4a8000, 0x4affff, "Sweden", "SE"},
+	{0x4b0000, 0x4b7fff, "Switzerland", "CH"},
+	{0x4b8000, 0x4bffff, "Turkey", "TR"},
+	{0x4c0000, 0x4c7fff, "Serbia", "RS"},
+	{0x4c8000, 0x4c83ff, "Cyprus", "CY"},
+	{0x4ca000, 0x4cafff, "Ireland", "IE"},
+	{0x4cc000, 0x4ccfff, "Iceland", "IS"},
+	{0x4d0000, 0x4d03ff, "Luxembourg", "LU"},
+	{0x4d2000, 0x4d23ff, "Malta", "MT"},
+	{0x4d4000, 0x4d43ff, "Monaco", "MC"},
+	{0x500000, 0x5003ff, "San Marino", "SM"},
+	{0x501000, 0x5013ff, "Albania", "AL"},
+	{0x501c00, 0x501fff, "Croatia", "HR"},
+	{0x502c00, 0x502fff, "Latvia", "LV"},
+	{0x503c00, 0x503fff, "Lithuania", "LT"},
+	{0x504c00, 0x504fff, "Moldova", "MD"},
+	{0x505c00, 0x505fff, "Slovakia", "SK"},
+	{0x506c00, 0x506fff, "Slovenia", "SI"},
+	{0x507c00, 0x507fff, "Uzbekistan", "UZ"},
+	{0x508000, 0x50ffff, "Ukraine", "UA"},
+	{0x510000, 0x5103ff, "Belarus", "BY"},
+	{0x511000, 0x5113ff, "Estonia", "EE"},
+	{0x512000, 0x5123ff, "North Macedonia", "MK"},
+	{0x513000, 0x5133ff, "Bosnia and Herzegovina", "BA"},
+	{0x514000, 0x5143ff, "Georgia", "GE"},
+	{0x515000, 0x5153ff, "Tajikistan", "TJ"},
+	{0x516000, 0x5163ff, "Montenegro", "ME"},
+	{0x600000, 0x6003ff, "Armenia", "AM"},
+	{0x600800, 0x600bff, "Azerbaijan", "AZ"},
+	{0x601000, 0x6013ff, "Kyrgyzstan", "KG"},
+	{0x601800, 0x601bff, "Turkmenistan", "TM"},
+	{0x680000, 0x6803ff, "Bhutan", "BT"},
+	{0x681000, 0x6813ff, "Micronesia", "FM"},
+	{0x682000, 0x6823ff, "Mongolia", "MN"},
+	{0x683000, 0x6833ff, "Kazakhstan", "KZ"},
+	{0x684000, 0x6843ff, "Palau", "PW"},
+	{0x700000, 0x700fff, "Afghanistan", "AF"},
+	{0x702000, 0x702fff, "Bangladesh", "BD"},
+	{0x704000, 0x704fff, "Myanmar", "MM"},
+	{0x706000, 0x706fff, "Kuwait", "KW"},
+	{0x708000, 0x708fff, "Laos", "LA"},
+	{0x70a000, 0x70afff, "Nepal", "NP"},
+	{0x70c000, 0x70c3ff, "Oman", "OM"},
+	{0x70e000, 0x70efff, "Cambodia", "KH"},
+	{0x710000, 0x717fff, "Saudi Arabia", "SA"},
+	{0x718000, 0x71ffff, "South Korea", "KR"},
+	{0x720000, 0x727fff, "North Korea", "KP"},
+	{0x728000, 0x72ffff, "Iraq", "IQ"},
+	{0x730000, 0x737fff, "Iran", "IR"},
+	{0x738000, 0x73ffff, "Israel", "IL"},
+	{0x740000, 0x747fff, "Jordan", "JO"},
+	{0x748000, 0x74ffff, "Lebanon", "LB"},
+	{0x750000, 0x757fff, "Malaysia", "MY"},
+	{0x758000, 0x75ffff, "Philippines", "PH"},
+	{0x760000, 0x767fff, "Pakistan", "PK"},
+	{0x768000, 0x76ffff, "Singapore", "SG"},
+	{0x770000, 0x777fff, "Sri Lanka", "LK"},
+	{0x778000, 0x77ffff, "Syria", "SY"},
+	{0x780000, 0x7bffff, "China", "CN"},
+	{0x7c0000, 0x7fffff, "Australia", "AU"},
+	{0x800000, 0x83ffff, "India", "IN"},
+	{0x840000, 0x87ffff, "Japan", "JP"},
+	{0x880000, 0x887fff, "Thailand", "TH"},
+	{0x888000, 0x88ffff, "Viet Nam", "VN"},
+	{0x890000, 0x890fff, "Yemen", "YE"},
+	{0x894000, 0x894fff, "Bahrain", "BH"},
+	{0x895000, 0x8953ff, "Brunei", "BN"},
+	{0x896000, 0x896fff, "United Arab Emirates", "AE"},
+	{0x897000, 0x8973ff, "Solomon Islands", "SB"},
+	{0x898000, 0x898fff, "Papua New Guinea", "PG"},
+	{0x899000, 0x8993ff, "Taiwan", "TW"},
+	{0x8a0000, 0x8a7fff, "Indonesia", "ID"},
+	{0x900000, 0x9003ff, "Marshall Islands", "MH"},
+	{0x901000, 0x9013ff, "Cook Islands", "CK"},
+	{0x902000, 0x9023ff, "Samoa", "WS"},
+	{0xa00000, 0xafffff, "United States", "US"},
+	{0xc00000, 0xc3ffff, "Canada", "CA"},
+	{0xc80000, 0xc87fff, "New Zealand", "NZ"},
+	{0xc88000, 0xc88fff, "Fiji", "FJ"},
+	{0xc8a000, 0xc8a3ff, "Nauru", "NR"},
+	{0xc8c000, 0xc8c3ff, "Saint Lucia", "LC"},
+	{0xc8d000, 0xc8d3ff, "Tonga", "TO"},
+	{0xc8e000, 0xc8e3ff, "Kiribati", "KI"},
+	{0xc90000, 0xc903ff, "Vanuatu", "VU"},
+	{0xe00000, 0xe3ffff, "Argentina", "AR"},
+	{0xe40000, 0xe7ffff, "Brazil", "BR"},
+	{0xe80000, 0xe80fff, "Chile", "CL"},
+	{0xe84000, 0xe84fff, "Ecuador", "EC"},
+	{0xe88000, 0xe88fff, "Paraguay", "PY"},
+	{0xe8c000, 0xe8cfff, "Peru", "PE"},
+	{0xe90000, 0xe90fff, "Uruguay", "UY"},
+	{0xe94000, 0xe94fff, "Bolivia", "BO"},
+	{0xf00000, 0xf07fff, "ICAO (temporary address)", ""},
+	{0xf09000, 0xf093ff, "ICAO (special use)", ""},
+}
+
+// militaryBlocks lists the parts of the country blocks which are known to be
+// used by military aircraft. These blocks aren't defined by ICAO and are
+// based on the observed allocations so the list isn't exhaustive.
+var militaryBlocks = []block{
+	{0x010070, 0x01008f, "Egypt", "EG"},
+	{0x0a4000, 0x0a4fff, "Algeria", "DZ"},
+	{0x33ff00, 0x33ffff, "Italy", "IT"},
+	{0x350000, 0x37ffff, "Spain", "ES"},
+	{0x3a8000, 0x3bffff, "France", "FR"},
+	{0x3e8000, 0x3ebfff, "Germany", "DE"},
+	{0x3f4000, 0x3fbfff, "Germany", "DE"},
+	{0x400000, 0x40003f, "United Kingdom", "GB"},
+	{0x43c000, 0x43cfff, "United Kingdom", "GB"},
+	{0x444000, 0x446fff, "Austria", "AT"},
+	{0x44f000, 0x44ffff, "Belgium", "BE"},
+	{0x457000, 0x457fff, "Bulgaria", "BG"},
+	{0x45f400, 0x45f4ff, "Denmark", "DK"},
+	{0x468000, 0x4683ff, "Greece", "GR"},
+	{0x473c00, 0x473c0f, "Hungary", "HU"},
+	{0x478100, 0x4781ff, "Norway", "NO"},
+	{0x480000, 0x480fff, "Netherlands", "NL"},
+	{0x48d800, 0x48d87f, "Poland", "PL"},
+	{0x497c00, 0x497cff, "Portugal", "PT"},
+	{0x498420, 0x49842f, "Czechia", "CZ"},
+	{0x4b7000, 0x4b7fff, "Switzerland", "CH"},
+	{0x4b8200, 0x4b82ff, "Turkey", "TR"},
+	{0x506f00, 0x506fff, "Slovenia", "SI"},
+	{0x70c070, 0x70c07f, "Oman", "OM"},
+	{0x710258, 0x71028f, "Saudi Arabia", "SA"},
+	{0x710380, 0x71039f, "Saudi Arabia", "SA"},
+	{0x738a00, 0x738aff, "Israel", "IL"},
+	{0x7c822e, 0x7c84ff, "Australia", "AU"},
+	{0x7c8800, 0x7c88ff, "Australia", "AU"},
+	{0x7c9000, 0x7cbfff, "Australia", "AU"},
+	{0x7cf800, 0x7cfaff, "Australia", "AU"},
+	{0x7d0000, 0x7fffff, "Australia", "AU"},
+	{0x800200, 0x8002ff, "India", "IN"},
+	{0xadf7c8, 0xafffff, "United States", "US"},
+	{0xc0cdf9, 0xc3ffff, "Canada", "CA"},
+	{0xe40000, 0xe41fff, "Brazil", "BR"},
+	{0xe80600, 0xe806ff, "Chile", "CL"},
+}
@@ -0,0 +1,73 @@
+// Package icao decodes the information encoded in the 24-bit ICAO aircraft
+// addresses.
+package icao
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Allocation describes the block from which an address was allocated.
+type Allocation struct {
+	// Country is the name of the state of registry.
+	Country string `json:"country"`
+
+	// CountryCode is the ISO 3166-1 alpha-2 code of the state of registry.
+	// It is empty for the blocks which aren't allocated to a state.
+	CountryCode string `json:"country_code,omitempty"`
+
+	// Military is true if the address belongs to a block known to be used
+	// by military aircraft.
+	Military bool `json:"military"`
+}
+
+// Parse converts a hexadecimal address to a number. False is returned if the
+// address is invalid.
+func Parse(address string) (uint32, bool) {
+	address = strings.TrimSpace(address)
+	if len(address) != 6 {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(address, 16, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(n), true
+}
+
+// Lookup returns the allocation of the hexadecimal address. False is
+// returned if the address is invalid or doesn't belong to any known block.
+func Lookup(address string) (Allocation, bool) {
+	n, ok := Parse(address)
+	if !ok {
+		return Allocation{}, false
+	}
+	b, ok := findBlock(countryBlocks, n)
+	if !ok {
+		return Allocation{}, false
+	}
+	_, military := findBlock(militaryBlocks, n)
+	return Allocation{
+		Country:     b.Name,
+		CountryCode: b.Code,
+		Military:    military,
+	}, true
+}
+
+type block struct {
+	From uint32
+	To   uint32
+	Name string
+	Code string
+}
+
+// findBlock returns the block containing the address. The blocks must be
+// sorted and mustn't overlap.
+func findBlock(blocks []block, n uint32) (block, bool) {
+	i := sort.Search(len(blocks), func(i int) bool { return blocks[i].To >= n })
+	if i < len(blocks) && blocks[i].From <= n {
+		return blocks[i], true
+	}
+	return block{}, false
+}
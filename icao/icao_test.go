@@ -0,0 +1,68 @@
+package icao
+
+import (
+	"testing"
+)
+
+func TestBlocksSorted(t *testing.T) {
+	for name, blocks := range map[string][]block{"country": countryBlocks, "military": militaryBlocks} {
+		for i, b := range blocks {
+			if b.From > b.To {
+				t.Errorf("Invalid %s block %06x-%06x", name, b.From, b.To)
+			}
+			if i > 0 && blocks[i-1].To >= b.From {
+				t.Errorf("Overlapping %s blocks %06x and %06x", name, blocks[i-1].From, b.From)
+			}
+		}
+	}
+}
+
+func TestMilitaryBlocksWithinCountries(t *testing.T) {
+	for _, b := range militaryBlocks {
+		for _, n := range []uint32{b.From, b.To} {
+			country, ok := findBlock(countryBlocks, n)
+			if !ok || country.Code != b.Code {
+				t.Errorf("Military block %06x-%06x is outside of %s", b.From, b.To, b.Name)
+			}
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	testCases := []struct {
+		Address  string
+		Ok       bool
+		Code     string
+		Military bool
+	}{
+		{"48ae22", true, "PL", false},
+		{"48AE22", true, "PL", false},
+		{"48d810", true, "PL", true},
+		{"a00001", true, "US", false},
+		{"ae1234", true, "US", true},
+		{"3c4b26", true, "DE", false},
+		{"004000", true, "ZW", false},
+		{"0043ff", true, "ZW", false},
+		{"004400", false, "", false},
+		{"f00001", true, "", false},
+		{"ffffff", false, "", false},
+		{"invalid", false, "", false},
+		{"zzzzzz", false, "", false},
+		{"", false, "", false},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Address, func(t *testing.T) {
+			allocation, ok := Lookup(testCase.Address)
+			if ok != testCase.Ok {
+				t.Fatalf("Wrong result %t", ok)
+			}
+			if allocation.CountryCode != testCase.Code {
+				t.Errorf("Wrong country %v", allocation)
+			}
+			if allocation.Military != testCase.Military {
+				t.Errorf("Wrong military flag %v", allocation)
+			}
+		})
+	}
+}
@@ -0,0 +1,446 @@
+// Package importer stores the data points read from a file. The data points
+// are stored by a bounded number of workers and the progress can be saved in
+// a checkpoint file so that an interrupted import can be resumed.
+package importer
+
+import (
+	"fmt"
+	"github.com/boreq/flightradar-backend/formats"
+	"github.com/boreq/flightradar-backend/storage"
+	"hash/fnv"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWorkers is the default number of workers storing the data points.
+const DefaultWorkers = 8
+
+// DefaultProgressInterval is the default interval at which the progress is
+// reported and the checkpoint is saved.
+const DefaultProgressInterval = 5 * time.Second
+
+// queueSize is the number of data points which can be queued for each
+// worker.
+const queueSize = 1000
+
+// batchSize is the maximum number of data points stored in a single batch.
+const batchSize = 500
+
+type Options struct {
+	// Workers is the number of goroutines storing the data points. The
+	// data points of a single plane are always stored by the same worker.
+	Workers int
+
+	// Overwrite causes the data points which are already stored to be
+	// replaced. By default they are skipped.
+	Overwrite bool
+
+	// Station is assigned to the data points which don't specify the
+	// station which received them. Can be empty.
+	Station string
+
+	// Checkpoint is the path of the file in which the number of processed
+	// records is saved. If the file exists those records are skipped. The
+	// file is removed once the import is completed. Empty path disables
+	// the checkpoints.
+	Checkpoint string
+
+	// ProgressInterval is the interval at which Progress is called and the
+	// checkpoint is saved.
+	ProgressInterval time.Duration
+
+	// Progress is called periodically while the import is running. Can be
+	// nil.
+	Progress func(Summary)
+
+	// InvalidRecord is called for each record which couldn't be decoded.
+	// Can be nil.
+	InvalidRecord func(*formats.RecordError)
+
+	// Conflict is called for each imported data point which conflicts with
+	// a data point which is already stored. Can be nil.
+	Conflict func(existing, imported storage.StoredData)
+}
+
+// Summary describes the progress of the import.
+type Summary struct {
+	// Resumed is the number of records skipped due to the checkpoint.
+	Resumed int
+
+	// Stored is the number of data points which were stored.
+	Stored int
+
+	// Duplicates is the number of data points which were already stored.
+	// They are skipped or overwritten depending on the options.
+	Duplicates int
+
+	// Conflicts is the number of data points with the same time and ICAO
+	// as the already stored data points but different data. They are
+	// skipped or overwritten depending on the options.
+	Conflicts int
+
+	// Invalid is the number of records which couldn't be decoded.
+	Invalid int
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("stored %d, duplicates %d, conflicts %d, invalid %d, resumed %d", s.Stored, s.Duplicates, s.Conflicts, s.Invalid, s.Resumed)
+}
+
+type job struct {
+	seq  int
+	data storage.StoredData
+}
+
+type result struct {
+	seq       int
+	stored    bool
+	duplicate bool
+	invalid   bool
+	err       error
+
+	// conflict is set if the data point conflicts with a stored one.
+	conflict *conflict
+}
+
+type conflict struct {
+	existing storage.StoredData
+	imported storage.StoredData
+}
+
+// Import decodes the records and stores them. Invalid records are reported
+// and skipped. The import is aborted if the data can't be stored.
+func Import(s storage.Storage, decoder formats.Decoder, options Options) (Summary, error) {
+	if options.Workers <= 0 {
+		options.Workers = DefaultWorkers
+	}
+	if options.ProgressInterval <= 0 {
+		options.ProgressInterval = DefaultProgressInterval
+	}
+
+	checkpoint, err := readCheckpoint(options.Checkpoint)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	c := newCollector(checkpoint, options)
+	go c.run()
+
+	var wg sync.WaitGroup
+	queues := make([]chan job, options.Workers)
+	for i := range queues {
+		queues[i] = make(chan job, queueSize)
+		wg.Add(1)
+		go func(queue <-chan job) {
+			defer wg.Done()
+			work(s, queue, c, options)
+		}(queues[i])
+	}
+
+	readErr := read(decoder, queues, c, checkpoint, options)
+
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+	summary, err := c.close()
+
+	if readErr != nil {
+		return summary, readErr
+	}
+	if err != nil {
+		return summary, err
+	}
+	return summary, removeCheckpoint(options.Checkpoint)
+}
+
+// read decodes the records and distributes them among the workers. The
+// records are numbered starting from 1.
+func read(decoder formats.Decoder, queues []chan job, c *collector, checkpoint int, options Options) error {
+	for seq := 1; ; seq++ {
+		data, err := decoder.Decode()
+		if err == io.EOF {
+			return nil
+		}
+
+		if recordErr, ok := err.(*formats.RecordError); ok {
+			if seq <= checkpoint {
+				continue
+			}
+			if options.InvalidRecord != nil {
+				options.InvalidRecord(recordErr)
+			}
+			c.results <- result{seq: seq, invalid: true}
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if seq <= checkpoint {
+			c.addResumed()
+			continue
+		}
+
+		select {
+		case queues[worker(*data.Data.Icao, len(queues))] <- job{seq, data}:
+		case <-c.stop:
+			return nil
+		}
+	}
+}
+
+// work stores the queued data points in batches. The data points which are
+// already queued are added to the batch without waiting for more of them.
+func work(s storage.Storage, queue <-chan job, c *collector, options Options) {
+	for j := range queue {
+		batch := []job{j}
+	drain:
+		for len(batch) < batchSize {
+			select {
+			case j, ok := <-queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, j)
+			default:
+				break drain
+			}
+		}
+
+		select {
+		case <-c.stop:
+			continue
+		default:
+		}
+
+		for _, r := range storeBatch(s, batch, options) {
+			c.results <- r
+		}
+	}
+}
+
+// storeBatch stores the data points in a single batch. A single result with
+// an error is returned if the batch couldn't be stored.
+func storeBatch(s storage.Storage, batch []job, options Options) []result {
+	var results []result
+	var toStore []storage.StoredData
+	inBatch := make(map[string]storage.StoredData)
+	for _, j := range batch {
+		if j.data.Data.Station == nil && options.Station != "" {
+			station := options.Station
+			j.data.Data.Station = &station
+		}
+
+		r := result{seq: j.seq}
+		key := fmt.Sprintf("%d%s", j.data.Time.UnixNano(), *j.data.Data.Icao)
+		existing, ok := inBatch[key]
+		if !ok {
+			stored, err := s.RetrievePoint(*j.data.Data.Icao, j.data.Time)
+			if err != nil {
+				return []result{{seq: j.seq, err: err}}
+			}
+			if stored != nil {
+				existing = *stored
+				ok = true
+			}
+		}
+		if ok {
+			if len(storage.DifferingFields(existing.Data, j.data.Data)) == 0 {
+				r.duplicate = true
+			} else {
+				r.conflict = &conflict{existing: existing, imported: j.data}
+			}
+		}
+		if !ok || options.Overwrite {
+			r.stored = true
+			toStore = append(toStore, j.data)
+			inBatch[key] = j.data
+		}
+		results = append(results, r)
+	}
+
+	if len(toStore) > 0 {
+		if err := s.StoreBatch(toStore); err != nil {
+			return []result{{seq: batch[0].seq, err: err}}
+		}
+	}
+	return results
+}
+
+// worker returns the index of the worker which stores the data points of the
+// plane.
+func worker(icao string, workers int) int {
+	h := fnv.New32a()
+	h.Write([]byte(icao))
+	return int(h.Sum32() % uint32(workers))
+}
+
+// collector gathers the results and saves the checkpoints.
+type collector struct {
+	results chan result
+	stop    chan struct{}
+	done    chan struct{}
+	options Options
+
+	mutex   sync.Mutex
+	summary Summary
+	err     error
+
+	// processed is the number of records which were processed without
+	// any gaps.
+	processed int
+
+	// pending contains the records processed out of order.
+	pending map[int]bool
+}
+
+func newCollector(checkpoint int, options Options) *collector {
+	return &collector{
+		results:   make(chan result, options.Workers*queueSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		options:   options,
+		processed: checkpoint,
+		pending:   make(map[int]bool),
+	}
+}
+
+func (c *collector) run() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(c.options.ProgressInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case r, ok := <-c.results:
+			if !ok {
+				return
+			}
+			c.add(r)
+		case <-ticker.C:
+			c.tick()
+		}
+	}
+}
+
+func (c *collector) add(r result) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if r.err != nil {
+		c.fail(r.err)
+		return
+	}
+
+	if r.invalid {
+		c.summary.Invalid++
+	}
+	if r.stored {
+		c.summary.Stored++
+	}
+	if r.duplicate {
+		c.summary.Duplicates++
+	}
+	if r.conflict != nil {
+		c.summary.Conflicts++
+		if c.options.Conflict != nil {
+			c.options.Conflict(r.conflict.existing, r.conflict.imported)
+		}
+	}
+
+	c.pending[r.seq] = true
+	for c.pending[c.processed+1] {
+		delete(c.pending, c.processed+1)
+		c.processed++
+	}
+}
+
+func (c *collector) addResumed() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.summary.Resumed++
+}
+
+func (c *collector) tick() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.err == nil {
+		if err := writeCheckpoint(c.options.Checkpoint, c.processed); err != nil {
+			c.fail(err)
+		}
+	}
+	if c.options.Progress != nil {
+		c.options.Progress(c.summary)
+	}
+}
+
+// fail records the first error and stops the import.
+func (c *collector) fail(err error) {
+	if c.err == nil {
+		c.err = err
+		close(c.stop)
+	}
+}
+
+// close waits for the remaining results and saves the last checkpoint.
+func (c *collector) close() (Summary, error) {
+	close(c.results)
+	<-c.done
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := writeCheckpoint(c.options.Checkpoint, c.processed); err != nil && c.err == nil {
+		c.err = err
+	}
+	return c.summary, c.err
+}
+
+func readCheckpoint(path string) (int, error) {
+	if path == "" {
+		return 0, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, fmt.Errorf("Invalid checkpoint file %s: %s", path, err)
+	}
+	return n, nil
+}
+
+// writeCheckpoint replaces the checkpoint file atomically.
+func writeCheckpoint(path string, processed int) error {
+	if path == "" {
+		return nil
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, []byte(strconv.Itoa(processed)+"\n"), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func removeCheckpoint(path string) error {
+	if path == "" {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package importer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/boreq/flightradar-backend/formats"
+	"github.com/boreq/flightradar-backend/storage"
+	"github.com/boreq/flightradar-backend/storage/memory"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func createInput(lines ...string) formats.Decoder {
+	d, _ := formats.NewDecoder("jsonl", bytes.NewBufferString(strings.Join(lines, "\n")))
+	return d
+}
+
+func createLine(icao string, t int64, altitude int) string {
+	return fmt.Sprintf(`{"data":{"icao":"%s","altitude":%d},"time":"%s"}`, icao, altitude, time.Unix(t, 0).UTC().Format(time.RFC3339))
+}
+
+func TestImport(t *testing.T) {
+	s := memory.New(0, storage.Position{})
+
+	var invalid []int
+	options := Options{
+		InvalidRecord: func(err *formats.RecordError) {
+			invalid = append(invalid, err.Line)
+		},
+	}
+	summary, err := Import(s, createInput(
+		createLine("aaaaaa", 1, 1000),
+		"invalid",
+		createLine("bbbbbb", 2, 1000),
+		createLine("aaaaaa", 3, 1000),
+	), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if summary != (Summary{Stored: 3, Invalid: 1}) {
+		t.Errorf("Invalid summary %+v", summary)
+	}
+	if len(invalid) != 1 || invalid[0] != 2 {
+		t.Errorf("Invalid lines %v", invalid)
+	}
+	data, err := s.RetrieveAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 3 {
+		t.Errorf("Invalid number of stored data points %d", len(data))
+	}
+}
+
+func TestImportDuplicates(t *testing.T) {
+	for _, overwrite := range []bool{false, true} {
+		t.Run(fmt.Sprintf("overwrite=%t", overwrite), func(t *testing.T) {
+			s := memory.New(0, storage.Position{})
+			if _, err := Import(s, createInput(createLine("aaaaaa", 1, 1000)), Options{}); err != nil {
+				t.Fatal(err)
+			}
+
+			var conflicts []storage.StoredData
+			options := Options{
+				Overwrite: overwrite,
+				Conflict: func(existing, imported storage.StoredData) {
+					conflicts = append(conflicts, existing)
+				},
+			}
+			summary, err := Import(s, createInput(
+				createLine("aaaaaa", 1, 2000),
+				createLine("aaaaaa", 2, 2000),
+				createLine("aaaaaa", 2, 2000),
+			), options)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			expectedStored := 1
+			expectedAltitude := 1000
+			if overwrite {
+				expectedStored = 3
+				expectedAltitude = 2000
+			}
+			if summary != (Summary{Stored: expectedStored, Duplicates: 1, Conflicts: 1}) {
+				t.Errorf("Invalid summary %+v", summary)
+			}
+			if len(conflicts) != 1 || *conflicts[0].Data.Altitude != 1000 {
+				t.Errorf("Invalid conflicts %v", conflicts)
+			}
+			data, err := s.Retrieve("aaaaaa")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *data[0].Data.Altitude != expectedAltitude {
+				t.Errorf("Invalid altitude %d", *data[0].Data.Altitude)
+			}
+		})
+	}
+}
+
+func TestImportStation(t *testing.T) {
+	s := memory.New(0, storage.Position{})
+
+	_, err := Import(s, createInput(
+		createLine("aaaaaa", 1, 1000),
+		`{"data":{"icao":"aaaaaa","station":"other"},"time":"1970-01-01T00:00:02Z"}`,
+	), Options{Station: "station"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := s.Retrieve("aaaaaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 || *data[0].Data.Station != "station" || *data[1].Data.Station != "other" {
+		t.Fatalf("Invalid data %+v", data)
+	}
+}
+
+type failingStorage struct {
+	storage.Storage
+	icao string
+}
+
+func (f failingStorage) StoreBatch(data []storage.StoredData) error {
+	for _, d := range data {
+		if *d.Data.Icao == f.icao {
+			return errors.New("failed")
+		}
+	}
+	return f.Storage.StoreBatch(data)
+}
+
+func TestImportCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "importer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	checkpoint := filepath.Join(dir, "checkpoint")
+
+	lines := []string{
+		createLine("aaaaaa", 1, 1000),
+		createLine("aaaaaa", 2, 1000),
+		createLine("bbbbbb", 3, 1000),
+		createLine("aaaaaa", 4, 1000),
+	}
+
+	s := memory.New(0, storage.Position{})
+	options := Options{Workers: 1, Checkpoint: checkpoint}
+	if _, err := Import(failingStorage{s, "bbbbbb"}, createInput(lines...), options); err == nil {
+		t.Fatal("Import should fail")
+	}
+
+	b, err := ioutil.ReadFile(checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The batch containing the failing record isn't stored so only the
+	// records preceding it may be marked as processed.
+	processed, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil || processed > 2 {
+		t.Fatalf("Invalid checkpoint %q", string(b))
+	}
+
+	summary, err := Import(s, createInput(lines...), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The last record may have been stored before the first import was
+	// stopped.
+	if summary.Resumed != processed || summary.Stored+summary.Duplicates != len(lines)-processed {
+		t.Errorf("Invalid summary %+v", summary)
+	}
+	data, err := s.RetrieveAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != len(lines) {
+		t.Errorf("Invalid number of stored data points %d", len(data))
+	}
+	if _, err := os.Stat(checkpoint); !os.IsNotExist(err) {
+		t.Errorf("Checkpoint should be removed %v", err)
+	}
+}
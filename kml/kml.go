@@ -0,0 +1,319 @@
+// Package kml encodes the tracks of the planes as KML and KMZ documents which
+// can be viewed in Google Earth.
+package kml
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"github.com/boreq/flightradar-backend/storage"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContentType is the media type of KML documents.
+const ContentType = "application/vnd.google-earth.kml+xml"
+
+// KMZContentType is the media type of KMZ archives.
+const KMZContentType = "application/vnd.google-earth.kmz"
+
+// AltitudeStyleStep is the size of the altitude bands in feet. Each band is
+// drawn using a different colour.
+const AltitudeStyleStep = 5000
+
+// AltitudeStyles is the number of altitude bands. The last band doesn't have
+// an upper boundary.
+const AltitudeStyles = 9
+
+const feetToMeters = 0.3048
+
+const namespace = "http://www.opengis.net/kml/2.2"
+
+type style struct {
+	XMLName xml.Name `xml:"Style"`
+	Id      string   `xml:"id,attr"`
+	Color   string   `xml:"LineStyle>color"`
+	Width   int      `xml:"LineStyle>width"`
+}
+
+type folder struct {
+	XMLName     xml.Name    `xml:"Folder"`
+	Name        string      `xml:"name"`
+	Description string      `xml:"description"`
+	Placemarks  []placemark `xml:"Placemark"`
+}
+
+type placemark struct {
+	StyleUrl   string    `xml:"styleUrl"`
+	LineString *geometry `xml:"LineString,omitempty"`
+	Point      *geometry `xml:"Point,omitempty"`
+}
+
+type geometry struct {
+	AltitudeMode string `xml:"altitudeMode"`
+	Coordinates  string `xml:"coordinates"`
+}
+
+type position struct {
+	Longitude float64
+	Latitude  float64
+	Altitude  int
+}
+
+// Encoder writes a KML document track by track.
+type Encoder struct {
+	encoder *xml.Encoder
+	w       io.Writer
+	archive *zip.Writer
+	name    string
+	started bool
+}
+
+// NewEncoder creates an encoder which writes a KML document with the given
+// name to w. Close has to be called in order to complete the document.
+func NewEncoder(w io.Writer, name string) *Encoder {
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	return &Encoder{
+		encoder: encoder,
+		w:       w,
+		name:    name,
+	}
+}
+
+// NewKMZEncoder creates an encoder which writes a KMZ archive containing
+// the KML document to w.
+func NewKMZEncoder(w io.Writer, name string) (*Encoder, error) {
+	archive := zip.NewWriter(w)
+	f, err := archive.Create("doc.kml")
+	if err != nil {
+		return nil, err
+	}
+	rv := NewEncoder(f, name)
+	rv.archive = archive
+	return rv, nil
+}
+
+func (e *Encoder) start() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	if _, err := io.WriteString(e.w, xml.Header); err != nil {
+		return err
+	}
+	err := e.encoder.EncodeToken(xml.StartElement{
+		Name: xml.Name{Local: "kml"},
+		Attr: []xml.Attr{{Name: xml.Name{Local: "xmlns"}, Value: namespace}},
+	})
+	if err != nil {
+		return err
+	}
+	if err := e.encoder.EncodeToken(xml.StartElement{Name: xml.Name{Local: "Document"}}); err != nil {
+		return err
+	}
+	if err := e.encoder.EncodeElement(e.name, xml.StartElement{Name: xml.Name{Local: "name"}}); err != nil {
+		return err
+	}
+	for _, s := range styles() {
+		if err := e.encoder.Encode(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// EncodeTrack writes a folder containing the track of a single plane. The
+// track is drawn at its actual altitude and coloured according to the
+// altitude band. Data points without a position are skipped, data points
+// without an altitude use the last known altitude of the plane.
+func (e *Encoder) EncodeTrack(track []storage.StoredData) error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	f, ok := trackFolder(track)
+	if !ok {
+		return nil
+	}
+	return e.encoder.Encode(f)
+}
+
+// Close completes the document. It doesn't close the underlying writer.
+func (e *Encoder) Close() error {
+	if err := e.start(); err != nil {
+		return err
+	}
+	for _, name := range []string{"Document", "kml"} {
+		if err := e.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}}); err != nil {
+			return err
+		}
+	}
+	if err := e.encoder.Flush(); err != nil {
+		return err
+	}
+	if e.archive != nil {
+		return e.archive.Close()
+	}
+	return nil
+}
+
+// Write splits the data points into tracks and writes a KML document
+// containing a folder per track.
+func Write(w io.Writer, name string, data []storage.StoredData) error {
+	return encodeTracks(NewEncoder(w, name), data)
+}
+
+// WriteKMZ writes a KMZ archive containing the document created by Write.
+func WriteKMZ(w io.Writer, name string, data []storage.StoredData) error {
+	e, err := NewKMZEncoder(w, name)
+	if err != nil {
+		return err
+	}
+	return encodeTracks(e, data)
+}
+
+func encodeTracks(e *Encoder, data []storage.StoredData) error {
+	for _, track := range storage.SplitTracks(data) {
+		if err := e.EncodeTrack(track); err != nil {
+			return err
+		}
+	}
+	return e.Close()
+}
+
+// AltitudeStyle returns the index of the style used for the given altitude.
+func AltitudeStyle(altitude int) int {
+	if altitude < 0 {
+		return 0
+	}
+	return int(math.Min(float64(altitude/AltitudeStyleStep), AltitudeStyles-1))
+}
+
+func styleId(i int) string {
+	return fmt.Sprintf("altitude-%d", i)
+}
+
+// styles returns the styles of the altitude bands. The colours range from
+// red for the lowest band to violet for the highest one.
+func styles() []style {
+	var rv []style
+	for i := 0; i < AltitudeStyles; i++ {
+		hue := 270 * float64(i) / float64(AltitudeStyles-1)
+		rv = append(rv, style{
+			Id:    styleId(i),
+			Color: hueToColor(hue),
+			Width: 3,
+		})
+	}
+	return rv
+}
+
+// hueToColor returns a fully saturated and opaque colour in the aabbggrr
+// format used by KML.
+func hueToColor(hue float64) string {
+	x := 1 - math.Abs(math.Mod(hue/60, 2)-1)
+	var r, g, b float64
+	switch {
+	case hue < 60:
+		r, g, b = 1, x, 0
+	case hue < 120:
+		r, g, b = x, 1, 0
+	case hue < 180:
+		r, g, b = 0, 1, x
+	case hue < 240:
+		r, g, b = 0, x, 1
+	case hue < 300:
+		r, g, b = x, 0, 1
+	default:
+		r, g, b = 1, 0, x
+	}
+	return fmt.Sprintf("ff%02x%02x%02x", int(b*255), int(g*255), int(r*255))
+}
+
+// trackFolder returns a folder with the placemarks of a single track. The
+// track is split into segments drawn using the style of their altitude band.
+// False is returned if the track doesn't contain any positions with a known
+// altitude.
+func trackFolder(track []storage.StoredData) (folder, bool) {
+	var positions []position
+	var altitude *int
+	var icao, flightNumber string
+	var from, to time.Time
+	for _, d := range track {
+		if d.Data.Icao != nil {
+			icao = *d.Data.Icao
+		}
+		if d.Data.FlightNumber != nil {
+			flightNumber = *d.Data.FlightNumber
+		}
+		if d.Data.Altitude != nil {
+			altitude = d.Data.Altitude
+		}
+		if d.Data.Latitude == nil || d.Data.Longitude == nil || altitude == nil {
+			continue
+		}
+		if len(positions) == 0 {
+			from = d.Time
+		}
+		to = d.Time
+		positions = append(positions, position{*d.Data.Longitude, *d.Data.Latitude, *altitude})
+	}
+	if len(positions) == 0 {
+		return folder{}, false
+	}
+
+	rv := folder{
+		Name:        strings.TrimSpace(icao + " " + flightNumber),
+		Description: fmt.Sprintf("%s - %s", from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339)),
+	}
+
+	if len(positions) == 1 {
+		rv.Placemarks = append(rv.Placemarks, placemark{
+			StyleUrl: "#" + styleId(AltitudeStyle(positions[0].Altitude)),
+			Point:    newGeometry(positions),
+		})
+		return rv, true
+	}
+
+	// Each segment starts with the last position of the previous segment
+	// so that the track is continuous.
+	segmentStyle := AltitudeStyle(positions[0].Altitude)
+	segment := []position{positions[0]}
+	for _, p := range positions[1:] {
+		segment = append(segment, p)
+		if s := AltitudeStyle(p.Altitude); s != segmentStyle {
+			rv.Placemarks = append(rv.Placemarks, newLineString(segmentStyle, segment))
+			segmentStyle = s
+			segment = []position{p}
+		}
+	}
+	if len(segment) > 1 {
+		rv.Placemarks = append(rv.Placemarks, newLineString(segmentStyle, segment))
+	}
+	return rv, true
+}
+
+func newLineString(style int, positions []position) placemark {
+	return placemark{
+		StyleUrl:   "#" + styleId(style),
+		LineString: newGeometry(positions),
+	}
+}
+
+func newGeometry(positions []position) *geometry {
+	var coordinates []string
+	for _, p := range positions {
+		coordinates = append(coordinates, strings.Join([]string{
+			strconv.FormatFloat(p.Longitude, 'f', -1, 64),
+			strconv.FormatFloat(p.Latitude, 'f', -1, 64),
+			strconv.FormatFloat(math.Round(float64(p.Altitude)*feetToMeters), 'f', -1, 64),
+		}, ","))
+	}
+	return &geometry{
+		AltitudeMode: "absolute",
+		Coordinates:  strings.Join(coordinates, " "),
+	}
+}
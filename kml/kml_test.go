@@ -0,0 +1,111 @@
+package kml
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"github.com/boreq/flightradar-backend/storage"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func createData(t int64, altitude int, lat, lon float64) storage.StoredData {
+	icao := "aaaaaa"
+	return storage.StoredData{
+		Time: time.Unix(t, 0),
+		Data: storage.Data{
+			Icao:      &icao,
+			Altitude:  &altitude,
+			Latitude:  &lat,
+			Longitude: &lon,
+		},
+	}
+}
+
+func TestAltitudeStyle(t *testing.T) {
+	testCases := []struct {
+		Altitude int
+		Style    int
+	}{
+		{-100, 0},
+		{0, 0},
+		{4999, 0},
+		{5000, 1},
+		{39999, 7},
+		{40000, 8},
+		{60000, 8},
+	}
+
+	for _, testCase := range testCases {
+		if s := AltitudeStyle(testCase.Altitude); s != testCase.Style {
+			t.Errorf("Invalid style for %d: %d", testCase.Altitude, s)
+		}
+	}
+}
+
+func TestTrackFolder(t *testing.T) {
+	track := []storage.StoredData{
+		createData(0, 1000, 50, 20),
+		createData(10, 2000, 50.1, 20.1),
+		createData(20, 6000, 50.2, 20.2),
+		createData(30, 7000, 50.3, 20.3),
+	}
+
+	f, ok := trackFolder(track)
+	if !ok {
+		t.Fatal("Folder should be created")
+	}
+	if len(f.Placemarks) != 2 {
+		t.Fatalf("Invalid number of placemarks %d", len(f.Placemarks))
+	}
+	if f.Placemarks[0].StyleUrl != "#altitude-0" || f.Placemarks[1].StyleUrl != "#altitude-1" {
+		t.Errorf("Invalid styles %s %s", f.Placemarks[0].StyleUrl, f.Placemarks[1].StyleUrl)
+	}
+	if f.Placemarks[0].LineString.Coordinates != "20,50,305 20.1,50.1,610 20.2,50.2,1829" {
+		t.Errorf("Invalid coordinates %s", f.Placemarks[0].LineString.Coordinates)
+	}
+	if f.Placemarks[1].LineString.AltitudeMode != "absolute" {
+		t.Errorf("Invalid altitude mode %s", f.Placemarks[1].LineString.AltitudeMode)
+	}
+}
+
+func TestWriteKMZ(t *testing.T) {
+	data := []storage.StoredData{
+		createData(0, 1000, 50, 20),
+		createData(10, 2000, 50.1, 20.1),
+	}
+
+	buf := &bytes.Buffer{}
+	if err := WriteKMZ(buf, "name", data); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(archive.File) != 1 || archive.File[0].Name != "doc.kml" {
+		t.Fatal("Invalid archive")
+	}
+	f, err := archive.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Name    string   `xml:"Document>name"`
+		Styles  []style  `xml:"Document>Style"`
+		Folders []folder `xml:"Document>Folder"`
+	}
+	if err := xml.Unmarshal(b, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Name != "name" || len(doc.Styles) != AltitudeStyles || len(doc.Folders) != 1 {
+		t.Fatalf("Invalid document %+v", doc)
+	}
+}
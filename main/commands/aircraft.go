@@ -0,0 +1,66 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/boreq/flightradar-backend/config"
+	"github.com/boreq/flightradar-backend/metadata"
+	"github.com/boreq/guinea"
+)
+
+var aircraftCmd = guinea.Command{
+	Subcommands: map[string]*guinea.Command{
+		"update": &aircraftUpdateCmd,
+	},
+	ShortDescription: "manages the aircraft database",
+}
+
+var aircraftUpdateCmd = guinea.Command{
+	Run: runAircraftUpdate,
+	Arguments: []guinea.Argument{
+		{Name: "config", Description: "Config file"},
+		{Name: "source", Description: "Source file"},
+	},
+	Options: []guinea.Option{
+		guinea.Option{
+			Name:        "format",
+			Type:        guinea.String,
+			Description: "Input format: csv or json, detected using the file extension by default",
+		},
+	},
+	ShortDescription: "replaces the aircraft database with the aircraft from a file",
+	Description: `
+This command replaces the configured aircraft database with the aircraft read
+from a CSV or JSON file. The CSV files must start with a header. The JSON files
+must contain an array of objects, an object with the ICAO addresses as the keys
+or one object per line. The common names of the columns and keys are
+recognized eg. "icao24", "registration", "typecode" and "operator" used by the
+OpenSky Network database or "r", "t", "reg", "icaotype" and "ownop" used by
+the databases distributed for readsb and tar1090. Records without a valid ICAO
+address are skipped. The files with the .json, .ndjson and .jsonl extensions
+are read as JSON.
+
+The running program loads the updated database when it receives SIGHUP.
+`,
+}
+
+func runAircraftUpdate(c guinea.Context) error {
+	if err := config.Load(c.Arguments[0]); err != nil {
+		return err
+	}
+	if config.Config.AircraftDatabaseFile == "" {
+		return fmt.Errorf("The aircraft database file isn't configured")
+	}
+
+	source := c.Arguments[1]
+	format := c.Options["format"].Str()
+	if format == "" {
+		format = metadata.DetectFormat(source)
+	}
+
+	summary, err := metadata.Update(source, format, config.Config.AircraftDatabaseFile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Saved %d aircraft, skipped %d invalid records\n", summary.Aircraft, summary.Invalid)
+	return nil
+}
@@ -0,0 +1,77 @@
+package commands
+
+import (
+	"github.com/boreq/flightradar-backend/backup"
+	"github.com/boreq/flightradar-backend/config"
+	"github.com/boreq/flightradar-backend/storage/bolt"
+	"github.com/boreq/guinea"
+	"net"
+	"os"
+)
+
+var backupCmd = guinea.Command{
+	Run: runBackup,
+	Arguments: []guinea.Argument{
+		{Name: "config", Description: "Config file"},
+		{Name: "destination", Description: "Destination file, - writes to stdout"},
+	},
+	Options: []guinea.Option{
+		guinea.Option{
+			Name:        "gzip",
+			Type:        guinea.Bool,
+			Description: "Compress the backup using gzip",
+		},
+		guinea.Option{
+			Name:        "remote",
+			Type:        guinea.Bool,
+			Description: "Download the backup from the running program using the /admin/backup endpoint",
+		},
+	},
+	ShortDescription: "creates a consistent backup of the database",
+	Description: `
+This command copies the bolt database within a read transaction so the backup is
+consistent. The database is locked while the program is running so in that case
+the remote option has to be used. The backup is then downloaded from the server
+listening on the configured address using the configured admin token.
+`,
+}
+
+func runBackup(c guinea.Context) error {
+	if err := config.Load(c.Arguments[0]); err != nil {
+		return err
+	}
+
+	destination := c.Arguments[1]
+	compress := c.Options["gzip"].Bool()
+
+	if c.Options["remote"].Bool() {
+		return downloadBackup(destination, compress)
+	}
+
+	b, err := bolt.NewReadOnly(config.Config.DatabaseFile)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	if destination == "-" {
+		return backup.Write(os.Stdout, b, compress)
+	}
+	return backup.WriteFile(b, destination, compress)
+}
+
+func downloadBackup(destination string, compress bool) error {
+	host, port, err := net.SplitHostPort(config.Config.ServeAddress)
+	if err != nil {
+		return err
+	}
+	if host == "" {
+		host = "localhost"
+	}
+	address := net.JoinHostPort(host, port)
+
+	if destination == "-" {
+		return backup.Download(os.Stdout, address, config.Config.AdminToken, compress)
+	}
+	return backup.DownloadFile(destination, address, config.Config.AdminToken, compress)
+}
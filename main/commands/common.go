@@ -1,9 +1,12 @@
 package commands
 
 import (
+	"fmt"
 	"github.com/boreq/flightradar-backend/config"
 	"github.com/boreq/flightradar-backend/storage"
 	"github.com/boreq/flightradar-backend/storage/bolt"
+	"github.com/boreq/flightradar-backend/storage/memory"
+	"time"
 )
 
 func initialize(configFilename string) (storage.Storage, error) {
@@ -11,5 +14,39 @@ func initialize(configFilename string) (storage.Storage, error) {
 		return nil, err
 	}
 
-	return bolt.New(config.Config.DatabaseFile)
+	switch config.Config.StorageBackend {
+	case "bolt":
+		options, err := boltOptions()
+		if err != nil {
+			return nil, err
+		}
+		return bolt.New(config.Config.DatabaseFile, options)
+	case "memory":
+		return memory.New(config.Config.MemoryStorageMaxSize, stationPosition()), nil
+	default:
+		return nil, fmt.Errorf("Unknown storage backend: %s", config.Config.StorageBackend)
+	}
+}
+
+// stationPosition returns the configured position of the station and the
+// range of its receiver.
+func stationPosition() storage.Position {
+	return storage.Position{
+		Latitude:  config.Config.StationLatitude,
+		Longitude: config.Config.StationLongitude,
+		Range:     config.Config.ReceiverRange,
+	}
+}
+
+// boltOptions returns the options of the bolt storage backend created using
+// the config.
+func boltOptions() (bolt.Options, error) {
+	location, err := time.LoadLocation(config.Config.Timezone)
+	if err != nil {
+		return bolt.Options{}, err
+	}
+	return bolt.Options{
+		Station:  stationPosition(),
+		Location: location,
+	}, nil
 }
@@ -0,0 +1,116 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/boreq/flightradar-backend/config"
+	"github.com/boreq/flightradar-backend/storage/bolt"
+	"github.com/boreq/guinea"
+	"os"
+	"time"
+)
+
+var dbCmd = guinea.Command{
+	Subcommands: map[string]*guinea.Command{
+		"info":  &dbInfoCmd,
+		"check": &dbCheckCmd,
+	},
+	ShortDescription: "inspects the database",
+}
+
+var dbInfoCmd = guinea.Command{
+	Run: runDbInfo,
+	Arguments: []guinea.Argument{
+		{Name: "config", Description: "Config file"},
+	},
+	ShortDescription: "displays information about the database",
+	Description: `
+This command displays the schema version, the number of stored data points and
+planes, the time span of the data points and the sizes of the buckets. The
+database is opened in read-only mode.
+`,
+}
+
+var dbCheckCmd = guinea.Command{
+	Run: runDbCheck,
+	Arguments: []guinea.Argument{
+		{Name: "config", Description: "Config file"},
+	},
+	Options: []guinea.Option{
+		guinea.Option{
+			Name:        "repair",
+			Type:        guinea.Bool,
+			Description: "Repair the found problems",
+		},
+	},
+	ShortDescription: "checks the consistency of the database",
+	Description: `
+This command verifies that all records can be decoded and that each data point
+is present both in the general bucket and in the bucket of its plane. The
+indexes are checked for missing and dangling entries. The database is opened in
+read-only mode unless the repair option is used. Undecodable records are
+removed when repairing. The program can't be running when the database is being
+repaired and the database must be migrated beforehand.
+`,
+}
+
+func runDbInfo(c guinea.Context) error {
+	if err := config.Load(c.Arguments[0]); err != nil {
+		return err
+	}
+
+	info, err := bolt.GetInfo(config.Config.DatabaseFile)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("File size: %s\n", formatBytes(info.FileSize))
+	fmt.Printf("Schema version: %d (pending migrations: %d)\n", info.SchemaVersion, info.PendingMigrations)
+	fmt.Printf("Data points: %d\n", info.DataPoints)
+	fmt.Printf("Planes: %d\n", info.Planes)
+	if info.DataPoints > 0 {
+		fmt.Printf("Time span: %s - %s (%s)\n", info.From.Format(time.RFC3339), info.To.Format(time.RFC3339), info.To.Sub(info.From).Round(time.Second))
+	}
+	fmt.Println("Buckets:")
+	for _, bucket := range info.Buckets {
+		fmt.Printf("  %s: %d keys, %s\n", bucket.Name, bucket.Keys, formatBytes(int64(bucket.Size)))
+	}
+	return nil
+}
+
+func runDbCheck(c guinea.Context) error {
+	if err := config.Load(c.Arguments[0]); err != nil {
+		return err
+	}
+
+	repair := c.Options["repair"].Bool()
+	summary, err := bolt.Check(config.Config.DatabaseFile, repair, func(p bolt.Problem) {
+		fmt.Fprintf(os.Stderr, "Problem: %s\n", p)
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Checked %d data points, found %d problems\n", summary.DataPoints, summary.Problems)
+	if summary.Problems > 0 {
+		if repair {
+			fmt.Println("The problems were repaired")
+			return nil
+		}
+		return fmt.Errorf("The database is inconsistent, run the command with the repair option to fix it")
+	}
+	return nil
+}
+
+// formatBytes formats the size using binary units.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -32,6 +32,101 @@ DatabaseURI
 ServeAddress
 	The server will listen on this address.
 	Allowed values: an address as defined by the Go standard library eg. ":8080".
+
+StorageBackend
+	Specifies where the collected data is stored. The memory backend doesn't
+	preserve the data between restarts.
+	Allowed values: "bolt" or "memory".
+
+DatabaseFile
+	Path to the database file used by the bolt storage backend.
+
+MemoryStorageMaxSize
+	Maximum number of data points held by the memory storage backend. The
+	oldest data points are removed when this number is exceeded together
+	with the statistics for the hours in which they were recorded.
+	Allowed values: a number, 0 disables the limit.
+
+StatsHistoryDays
+	Number of days for which the statistics are returned by the API if the
+	time range isn't specified. The statistics are stored permanently and
+	updated as the data is recorded so this number can be increased at any
+	time.
+
+Timezone
+	Time zone used to group the statistics into days, hours etc. It can be
+	overridden using the tz parameter of the statistics endpoints. The
+	statistics are stored for hourly periods so the offset of the time zone
+	must be expressed in full hours.
+	Allowed values: an IANA time zone name eg. "Europe/Warsaw".
+
+AdminToken
+	Token which has to be sent in the Authorization header as "Bearer <token>"
+	to access the administrative endpoints such as /admin/backup. The
+	administrative endpoints are disabled if the token is empty.
+
+SnapshotDirectory
+	Directory in which the snapshots of the bolt database are periodically
+	saved while the program is running. An empty string disables the
+	snapshots.
+
+SnapshotInterval
+	Number of hours between the snapshots.
+
+SnapshotKeep
+	Number of the newest snapshots which are kept, the older snapshots are
+	removed. Allowed values: a number, 0 keeps all snapshots.
+
+SnapshotGzip
+	Specifies if the snapshots are compressed using gzip.
+	Allowed values: true or false.
+
+AircraftDatabaseFile
+	Path to the aircraft database created by the "aircraft update" command.
+	It is used to add the registration, type and operator of the aircraft to
+	the API responses. The database is reloaded when the program receives
+	SIGHUP. An empty string disables the aircraft database.
+
+AirlinesFile
+	Path to a CSV file with the airlines used to add the airline to the API
+	responses. The file must have a header with the "icao" column and
+	optionally the "iata", "name" and "country" columns. The airlines.dat
+	file distributed by OpenFlights can be used as well. The file is
+	reloaded when the program receives SIGHUP. An empty string disables the
+	airline lookup, the ICAO designator of the airline is still returned.
+
+RoutesFile
+	Path to a CSV file with the routes used to add the origin and the
+	destination of the flights to the API responses. The file must have a
+	header with the "callsign" column and either the "origin" and
+	"destination" columns or the "route" column with the ICAO codes of the
+	airports separated by dashes eg. "EPWA-EGLL". The file is reloaded when
+	the program receives SIGHUP. An empty string disables the routes.
+
+AirportsFile
+	Path to the airports.csv file distributed by OurAirports. The airports
+	located within AirportsRadius from the station are used to detect the
+	departures, arrivals and overflights which are counted in the
+	statistics. An empty string disables the detection.
+
+RunwaysFile
+	Path to the runways.csv file distributed by OurAirports used to detect
+	the runways used by the departing and arriving planes. If it is empty
+	the runway designators are derived from the headings of the planes.
+
+AirportsRadius
+	Distance from the station in kilometers within which the airports are
+	considered when detecting the movements.
+
+ReceiverRange
+	Maximum distance from the station in kilometers at which the planes can
+	be received. Positions located further away are rejected as they are
+	the result of incorrectly decoded or spoofed messages. The positions
+	which imply an impossible speed and the altitudes which imply an
+	impossible rate of climb are always rejected. The number of rejections
+	is recorded in the statistics. The statistics and the coverage are
+	recalculated when the range or the position of the station changes.
+	Zero disables the range check.
 	`,
 }
 
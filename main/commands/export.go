@@ -1,21 +1,76 @@
 package commands
 
 import (
-	"encoding/json"
+	"compress/gzip"
+	"fmt"
+	"github.com/boreq/flightradar-backend/formats"
+	"github.com/boreq/flightradar-backend/storage"
 	"github.com/boreq/guinea"
+	"io"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var exportCmd = guinea.Command{
 	Run: runExport,
 	Arguments: []guinea.Argument{
-		{"config", false, false, "Config file"},
-		{"destination", false, false, "Destination file"},
+		{Name: "config", Description: "Config file"},
+		{Name: "destination", Description: "Destination file"},
+	},
+	Options: []guinea.Option{
+		guinea.Option{
+			Name:        "format",
+			Type:        guinea.String,
+			Default:     "jsonl",
+			Description: fmt.Sprintf("Output format: %s", strings.Join(formats.Names(), ", ")),
+		},
+		guinea.Option{
+			Name:        "from",
+			Type:        guinea.String,
+			Description: "Export data points recorded at or after this Unix timestamp",
+		},
+		guinea.Option{
+			Name:        "to",
+			Type:        guinea.String,
+			Description: "Export data points recorded at or before this Unix timestamp",
+		},
+		guinea.Option{
+			Name:        "icao",
+			Type:        guinea.String,
+			Description: "Export only the data points of the plane with this ICAO",
+		},
+		guinea.Option{
+			Name:        "gzip",
+			Type:        guinea.Bool,
+			Description: "Compress the output using gzip",
+		},
 	},
 	ShortDescription: "exports data to a file",
+	Description: `
+This command exports the data points in chronological order. The data points
+are streamed from the storage so large databases can be exported without
+loading them into memory. The track based formats (geojson, gpx, kml, kmz)
+split the data points into tracks of the individual planes.
+`,
 }
 
 func runExport(c guinea.Context) error {
+	if format := c.Options["format"].Str(); !formats.IsSupported(format) {
+		return fmt.Errorf("Unknown format: %s", format)
+	}
+
+	from, err := optionalTimestamp(c.Options["from"].Str(), time.Unix(0, 0))
+	if err != nil {
+		return err
+	}
+
+	to, err := optionalTimestamp(c.Options["to"].Str(), time.Now())
+	if err != nil {
+		return err
+	}
+
 	storage, err := initialize(c.Arguments[0])
 	if err != nil {
 		return err
@@ -26,25 +81,57 @@ func runExport(c guinea.Context) error {
 		return err
 	}
 
-	data, err := storage.RetrieveAll()
+	if err := export(storage, file, c, from, to); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+func export(s storage.ReadStorage, file io.Writer, c guinea.Context, from, to time.Time) error {
+	w := file
+	var gzipWriter *gzip.Writer
+	if c.Options["gzip"].Bool() {
+		gzipWriter = gzip.NewWriter(file)
+		w = gzipWriter
+	}
+
+	encoder, err := formats.NewEncoder(c.Options["format"].Str(), w)
 	if err != nil {
 		return err
 	}
 
-	for _, d := range data {
-		j, err := json.Marshal(d)
-		if err != nil {
-			return err
-		}
-		j = append(j, []byte("\n")...)
-		if _, err := file.Write(j); err != nil {
-			return err
+	icao := c.Options["icao"].Str()
+	err = s.Iterate(from, to, func(d storage.StoredData) error {
+		if icao != "" && (d.Data.Icao == nil || *d.Data.Icao != icao) {
+			return nil
 		}
+		return encoder.Encode(d)
+	})
+	if err != nil {
+		return err
 	}
 
-	if err := file.Close(); err != nil {
+	if err := encoder.Close(); err != nil {
 		return err
 	}
 
+	if gzipWriter != nil {
+		return gzipWriter.Close()
+	}
 	return nil
 }
+
+// optionalTimestamp parses a Unix timestamp. The default value is returned if
+// the string is empty.
+func optionalTimestamp(s string, defaultValue time.Time) (time.Time, error) {
+	if s == "" {
+		return defaultValue, nil
+	}
+	timestamp, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Invalid timestamp %s: %s", s, err)
+	}
+	return time.Unix(timestamp, 0), nil
+}
@@ -2,58 +2,128 @@ package commands
 
 import (
 	"bufio"
-	"encoding/json"
-	"github.com/boreq/flightradar-backend/storage"
+	"compress/gzip"
+	"fmt"
+	"github.com/boreq/flightradar-backend/formats"
+	"github.com/boreq/flightradar-backend/importer"
 	"github.com/boreq/guinea"
+	"io"
 	"os"
-	"sync"
 )
 
 var importCmd = guinea.Command{
 	Run: runImport,
 	Arguments: []guinea.Argument{
-		{"config", false, false, "Config file"},
-		{"source", false, false, "Source file"},
+		{Name: "config", Description: "Config file"},
+		{Name: "source", Description: "Source file"},
+	},
+	Options: []guinea.Option{
+		guinea.Option{
+			Name:        "format",
+			Type:        guinea.String,
+			Description: "Input format: jsonl or csv, detected using the file extension by default",
+		},
+		guinea.Option{
+			Name:        "gzip",
+			Type:        guinea.Bool,
+			Description: "Decompress the input using gzip, enabled for files with the .gz extension",
+		},
+		guinea.Option{
+			Name:        "overwrite",
+			Type:        guinea.Bool,
+			Description: "Overwrite the data points which are already stored instead of skipping them",
+		},
+		guinea.Option{
+			Name:        "workers",
+			Type:        guinea.Int,
+			Default:     importer.DefaultWorkers,
+			Description: "Number of workers storing the data points",
+		},
+		guinea.Option{
+			Name:        "checkpoint",
+			Type:        guinea.String,
+			Description: "Checkpoint file used to resume an interrupted import, defaults to <source>.checkpoint",
+		},
 	},
 	ShortDescription: "imports data from a file",
+	Description: `
+This command imports data points from a JSON-lines or a CSV file, optionally
+compressed using gzip. The number of processed records is periodically saved
+in a checkpoint file. If the import is interrupted running it again with the
+same checkpoint file resumes it. Invalid records are reported and skipped.
+`,
 }
 
 func runImport(c guinea.Context) error {
+	source := c.Arguments[1]
+	checkpoint := c.Options["checkpoint"].Str()
+	if checkpoint == "" {
+		checkpoint = source + ".checkpoint"
+	}
+
 	st, err := initialize(c.Arguments[0])
 	if err != nil {
 		return err
 	}
 
-	file, err := os.Open(c.Arguments[1])
+	decoder, closeSource, err := openExport(source, c.Options["format"].Str(), c.Options["gzip"].Bool())
 	if err != nil {
 		return err
 	}
+	defer closeSource()
 
-	var wg sync.WaitGroup
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		var storedData storage.StoredData
-		if err := json.Unmarshal(scanner.Bytes(), &storedData); err != nil {
-			return err
-		}
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			if err := st.Store(storedData); err != nil {
-				panic(err)
-			}
-		}()
+	options := importer.Options{
+		Workers:       c.Options["workers"].Int(),
+		Overwrite:     c.Options["overwrite"].Bool(),
+		Checkpoint:    checkpoint,
+		Progress:      printProgress,
+		InvalidRecord: printInvalidRecord,
 	}
 
-	if err := scanner.Err(); err != nil {
-		return err
+	summary, err := importer.Import(st, decoder, options)
+	if err != nil {
+		return fmt.Errorf("Import failed, run the command again to resume it: %s", err)
 	}
+	fmt.Printf("Imported: %s\n", summary)
+	return nil
+}
 
-	if err := file.Close(); err != nil {
-		return err
+// openExport opens a file created by the export command. The format is
+// detected using the file extension unless it is provided.
+func openExport(path string, format string, compressed bool) (formats.Decoder, func() error, error) {
+	detectedFormat, detectedCompressed := formats.DetectFormat(path)
+	if format == "" {
+		format = detectedFormat
 	}
+	compressed = compressed || detectedCompressed
 
-	wg.Wait()
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	return nil
+	var r io.Reader = bufio.NewReader(file)
+	if compressed {
+		gzipReader, err := gzip.NewReader(r)
+		if err != nil {
+			file.Close()
+			return nil, nil, err
+		}
+		r = gzipReader
+	}
+
+	decoder, err := formats.NewDecoder(format, r)
+	if err != nil {
+		file.Close()
+		return nil, nil, err
+	}
+	return decoder, file.Close, nil
+}
+
+func printProgress(summary importer.Summary) {
+	fmt.Fprintf(os.Stderr, "Progress: %s\n", summary)
+}
+
+func printInvalidRecord(err *formats.RecordError) {
+	fmt.Fprintf(os.Stderr, "Invalid record: %s\n", err)
 }
@@ -22,6 +22,11 @@ var MainCmd = guinea.Command{
 		"default_config": &defaultConfigCmd,
 		"export":         &exportCmd,
 		"import":         &importCmd,
+		"migrate":        &migrateCmd,
+		"merge":          &mergeCmd,
+		"db":             &dbCmd,
+		"backup":         &backupCmd,
+		"aircraft":       &aircraftCmd,
 	},
 	ShortDescription: "SDR plane tracking software",
 	Description:      "This software records plane tracking data collected by SDR radios.",
@@ -0,0 +1,314 @@
+package commands
+
+import (
+	"errors"
+	"fmt"
+	"github.com/boreq/flightradar-backend/formats"
+	"github.com/boreq/flightradar-backend/importer"
+	"github.com/boreq/flightradar-backend/storage"
+	"github.com/boreq/flightradar-backend/storage/bolt"
+	"github.com/boreq/guinea"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var mergeCmd = guinea.Command{
+	Run: runMerge,
+	Arguments: []guinea.Argument{
+		{Name: "config", Description: "Config file"},
+		{Name: "source", Multiple: true, Description: "Bolt database or exported file, optionally prefixed with the station name: [station=]path"},
+	},
+	Options: []guinea.Option{
+		guinea.Option{
+			Name:        "overwrite",
+			Type:        guinea.Bool,
+			Description: "Overwrite the conflicting data points instead of keeping the ones which are already stored",
+		},
+	},
+	ShortDescription: "merges data from multiple stations",
+	Description: `
+This command merges bolt databases and files created by the export command into
+the configured storage. Files with the .bolt or .db extension are treated as
+bolt databases. The source databases are opened in read-only mode and are never
+modified. If a source database has pending migrations they are applied to its
+temporary copy which is merged instead. The data points are validated in the
+same way as by the import command and the invalid ones are skipped.
+
+The merged data points are tagged with the name of the station which received
+them. The name of the station can be provided before the path, by default the
+name of the file without the extension is used. Data points which are already
+tagged keep their station.
+
+Data points which have exactly the same time, down to the nanosecond, and ICAO
+as the already stored data points are skipped if their data is identical.
+Otherwise they are reported as conflicts and skipped unless the overwrite
+option is used. The times aren't rounded so the data points received from the
+same plane by multiple stations are usually all stored even if they describe
+the same message.
+`,
+}
+
+// mergeSource is a source of data points from a single station.
+type mergeSource struct {
+	Station string
+	Path    string
+}
+
+func parseMergeSource(s string) mergeSource {
+	if i := strings.Index(s, "="); i > 0 {
+		return mergeSource{Station: s[:i], Path: s[i+1:]}
+	}
+	name := filepath.Base(s)
+	for ext := filepath.Ext(name); ext != ""; ext = filepath.Ext(name) {
+		name = strings.TrimSuffix(name, ext)
+	}
+	return mergeSource{Station: name, Path: s}
+}
+
+// mergeConflicts summarizes the conflicts found when merging a source.
+type mergeConflicts struct {
+	// Fields counts the conflicts in which each field differed.
+	Fields map[string]int
+
+	// Stations counts the conflicts with the data points received by each
+	// station.
+	Stations map[string]int
+}
+
+func newMergeConflicts() *mergeConflicts {
+	return &mergeConflicts{
+		Fields:   make(map[string]int),
+		Stations: make(map[string]int),
+	}
+}
+
+func (m *mergeConflicts) Add(existing, imported storage.StoredData) {
+	for _, field := range storage.DifferingFields(existing.Data, imported.Data) {
+		m.Fields[field]++
+	}
+	station := "local"
+	if existing.Data.Station != nil {
+		station = *existing.Data.Station
+	}
+	m.Stations[station]++
+}
+
+func runMerge(c guinea.Context) error {
+	var sources []mergeSource
+	for _, argument := range c.Arguments[1:] {
+		sources = append(sources, parseMergeSource(argument))
+	}
+
+	st, err := initialize(c.Arguments[0])
+	if err != nil {
+		return err
+	}
+	if closer, ok := st.(io.Closer); ok {
+		defer closer.Close()
+	}
+
+	for _, source := range sources {
+		fmt.Printf("Merging %s as station %s\n", source.Path, source.Station)
+		conflicts := newMergeConflicts()
+		summary, err := mergeFrom(st, source, conflicts, c.Options["overwrite"].Bool())
+		if err != nil {
+			return fmt.Errorf("Merging %s failed: %s", source.Path, err)
+		}
+		printMergeSummary(summary, conflicts)
+	}
+	return nil
+}
+
+func mergeFrom(st storage.Storage, source mergeSource, conflicts *mergeConflicts, overwrite bool) (importer.Summary, error) {
+	decoder, closeSource, err := openMergeSource(source.Path)
+	if err != nil {
+		return importer.Summary{}, err
+	}
+	defer closeSource()
+
+	options := importer.Options{
+		Overwrite:     overwrite,
+		Station:       source.Station,
+		Progress:      printProgress,
+		InvalidRecord: printInvalidRecord,
+		Conflict:      conflicts.Add,
+	}
+	return importer.Import(st, decoder, options)
+}
+
+func openMergeSource(path string) (formats.Decoder, func() error, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".bolt", ".db":
+		return openMergeDatabase(path)
+	default:
+		return openExport(path, "", false)
+	}
+}
+
+// openMergeDatabase opens the source database in read-only mode. If it has
+// pending migrations its temporary copy is migrated and opened instead.
+func openMergeDatabase(path string) (formats.Decoder, func() error, error) {
+	pending, err := bolt.PendingMigrations(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	removeCopy := func() error { return nil }
+	if len(pending) > 0 {
+		dir, err := os.MkdirTemp("", "flightradar-merge")
+		if err != nil {
+			return nil, nil, err
+		}
+		removeCopy = func() error { return os.RemoveAll(dir) }
+
+		fmt.Printf("Applying %d pending migrations to a temporary copy of %s\n", len(pending), path)
+		migrated, err := migrateCopy(path, dir)
+		if err != nil {
+			removeCopy()
+			return nil, nil, fmt.Errorf("Could not migrate a copy of the database: %s", err)
+		}
+		path = migrated
+	}
+
+	b, err := bolt.NewReadOnly(path)
+	if err != nil {
+		removeCopy()
+		return nil, nil, err
+	}
+	decoder := newStorageDecoder(b)
+	return decoder, func() error {
+		decoder.Close()
+		if err := b.Close(); err != nil {
+			removeCopy()
+			return err
+		}
+		return removeCopy()
+	}, nil
+}
+
+// migrateCopy copies the database to the given directory and applies the
+// pending migrations to the copy. It returns the path to the copy.
+func migrateCopy(path string, dir string) (string, error) {
+	copyPath := filepath.Join(dir, filepath.Base(path))
+	if err := copyFile(path, copyPath); err != nil {
+		return "", err
+	}
+	options, err := boltOptions()
+	if err != nil {
+		return "", err
+	}
+	if _, err := bolt.Migrate(copyPath, options); err != nil {
+		return "", err
+	}
+	return copyPath, nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func printMergeSummary(summary importer.Summary, conflicts *mergeConflicts) {
+	fmt.Printf("Merged: %s\n", summary)
+	if summary.Conflicts == 0 {
+		return
+	}
+	fmt.Printf("Conflicting fields: %s\n", formatCounts(conflicts.Fields))
+	fmt.Printf("Conflicts with stations: %s\n", formatCounts(conflicts.Stations))
+}
+
+// formatCounts lists the counts in descending order.
+func formatCounts(counts map[string]int) string {
+	var names []string
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	var parts []string
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s %d", name, counts[name]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+var errDecoderClosed = errors.New("decoder closed")
+
+// storageDecoder reads all data points from a storage in chronological
+// order. The data points are validated in the same way as the data points
+// decoded from the exported files.
+type storageDecoder struct {
+	data chan storage.StoredData
+	err  chan error
+	done chan struct{}
+	n    int
+}
+
+func newStorageDecoder(s storage.ReadStorage) *storageDecoder {
+	rv := &storageDecoder{
+		data: make(chan storage.StoredData, 100),
+		err:  make(chan error, 1),
+		done: make(chan struct{}),
+	}
+	go rv.run(s)
+	return rv
+}
+
+func (d *storageDecoder) run(s storage.ReadStorage) {
+	defer close(d.data)
+	to := time.Now().AddDate(100, 0, 0)
+	d.err <- s.Iterate(time.Unix(0, 0), to, func(data storage.StoredData) error {
+		select {
+		case d.data <- data:
+			return nil
+		case <-d.done:
+			return errDecoderClosed
+		}
+	})
+}
+
+func (d *storageDecoder) Decode() (storage.StoredData, error) {
+	data, ok := <-d.data
+	if !ok {
+		if err := <-d.err; err != nil {
+			return data, err
+		}
+		return data, io.EOF
+	}
+	d.n++
+	if err := formats.Validate(data); err != nil {
+		return data, &formats.RecordError{Line: d.n, Err: err}
+	}
+	return data, nil
+}
+
+// Line returns the number of the data point.
+func (d *storageDecoder) Line() int {
+	return d.n
+}
+
+// Close stops reading the data points.
+func (d *storageDecoder) Close() {
+	close(d.done)
+}
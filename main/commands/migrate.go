@@ -0,0 +1,65 @@
+package commands
+
+import (
+	"fmt"
+	"github.com/boreq/flightradar-backend/config"
+	"github.com/boreq/flightradar-backend/storage/bolt"
+	"github.com/boreq/guinea"
+)
+
+var migrateCmd = guinea.Command{
+	Run: runMigrate,
+	Arguments: []guinea.Argument{
+		{Name: "config", Description: "Config file"},
+	},
+	Options: []guinea.Option{
+		guinea.Option{
+			Name:        "dry-run",
+			Type:        guinea.Bool,
+			Description: "Only list the pending migrations",
+		},
+	},
+	ShortDescription: "migrates the database to the current schema version",
+	Description: `
+This command applies the pending migrations to the bolt database. A backup of
+the database is created next to it before any migrations are applied. The
+migrations are also applied automatically when the program starts. The program
+can't be running when this command is executed.
+`,
+}
+
+func runMigrate(c guinea.Context) error {
+	if err := config.Load(c.Arguments[0]); err != nil {
+		return err
+	}
+
+	if c.Options["dry-run"].Bool() {
+		pending, err := bolt.PendingMigrations(config.Config.DatabaseFile)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			fmt.Println("No pending migrations")
+		}
+		for _, m := range pending {
+			fmt.Printf("Pending migration %d: %s\n", m.Version, m.Description)
+		}
+		return nil
+	}
+
+	options, err := boltOptions()
+	if err != nil {
+		return err
+	}
+	applied, err := bolt.Migrate(config.Config.DatabaseFile, options)
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("No pending migrations")
+	}
+	for _, m := range applied {
+		fmt.Printf("Applied migration %d: %s\n", m.Version, m.Description)
+	}
+	return nil
+}
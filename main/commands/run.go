@@ -1,37 +1,161 @@
 package commands
 
 import (
+	"fmt"
 	"github.com/boreq/flightradar-backend/aggregator"
+	"github.com/boreq/flightradar-backend/backup"
 	"github.com/boreq/flightradar-backend/config"
+	"github.com/boreq/flightradar-backend/metadata"
+	"github.com/boreq/flightradar-backend/movements"
 	"github.com/boreq/flightradar-backend/server"
 	"github.com/boreq/flightradar-backend/sources"
+	"github.com/boreq/flightradar-backend/storage"
 	"github.com/boreq/guinea"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 var runCmd = guinea.Command{
 	Run: runRun,
 	Arguments: []guinea.Argument{
-		{"config", false, false, "Config file"},
+		{Name: "config", Description: "Config file"},
 	},
 	ShortDescription: "runs the program",
 }
 
 func runRun(c guinea.Context) error {
-	storage, err := initialize(c.Arguments[0])
+	st, err := initialize(c.Arguments[0])
 	if err != nil {
 		return err
 	}
+	backuper, _ := st.(storage.Backuper)
+
+	// Load the metadata which is reloaded on SIGHUP
+	md, reloaders, err := loadMetadata()
+	if err != nil {
+		return err
+	}
+	go reloadOnSignal(reloaders)
 
 	// Run the data collection
-	aggr := aggregator.New(storage)
+	detector, err := loadDetector()
+	if err != nil {
+		return err
+	}
+	aggr := aggregator.NewWithDetector(st, detector)
 	if err := sources.NewDump1090(config.Config.Dump1090Address, aggr.GetChannel()); err != nil {
 		return err
 	}
 
 	// Serve the collected data
-	if err := server.Serve(aggr, config.Config.ServeAddress); err != nil {
-		return err
+	serverErr := make(chan error, 1)
+	go func() {
+		serverErr <- server.Serve(aggr, backuper, md, config.Config.ServeAddress)
+	}()
+
+	// Periodically save the snapshots of the database
+	var scheduler *backup.Scheduler
+	if config.Config.SnapshotDirectory != "" && backuper != nil {
+		options := backup.Options{
+			Directory: config.Config.SnapshotDirectory,
+			Interval:  time.Duration(config.Config.SnapshotInterval) * time.Hour,
+			Keep:      config.Config.SnapshotKeep,
+			Compress:  config.Config.SnapshotGzip,
+		}
+		scheduler, err = backup.NewScheduler(backuper, options)
+		if err != nil {
+			aggr.Close()
+			return err
+		}
+	}
+
+	// Store the buffered data points before exiting
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	select {
+	case err = <-serverErr:
+	case <-signals:
+	}
+
+	if scheduler != nil {
+		scheduler.Close()
+	}
+	if closeErr := aggr.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if closer, ok := st.(io.Closer); ok {
+		if closeErr := closer.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// reloader is a file based database which can be reloaded.
+type reloader struct {
+	Name   string
+	Reload func() error
+}
+
+// loadMetadata loads the configured metadata databases.
+func loadMetadata() (server.Metadata, []reloader, error) {
+	var md server.Metadata
+	var reloaders []reloader
+	var err error
+
+	if config.Config.AircraftDatabaseFile != "" {
+		if md.Aircraft, err = metadata.Load(config.Config.AircraftDatabaseFile); err != nil {
+			return md, nil, err
+		}
+		reloaders = append(reloaders, reloader{"aircraft database", md.Aircraft.Reload})
+	}
+
+	if config.Config.AirlinesFile != "" {
+		if md.Airlines, err = metadata.LoadAirlines(config.Config.AirlinesFile); err != nil {
+			return md, nil, err
+		}
+		reloaders = append(reloaders, reloader{"airlines", md.Airlines.Reload})
+	}
+
+	if config.Config.RoutesFile != "" {
+		if md.Routes, err = metadata.LoadRoutes(config.Config.RoutesFile); err != nil {
+			return md, nil, err
+		}
+		reloaders = append(reloaders, reloader{"routes", md.Routes.Reload})
 	}
 
-	return nil
+	return md, reloaders, nil
+}
+
+// loadDetector creates the detector of the movements at the airports located
+// near the station. Nil is returned if the airports aren't configured.
+func loadDetector() (*movements.Detector, error) {
+	if config.Config.AirportsFile == "" {
+		return nil, nil
+	}
+	airports, err := metadata.LoadAirports(config.Config.AirportsFile, config.Config.RunwaysFile)
+	if err != nil {
+		return nil, err
+	}
+	airports = metadata.AirportsWithin(airports, config.Config.StationLongitude, config.Config.StationLatitude, config.Config.AirportsRadius)
+	fmt.Fprintf(os.Stderr, "Detecting movements at %d airports\n", len(airports))
+	return movements.NewDetector(airports), nil
+}
+
+func reloadOnSignal(reloaders []reloader) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	for range signals {
+		for _, r := range reloaders {
+			if err := r.Reload(); err != nil {
+				fmt.Fprintf(os.Stderr, "Reloading the %s failed: %s\n", r.Name, err)
+				continue
+			}
+			fmt.Fprintf(os.Stderr, "Reloaded the %s\n", r.Name)
+		}
+	}
 }
@@ -6,6 +6,7 @@ import (
 	"github.com/boreq/guinea"
 	"github.com/boreq/flightradar-backend/main/commands"
 	"os"
+	_ "time/tzdata"
 )
 
 func main() {
@@ -0,0 +1,244 @@
+package metadata
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/boreq/flightradar-backend/geo"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Airport describes an airport identified by its ICAO code.
+type Airport struct {
+	Icao      string
+	Name      string
+	Latitude  float64
+	Longitude float64
+
+	// Elevation is expressed in feet.
+	Elevation int
+
+	// Runways lists both ends of each open runway.
+	Runways []Runway
+}
+
+// Runway is a single end of a runway.
+type Runway struct {
+	// Ident is the designator of the runway end eg. "07" or "25L".
+	Ident string
+
+	// Heading is the true heading of the runway end in degrees.
+	Heading float64
+}
+
+// airportFields maps the names of the columns of the airport tables to the
+// fields of Airport.
+var airportFields = map[string]string{
+	"ident":         "icao",
+	"gps_code":      "gps_code",
+	"icao_code":     "icao_code",
+	"icao":          "icao_code",
+	"name":          "name",
+	"latitude_deg":  "latitude",
+	"latitude":      "latitude",
+	"longitude_deg": "longitude",
+	"longitude":     "longitude",
+	"elevation_ft":  "elevation",
+	"elevation":     "elevation",
+	"type":          "type",
+}
+
+// ignoredAirportTypes are the types of the airports used by OurAirports
+// which are never used by the tracked planes.
+var ignoredAirportTypes = map[string]bool{
+	"heliport":      true,
+	"closed":        true,
+	"seaplane_base": true,
+	"balloonport":   true,
+}
+
+// runwayFields maps the names of the columns of the runway tables to the
+// fields of Runway.
+var runwayFields = map[string]string{
+	"airport_ident":   "airport",
+	"le_ident":        "le_ident",
+	"le_heading_degt": "le_heading",
+	"he_ident":        "he_ident",
+	"he_heading_degt": "he_heading",
+	"closed":          "closed",
+}
+
+// LoadAirports loads the airports and optionally their runways from the CSV
+// files in the format used by OurAirports. The runways file can be empty in
+// which case the airports are loaded without runways. Heliports, seaplane
+// bases, balloonports, closed airports and airports without an ICAO code are
+// skipped. The airports are sorted by their ICAO codes.
+func LoadAirports(airportsPath, runwaysPath string) ([]Airport, error) {
+	airports, err := loadAirports(airportsPath)
+	if err != nil {
+		return nil, fmt.Errorf("Loading %s failed: %s", airportsPath, err)
+	}
+	if runwaysPath != "" {
+		if err := loadRunways(runwaysPath, airports); err != nil {
+			return nil, fmt.Errorf("Loading %s failed: %s", runwaysPath, err)
+		}
+	}
+
+	var rv []Airport
+	for _, airport := range airports {
+		rv = append(rv, *airport)
+	}
+	sort.Slice(rv, func(i, j int) bool { return rv[i].Icao < rv[j].Icao })
+	return rv, nil
+}
+
+func loadAirports(path string) (map[string]*Airport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	airports := make(map[string]*Airport)
+	err = readFields(bufio.NewReader(file), airportFields, nil, func(fields map[string]string) error {
+		if ignoredAirportTypes[strings.TrimSpace(fields["type"])] {
+			return nil
+		}
+		icao := airportIcao(fields)
+		if icao == "" {
+			return nil
+		}
+		latitude, err := strconv.ParseFloat(strings.TrimSpace(fields["latitude"]), 64)
+		if err != nil {
+			return nil
+		}
+		longitude, err := strconv.ParseFloat(strings.TrimSpace(fields["longitude"]), 64)
+		if err != nil {
+			return nil
+		}
+		elevation, _ := strconv.Atoi(strings.TrimSpace(fields["elevation"]))
+		airports[icao] = &Airport{
+			Icao:      icao,
+			Name:      cleanField(fields["name"]),
+			Latitude:  latitude,
+			Longitude: longitude,
+			Elevation: elevation,
+		}
+		return nil
+	})
+	return airports, err
+}
+
+// airportIcao returns the ICAO code of the airport. OurAirports uses local
+// codes as the identifiers of small airfields so the dedicated columns are
+// preferred if they are present.
+func airportIcao(fields map[string]string) string {
+	for _, field := range []string{"icao_code", "gps_code", "icao"} {
+		code := strings.ToUpper(cleanField(fields[field]))
+		if len(code) == 4 {
+			return code
+		}
+	}
+	return ""
+}
+
+func loadRunways(path string, airports map[string]*Airport) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return readFields(bufio.NewReader(file), runwayFields, nil, func(fields map[string]string) error {
+		airport, ok := airports[strings.ToUpper(cleanField(fields["airport"]))]
+		if !ok || parseFlag(fields["closed"]) {
+			return nil
+		}
+		for _, end := range []string{"le", "he"} {
+			if runway, ok := newRunway(fields[end+"_ident"], fields[end+"_heading"]); ok {
+				airport.Runways = append(airport.Runways, runway)
+			}
+		}
+		return nil
+	})
+}
+
+// newRunway creates a runway end. If the heading is missing it is derived
+// from the designator. False is returned if neither can be used.
+func newRunway(ident, heading string) (Runway, bool) {
+	ident = strings.ToUpper(cleanField(ident))
+	if ident == "" {
+		return Runway{}, false
+	}
+	if h, err := strconv.ParseFloat(strings.TrimSpace(heading), 64); err == nil {
+		return Runway{Ident: ident, Heading: h}, true
+	}
+	number, err := strconv.Atoi(strings.TrimRight(ident, "LCR"))
+	if err != nil || number < 1 || number > 36 {
+		return Runway{}, false
+	}
+	return Runway{Ident: ident, Heading: float64(number * 10)}, true
+}
+
+// AirportsWithin returns the airports located within the given radius in
+// kilometers from the given position.
+func AirportsWithin(airports []Airport, longitude, latitude, radius float64) []Airport {
+	var rv []Airport
+	for _, airport := range airports {
+		if geo.Distance(longitude, latitude, airport.Longitude, airport.Latitude) <= radius {
+			rv = append(rv, airport)
+		}
+	}
+	return rv
+}
+
+// RunwayDesignator returns the designator of the runway most closely aligned
+// with the given heading in degrees. The runways of the airport are matched
+// first, if none of them is aligned within maxRunwayDeviation the designator
+// is derived from the heading itself.
+func (a Airport) RunwayDesignator(heading float64) string {
+	best := ""
+	bestDeviation := float64(maxRunwayDeviation)
+	for _, runway := range a.Runways {
+		if deviation := angleDifference(heading, runway.Heading); deviation <= bestDeviation {
+			best = runway.Ident
+			bestDeviation = deviation
+		}
+	}
+	if best != "" {
+		return best
+	}
+
+	number := int(math.Round(normalizeAngle(heading)/10)) % 36
+	if number == 0 {
+		number = 36
+	}
+	return fmt.Sprintf("%02d", number)
+}
+
+// maxRunwayDeviation is the largest difference in degrees between the
+// heading of a plane and the heading of a runway for which the plane is
+// considered to be using the runway.
+const maxRunwayDeviation = 30
+
+// angleDifference returns the absolute difference between the angles in
+// degrees in the range [0, 180].
+func angleDifference(a, b float64) float64 {
+	d := math.Abs(normalizeAngle(a) - normalizeAngle(b))
+	if d > 180 {
+		d = 360 - d
+	}
+	return d
+}
+
+// normalizeAngle returns the angle in degrees in the range [0, 360).
+func normalizeAngle(a float64) float64 {
+	a = math.Mod(a, 360)
+	if a < 0 {
+		a += 360
+	}
+	return a
+}
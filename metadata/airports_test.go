@@ -0,0 +1,100 @@
+package metadata
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoadAirports(t *testing.T) {
+	dir := t.TempDir()
+	airportsPath := filepath.Join(dir, "airports.csv")
+	runwaysPath := filepath.Join(dir, "runways.csv")
+
+	airports := `"id","ident","type","name","latitude_deg","longitude_deg","elevation_ft","gps_code","icao_code"
+4203,"EPKK","large_airport","Kraków John Paul II International Airport",50.077702,19.7848,791,"EPKK","EPKK"
+4204,"EPKT","medium_airport","Katowice International Airport",50.4743,19.08,995,"EPKT","EPKT"
+300,"PL-0001","small_airport","Pobiednik Wielki Airfield",50.0897,20.2017,,"EPKP",""
+301,"PL-0002","heliport","Hospital Heliport",50.06,19.95,700,"EPXH",""
+302,"PL-0003","small_airport","No Code Airfield",50.1,20.0,700,"",""
+303,"EPXX","closed","Closed Airport",50.2,20.1,700,"EPXX","EPXX"
+`
+	runways := `"id","airport_ref","airport_ident","length_ft","closed","le_ident","le_heading_degT","he_ident","he_heading_degT"
+1,4203,"EPKK",8366,0,"07",76.7,"25",256.7
+2,4204,"EPKT",10498,0,"09","","27",""
+3,4204,"EPKT",1000,1,"18","","36",""
+4,303,"EPXX",1000,0,"01","","19",""
+`
+	if err := ioutil.WriteFile(airportsPath, []byte(airports), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(runwaysPath, []byte(runways), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadAirports(airportsPath, runwaysPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Airport{
+		{
+			Icao:      "EPKK",
+			Name:      "Kraków John Paul II International Airport",
+			Latitude:  50.077702,
+			Longitude: 19.7848,
+			Elevation: 791,
+			Runways:   []Runway{{"07", 76.7}, {"25", 256.7}},
+		},
+		{
+			Icao:      "EPKP",
+			Name:      "Pobiednik Wielki Airfield",
+			Latitude:  50.0897,
+			Longitude: 20.2017,
+		},
+		{
+			Icao:      "EPKT",
+			Name:      "Katowice International Airport",
+			Latitude:  50.4743,
+			Longitude: 19.08,
+			Elevation: 995,
+			Runways:   []Runway{{"09", 90}, {"27", 270}},
+		},
+	}
+	if !reflect.DeepEqual(loaded, expected) {
+		t.Errorf("Wrong airports %+v", loaded)
+	}
+
+	nearby := AirportsWithin(loaded, 19.97605, 50.08179, 30)
+	if len(nearby) != 2 || nearby[0].Icao != "EPKK" || nearby[1].Icao != "EPKP" {
+		t.Errorf("Wrong nearby airports %+v", nearby)
+	}
+}
+
+func TestRunwayDesignator(t *testing.T) {
+	airport := Airport{Runways: []Runway{{"07", 76.7}, {"25", 256.7}}}
+	noRunways := Airport{}
+
+	testCases := []struct {
+		Airport  Airport
+		Heading  float64
+		Expected string
+	}{
+		{airport, 80, "07"},
+		{airport, 250, "25"},
+		{airport, 100, "07"},
+		{airport, 180, "18"},
+		{noRunways, 76.7, "08"},
+		{noRunways, 3, "36"},
+		{noRunways, 358, "36"},
+		{noRunways, -90, "27"},
+	}
+
+	for _, testCase := range testCases {
+		designator := testCase.Airport.RunwayDesignator(testCase.Heading)
+		if designator != testCase.Expected {
+			t.Errorf("Heading %f: expected %s, got %s", testCase.Heading, testCase.Expected, designator)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package metadata
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Callsign is a callsign used by an airline split into its parts.
+type Callsign struct {
+	// Airline is the three letter ICAO airline designator.
+	Airline string `json:"airline"`
+
+	// Number is the flight identification following the designator.
+	Number string `json:"number"`
+}
+
+// ParseCallsign splits a callsign into the airline designator and the flight
+// number. False is returned if the callsign doesn't look like the callsign of
+// an airline eg. if it is a registration of the aircraft.
+func ParseCallsign(callsign string) (Callsign, bool) {
+	callsign = NormalizeCallsign(callsign)
+	if len(callsign) < 4 || len(callsign) > 7 {
+		return Callsign{}, false
+	}
+	for i, c := range callsign {
+		switch {
+		case i < 3:
+			if c < 'A' || c > 'Z' {
+				return Callsign{}, false
+			}
+		case i == 3:
+			if c < '0' || c > '9' {
+				return Callsign{}, false
+			}
+		default:
+			if !(c >= '0' && c <= '9' || c >= 'A' && c <= 'Z') {
+				return Callsign{}, false
+			}
+		}
+	}
+	return Callsign{Airline: callsign[:3], Number: callsign[3:]}, true
+}
+
+// NormalizeCallsign returns the callsign in the form used as the key of the
+// route table.
+func NormalizeCallsign(callsign string) string {
+	return strings.ToUpper(strings.TrimSpace(callsign))
+}
+
+// Airline describes an airline identified by its ICAO designator.
+type Airline struct {
+	Icao    string `json:"icao"`
+	Iata    string `json:"iata,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Country string `json:"country,omitempty"`
+}
+
+// airlineFields maps the names of the columns of the airline tables to the
+// fields of Airline.
+var airlineFields = map[string]string{
+	"icao":    "icao",
+	"code":    "icao",
+	"iata":    "iata",
+	"name":    "name",
+	"country": "country",
+	"active":  "active",
+}
+
+// openFlightsAirlineColumns are the columns of the airlines.dat file
+// distributed by OpenFlights which doesn't have a header.
+var openFlightsAirlineColumns = []string{"", "name", "", "iata", "icao", "", "country", "active"}
+
+// Airlines holds the airlines loaded from a CSV file indexed by their ICAO
+// designators. The methods of nil airlines behave as if no airlines were
+// loaded.
+type Airlines struct {
+	path     string
+	mutex    sync.RWMutex
+	airlines map[string]Airline
+}
+
+// LoadAirlines loads the airlines from a CSV file with a header containing at
+// least the "icao" column and optionally the "iata", "name" and "country"
+// columns. The airlines.dat file distributed by OpenFlights is also
+// supported.
+func LoadAirlines(path string) (*Airlines, error) {
+	rv := &Airlines{path: path}
+	if err := rv.Reload(); err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// Reload loads the airlines from their file again. The previously loaded
+// airlines are kept if the file can't be read.
+func (a *Airlines) Reload() error {
+	file, err := os.Open(a.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	airlines := make(map[string]Airline)
+	active := make(map[string]bool)
+	err = readFields(bufio.NewReader(file), airlineFields, openFlightsAirlineColumns, func(fields map[string]string) error {
+		airline := Airline{
+			Icao:    strings.ToUpper(cleanField(fields["icao"])),
+			Iata:    strings.ToUpper(cleanField(fields["iata"])),
+			Name:    cleanField(fields["name"]),
+			Country: cleanField(fields["country"]),
+		}
+		if len(airline.Icao) != 3 {
+			return nil
+		}
+		// The tables often contain the defunct airlines which used
+		// the same designator.
+		isActive := fields["active"] == "" || parseFlag(fields["active"])
+		if _, ok := airlines[airline.Icao]; ok && active[airline.Icao] && !isActive {
+			return nil
+		}
+		airlines[airline.Icao] = airline
+		active[airline.Icao] = isActive
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Loading %s failed: %s", a.path, err)
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.airlines = airlines
+	return nil
+}
+
+// Lookup returns the airline with the given ICAO designator.
+func (a *Airlines) Lookup(designator string) (Airline, bool) {
+	if a == nil {
+		return Airline{}, false
+	}
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	airline, ok := a.airlines[strings.ToUpper(designator)]
+	return airline, ok
+}
+
+// Route describes the airports between which a flight is operated. The
+// airports are identified by their ICAO codes.
+type Route struct {
+	Origin      string `json:"origin"`
+	Destination string `json:"destination"`
+}
+
+// routeFields maps the names of the columns of the route tables to the
+// fields of Route.
+var routeFields = map[string]string{
+	"callsign":     "callsign",
+	"origin":       "origin",
+	"from":         "origin",
+	"departure":    "origin",
+	"destination":  "destination",
+	"to":           "destination",
+	"arrival":      "destination",
+	"route":        "route",
+	"airportcodes": "route",
+}
+
+// Routes holds the routes loaded from a CSV file indexed by the callsigns.
+// The methods of nil routes behave as if no routes were loaded.
+type Routes struct {
+	path   string
+	mutex  sync.RWMutex
+	routes map[string]Route
+}
+
+// LoadRoutes loads the routes from a CSV file with a header containing the
+// "callsign" column and either the "origin" and "destination" columns or the
+// "route" column with the airport codes separated by dashes. In the latter
+// case the first airport is the origin and the last one is the destination.
+func LoadRoutes(path string) (*Routes, error) {
+	rv := &Routes{path: path}
+	if err := rv.Reload(); err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// Reload loads the routes from their file again. The previously loaded
+// routes are kept if the file can't be read.
+func (r *Routes) Reload() error {
+	file, err := os.Open(r.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	routes := make(map[string]Route)
+	err = readFields(bufio.NewReader(file), routeFields, nil, func(fields map[string]string) error {
+		callsign := NormalizeCallsign(fields["callsign"])
+		route := Route{
+			Origin:      strings.ToUpper(cleanField(fields["origin"])),
+			Destination: strings.ToUpper(cleanField(fields["destination"])),
+		}
+		if airports := strings.Split(cleanField(fields["route"]), "-"); len(airports) > 1 {
+			route.Origin = strings.ToUpper(strings.TrimSpace(airports[0]))
+			route.Destination = strings.ToUpper(strings.TrimSpace(airports[len(airports)-1]))
+		}
+		if callsign == "" || route.Origin == "" || route.Destination == "" {
+			return nil
+		}
+		routes[callsign] = route
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Loading %s failed: %s", r.path, err)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.routes = routes
+	return nil
+}
+
+// Lookup returns the route of the flight with the given callsign.
+func (r *Routes) Lookup(callsign string) (Route, bool) {
+	if r == nil {
+		return Route{}, false
+	}
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	route, ok := r.routes[NormalizeCallsign(callsign)]
+	return route, ok
+}
+
+// cleanField trims the value and removes the placeholders used by some
+// tables for unknown values.
+func cleanField(s string) string {
+	s = strings.TrimSpace(s)
+	switch s {
+	case `\N`, "-", "N/A":
+		return ""
+	default:
+		return s
+	}
+}
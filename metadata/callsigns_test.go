@@ -0,0 +1,132 @@
+package metadata
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseCallsign(t *testing.T) {
+	testCases := []struct {
+		Callsign string
+		Ok       bool
+		Airline  string
+		Number   string
+	}{
+		{"LOT3NV", true, "LOT", "3NV"},
+		{"lot3nv  ", true, "LOT", "3NV"},
+		{"RYR1", true, "RYR", "1"},
+		{"DLH4AB", true, "DLH", "4AB"},
+		{"SPLWA", false, "", ""},
+		{"N123AB", false, "", ""},
+		{"LOT", false, "", ""},
+		{"LOTABC", false, "", ""},
+		{"LOT12345", false, "", ""},
+		{"LOT3-NV", false, "", ""},
+		{"", false, "", ""},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Callsign, func(t *testing.T) {
+			callsign, ok := ParseCallsign(testCase.Callsign)
+			if ok != testCase.Ok {
+				t.Fatalf("Wrong result %t", ok)
+			}
+			if callsign.Airline != testCase.Airline || callsign.Number != testCase.Number {
+				t.Errorf("Wrong callsign %v", callsign)
+			}
+		})
+	}
+}
+
+func TestLoadAirlines(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Input string
+	}{
+		{
+			Name: "header",
+			Input: `icao,iata,name,country
+LOT,LO,LOT Polish Airlines,Poland
+RYR,FR,Ryanair,Ireland
+XX,,Invalid,
+`,
+		},
+		{
+			Name: "openflights",
+			Input: `1,"Private flight",\N,"-","N/A","","","Y"
+3350,"LOT Polish Airlines",\N,"LO","LOT","POLLOT","Poland","Y"
+9999,"Defunct Airline",\N,"","LOT","","Poland","N"
+4296,"Ryanair",\N,"FR","RYR","RYANAIR","Ireland","Y"
+`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "airlines.csv")
+			if err := ioutil.WriteFile(path, []byte(testCase.Input), 0644); err != nil {
+				t.Fatal(err)
+			}
+			airlines, err := LoadAirlines(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			airline, ok := airlines.Lookup("LOT")
+			expected := Airline{Icao: "LOT", Iata: "LO", Name: "LOT Polish Airlines", Country: "Poland"}
+			if !ok || airline != expected {
+				t.Errorf("Wrong airline %v", airline)
+			}
+			if _, ok := airlines.Lookup("RYR"); !ok {
+				t.Error("Airline RYR is missing")
+			}
+			if len(airlines.airlines) != 2 {
+				t.Errorf("Wrong airlines %v", airlines.airlines)
+			}
+		})
+	}
+}
+
+func TestLoadRoutes(t *testing.T) {
+	testCases := []struct {
+		Name  string
+		Input string
+	}{
+		{
+			Name: "origin and destination",
+			Input: `callsign,origin,destination
+LOT3NV,EPWA,EGLL
+RYR1,,EIDW
+`,
+		},
+		{
+			Name: "route",
+			Input: `Callsign,Code,Number,AirlineCode,AirportCodes
+LOT3NV,LO3NV,3NV,LOT,EPWA-EPKK-EGLL
+RYR1,FR1,1,RYR,EIDW
+`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "routes.csv")
+			if err := ioutil.WriteFile(path, []byte(testCase.Input), 0644); err != nil {
+				t.Fatal(err)
+			}
+			routes, err := LoadRoutes(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			route, ok := routes.Lookup("lot3nv ")
+			if !ok || route != (Route{Origin: "EPWA", Destination: "EGLL"}) {
+				t.Errorf("Wrong route %v", route)
+			}
+			if _, ok := routes.Lookup("RYR1"); ok {
+				t.Error("Incomplete route was loaded")
+			}
+		})
+	}
+}
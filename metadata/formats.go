@@ -0,0 +1,324 @@
+package metadata
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Formats of the files from which the aircraft can be read.
+const (
+	FormatCSV  = "csv"
+	FormatJSON = "json"
+)
+
+// DetectFormat returns the format of the file based on its extension. CSV is
+// used by default.
+func DetectFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json", ".ndjson", ".jsonl":
+		return FormatJSON
+	default:
+		return FormatCSV
+	}
+}
+
+// fieldNames maps the names of the columns or keys used by the popular
+// aircraft databases to the fields of Aircraft.
+var fieldNames = map[string]string{
+	"icao":         "icao",
+	"icao24":       "icao",
+	"hex":          "icao",
+	"registration": "registration",
+	"reg":          "registration",
+	"r":            "registration",
+	"type":         "type_code",
+	"type_code":    "type_code",
+	"typecode":     "type_code",
+	"icaotype":     "type_code",
+	"t":            "type_code",
+	"operator":     "operator",
+	"owner":        "operator",
+	"ownop":        "operator",
+	"military":     "military",
+	"mil":          "military",
+}
+
+// csvHeader is the header of the CSV files created by Write.
+var csvHeader = []string{"icao", "registration", "type_code", "operator", "military"}
+
+// Read calls the function for each aircraft read from the reader. The CSV
+// files must start with a header. The JSON files must contain an array of
+// objects, an object with the ICAO addresses as the keys and objects as the
+// values or a sequence of objects eg. one object per line. The names of the columns and keys are matched against the
+// names used by the popular aircraft databases, unknown columns and keys are
+// ignored. Records without a valid ICAO address are skipped and their number
+// is returned.
+func Read(r io.Reader, format string, fn func(Aircraft) error) (int, error) {
+	switch format {
+	case FormatCSV:
+		return readCSV(r, fn)
+	case FormatJSON:
+		return readJSON(r, fn)
+	default:
+		return 0, fmt.Errorf("Unknown format: %s", format)
+	}
+}
+
+func readCSV(r io.Reader, fn func(Aircraft) error) (int, error) {
+	invalid := 0
+	err := readFields(r, fieldNames, nil, func(fields map[string]string) error {
+		aircraft, ok := newAircraft(fields)
+		if !ok {
+			invalid++
+			return nil
+		}
+		return fn(aircraft)
+	})
+	return invalid, err
+}
+
+// readFields reads a CSV file and calls the function with the values of the
+// columns which are recognized using their names in the header. The names
+// are mapped to the names of the fields. If none of the columns are
+// recognized and the number of columns matches the fallback the file is
+// assumed to have no header and the fallback columns are used instead.
+func readFields(r io.Reader, names map[string]string, fallback []string, fn func(fields map[string]string) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("Reading the header failed: %s", err)
+	}
+	columns := make(map[int]string)
+	for i, name := range header {
+		if field, ok := names[strings.ToLower(strings.TrimSpace(name))]; ok {
+			columns[i] = field
+		}
+	}
+
+	handle := func(record []string) error {
+		fields := make(map[string]string)
+		for i, value := range record {
+			if field, ok := columns[i]; ok {
+				fields[field] = value
+			}
+		}
+		return fn(fields)
+	}
+
+	if len(columns) == 0 && fallback != nil && len(header) == len(fallback) {
+		for i, field := range fallback {
+			if field != "" {
+				columns[i] = field
+			}
+		}
+		if err := handle(header); err != nil {
+			return err
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := handle(record); err != nil {
+			return err
+		}
+	}
+}
+
+func readJSON(r io.Reader, fn func(Aircraft) error) (int, error) {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return 0, err
+	}
+
+	invalid := 0
+	handle := func(object map[string]interface{}, icao string) error {
+		fields := make(map[string]string)
+		if icao != "" {
+			fields["icao"] = icao
+		}
+		for key, value := range object {
+			if field, ok := fieldNames[strings.ToLower(key)]; ok {
+				fields[field] = jsonValueToString(value)
+			}
+		}
+		aircraft, ok := newAircraft(fields)
+		if !ok {
+			invalid++
+			return nil
+		}
+		return fn(aircraft)
+	}
+
+	switch token {
+	case json.Delim('['):
+		for decoder.More() {
+			var object map[string]interface{}
+			if err := decoder.Decode(&object); err != nil {
+				return invalid, err
+			}
+			if err := handle(object, ""); err != nil {
+				return invalid, err
+			}
+		}
+	case json.Delim('{'):
+		if !decoder.More() {
+			break
+		}
+		key, err := decoder.Token()
+		if err != nil {
+			return invalid, err
+		}
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return invalid, err
+		}
+
+		// A sequence of objects is recognized by the first value which
+		// isn't an object eg. the database distributed with readsb
+		// which contains one object per line.
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			err := readJSONSequence(decoder, map[string]interface{}{key.(string): value}, handle)
+			return invalid, err
+		}
+		if err := handle(object, key.(string)); err != nil {
+			return invalid, err
+		}
+
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return invalid, err
+			}
+			var object map[string]interface{}
+			if err := decoder.Decode(&object); err != nil {
+				return invalid, err
+			}
+			if err := handle(object, key.(string)); err != nil {
+				return invalid, err
+			}
+		}
+	default:
+		return 0, errors.New("Expected an array or an object")
+	}
+
+	if _, err := decoder.Token(); err != nil {
+		return invalid, err
+	}
+	return invalid, nil
+}
+
+// readJSONSequence reads a sequence of objects. The first object is read
+// partially, the keys and values which were already read are passed in the
+// map.
+func readJSONSequence(decoder *json.Decoder, first map[string]interface{}, handle func(map[string]interface{}, string) error) error {
+	for decoder.More() {
+		key, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			return err
+		}
+		first[key.(string)] = value
+	}
+	if _, err := decoder.Token(); err != nil {
+		return err
+	}
+	if err := handle(first, ""); err != nil {
+		return err
+	}
+
+	for decoder.More() {
+		var object map[string]interface{}
+		if err := decoder.Decode(&object); err != nil {
+			return err
+		}
+		if err := handle(object, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonValueToString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
+
+// newAircraft creates an aircraft from the fields. False is returned if the
+// ICAO address is missing or invalid.
+func newAircraft(fields map[string]string) (Aircraft, bool) {
+	icao, ok := NormalizeIcao(fields["icao"])
+	if !ok {
+		return Aircraft{}, false
+	}
+	return Aircraft{
+		Icao:         icao,
+		Registration: strings.TrimSpace(fields["registration"]),
+		TypeCode:     strings.ToUpper(strings.TrimSpace(fields["type_code"])),
+		Operator:     strings.TrimSpace(fields["operator"]),
+		Military:     parseFlag(fields["military"]),
+	}, true
+}
+
+func parseFlag(s string) bool {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "1", "true", "t", "yes", "y":
+		return true
+	default:
+		return false
+	}
+}
+
+// Write writes the aircraft to a CSV file which can be loaded by Load. The
+// aircraft are sorted by their ICAO addresses.
+func Write(w io.Writer, aircraft map[string]Aircraft) error {
+	var icaos []string
+	for icao := range aircraft {
+		icaos = append(icaos, icao)
+	}
+	sort.Strings(icaos)
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, icao := range icaos {
+		a := aircraft[icao]
+		record := []string{a.Icao, a.Registration, a.TypeCode, a.Operator, strconv.FormatBool(a.Military)}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,152 @@
+// Package metadata provides information about the aircraft which isn't
+// broadcast by them such as their registration, type or operator.
+package metadata
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Aircraft describes a single aircraft identified by its ICAO address.
+type Aircraft struct {
+	Icao         string `json:"icao"`
+	Registration string `json:"registration,omitempty"`
+	TypeCode     string `json:"type_code,omitempty"`
+	Operator     string `json:"operator,omitempty"`
+	Military     bool   `json:"military"`
+}
+
+// NormalizeIcao returns the ICAO address in the form used as the key of the
+// database. False is returned if the address is invalid.
+func NormalizeIcao(icao string) (string, bool) {
+	icao = strings.ToLower(strings.TrimSpace(icao))
+	if len(icao) != 6 {
+		return "", false
+	}
+	for _, c := range icao {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return "", false
+		}
+	}
+	return icao, true
+}
+
+// Database holds the aircraft loaded from a file indexed by their ICAO
+// addresses. The methods of a nil database behave as if it was empty.
+type Database struct {
+	path     string
+	mutex    sync.RWMutex
+	aircraft map[string]Aircraft
+}
+
+// Load loads the database from a file created by Update.
+func Load(path string) (*Database, error) {
+	rv := &Database{path: path}
+	if err := rv.Reload(); err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// Reload loads the database from its file again. The previously loaded
+// aircraft are kept if the file can't be read.
+func (d *Database) Reload() error {
+	file, err := os.Open(d.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	aircraft := make(map[string]Aircraft)
+	_, err = Read(bufio.NewReader(file), FormatCSV, func(a Aircraft) error {
+		aircraft[a.Icao] = a
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Loading %s failed: %s", d.path, err)
+	}
+
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.aircraft = aircraft
+	return nil
+}
+
+// Lookup returns the aircraft with the given ICAO address.
+func (d *Database) Lookup(icao string) (Aircraft, bool) {
+	if d == nil {
+		return Aircraft{}, false
+	}
+	icao, ok := NormalizeIcao(icao)
+	if !ok {
+		return Aircraft{}, false
+	}
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	a, ok := d.aircraft[icao]
+	return a, ok
+}
+
+// Len returns the number of aircraft in the database.
+func (d *Database) Len() int {
+	if d == nil {
+		return 0
+	}
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return len(d.aircraft)
+}
+
+// UpdateSummary describes the result of an update.
+type UpdateSummary struct {
+	// Aircraft is the number of aircraft saved in the database.
+	Aircraft int
+
+	// Invalid is the number of skipped records without a valid ICAO
+	// address.
+	Invalid int
+}
+
+// Update reads the aircraft from the source file in the given format and
+// replaces the database located at the destination path with them. Records
+// without a valid ICAO address are skipped. If the source contains multiple
+// records with the same ICAO address the last one is used. The destination
+// file is replaced only once the source file was read successfully.
+func Update(source string, format string, destination string) (UpdateSummary, error) {
+	var summary UpdateSummary
+
+	file, err := os.Open(source)
+	if err != nil {
+		return summary, err
+	}
+	defer file.Close()
+
+	aircraft := make(map[string]Aircraft)
+	summary.Invalid, err = Read(bufio.NewReader(file), format, func(a Aircraft) error {
+		aircraft[a.Icao] = a
+		return nil
+	})
+	if err != nil {
+		return summary, err
+	}
+	summary.Aircraft = len(aircraft)
+
+	tmp, err := os.CreateTemp(filepath.Dir(destination), ".aircraft-*")
+	if err != nil {
+		return summary, err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := Write(tmp, aircraft); err != nil {
+		tmp.Close()
+		return summary, err
+	}
+	if err := tmp.Close(); err != nil {
+		return summary, err
+	}
+	return summary, os.Rename(tmp.Name(), destination)
+}
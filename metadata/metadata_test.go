@@ -0,0 +1,162 @@
+package metadata
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	expected := []Aircraft{
+		{Icao: "48ae22", Registration: "SP-LWA", TypeCode: "B738", Operator: "LOT"},
+		{Icao: "3c4b26", Registration: "D-ABYA", TypeCode: "B748", Operator: "Lufthansa", Military: false},
+		{Icao: "43c6f1", Registration: "ZZ336", TypeCode: "A332", Military: true},
+	}
+
+	testCases := []struct {
+		Name   string
+		Format string
+		Input  string
+
+		// NoMilitary is set if the format doesn't contain the military
+		// flag.
+		NoMilitary bool
+	}{
+		{
+			Name:       "opensky csv",
+			Format:     FormatCSV,
+			NoMilitary: true,
+			Input: `"icao24","registration","manufacturername","typecode","operator"
+"48AE22","SP-LWA","Boeing","b738","LOT"
+"3c4b26","D-ABYA","Boeing","B748","Lufthansa"
+"invalid","X","","",""
+"43c6f1","ZZ336","Airbus","A332",""
+`,
+		},
+		{
+			Name:   "csv with military column",
+			Format: FormatCSV,
+			Input: `icao,registration,type_code,operator,military
+48ae22,SP-LWA,B738,LOT,false
+3c4b26,D-ABYA,B748,Lufthansa,
+,,,,
+43c6f1,ZZ336,A332,,true
+`,
+		},
+		{
+			Name:   "json array",
+			Format: FormatJSON,
+			Input: `[
+{"icao": "48ae22", "registration": "SP-LWA", "type": "B738", "operator": "LOT"},
+{"icao": "3c4b26", "registration": "D-ABYA", "type": "B748", "operator": "Lufthansa", "military": false},
+{"registration": "X"},
+{"icao": "43c6f1", "registration": "ZZ336", "type": "A332", "military": 1}
+]`,
+		},
+		{
+			Name:   "json object",
+			Format: FormatJSON,
+			Input: `{
+"48AE22": {"r": "SP-LWA", "t": "B738", "ownop": "LOT"},
+"3C4B26": {"r": "D-ABYA", "t": "B748", "ownop": "Lufthansa"},
+"~123456": {"r": "X"},
+"43C6F1": {"r": "ZZ336", "t": "A332", "mil": true}
+}`,
+		},
+		{
+			Name:   "readsb ndjson",
+			Format: FormatJSON,
+			Input: `{"icao":"48ae22","reg":"SP-LWA","icaotype":"B738","year":null,"manufacturer":"Boeing","model":"737-800","ownop":"LOT","faa_pia":false,"faa_ladd":false,"short_type":"L2J","mil":false}
+{"icao":"3c4b26","reg":"D-ABYA","icaotype":"B748","year":"2011","manufacturer":"Boeing","model":"747-830","ownop":"Lufthansa","faa_pia":false,"faa_ladd":false,"short_type":"L4J","mil":false}
+{"icao":"~123456","reg":null,"icaotype":null,"year":null,"manufacturer":null,"model":null,"ownop":null,"faa_pia":false,"faa_ladd":false,"short_type":null,"mil":false}
+{"icao":"43c6f1","reg":"ZZ336","icaotype":"A332","year":null,"manufacturer":"Airbus","model":"Voyager KC2","ownop":null,"faa_pia":false,"faa_ladd":false,"short_type":"L2J","mil":true}
+`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			var aircraft []Aircraft
+			invalid, err := Read(strings.NewReader(testCase.Input), testCase.Format, func(a Aircraft) error {
+				aircraft = append(aircraft, a)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if invalid != 1 {
+				t.Errorf("Wrong number of invalid records %d", invalid)
+			}
+			if len(aircraft) != len(expected) {
+				t.Fatalf("Wrong aircraft %v", aircraft)
+			}
+			for i := range expected {
+				e := expected[i]
+				if testCase.NoMilitary {
+					e.Military = false
+				}
+				if aircraft[i] != e {
+					t.Errorf("Wrong aircraft %v instead of %v", aircraft[i], e)
+				}
+			}
+		})
+	}
+}
+
+func TestReadSingleLine(t *testing.T) {
+	// A line from the basic-ac-db.json database used by readsb.
+	input := `{"icao":"a00001","reg":"N1","icaotype":"GLF5","year":"2007","manufacturer":"Gulfstream Aerospace","model":"G-V","ownop":"FEDERAL AVIATION ADMINISTRATION","faa_pia":false,"faa_ladd":false,"short_type":"L2J","mil":false}`
+
+	var aircraft []Aircraft
+	invalid, err := Read(strings.NewReader(input), DetectFormat("basic-ac-db.ndjson"), func(a Aircraft) error {
+		aircraft = append(aircraft, a)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := Aircraft{Icao: "a00001", Registration: "N1", TypeCode: "GLF5", Operator: "FEDERAL AVIATION ADMINISTRATION"}
+	if invalid != 0 || len(aircraft) != 1 || aircraft[0] != expected {
+		t.Fatalf("Wrong aircraft %v, %d invalid", aircraft, invalid)
+	}
+}
+
+func TestUpdateAndLoad(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.json")
+	destination := filepath.Join(dir, "aircraft.csv")
+
+	input := `[{"icao": "48ae22", "registration": "SP-LWA"}, {"icao": "48AE22", "registration": "SP-LWB"}, {"icao": "3c4b26"}]`
+	if err := ioutil.WriteFile(source, []byte(input), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	summary, err := Update(source, DetectFormat(source), destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Aircraft != 2 || summary.Invalid != 0 {
+		t.Fatalf("Wrong summary %v", summary)
+	}
+
+	database, err := Load(destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if database.Len() != 2 {
+		t.Errorf("Wrong number of aircraft %d", database.Len())
+	}
+	aircraft, ok := database.Lookup("48AE22")
+	if !ok || aircraft.Registration != "SP-LWB" {
+		t.Errorf("Wrong aircraft %v", aircraft)
+	}
+	if _, ok := database.Lookup("000000"); ok {
+		t.Error("Unknown aircraft was found")
+	}
+
+	var nilDatabase *Database
+	if _, ok := nilDatabase.Lookup("48ae22"); ok {
+		t.Error("Aircraft was found in a nil database")
+	}
+}
@@ -0,0 +1,214 @@
+// Package movements detects the departures, arrivals and overflights of the
+// airports located near the station.
+package movements
+
+import (
+	"github.com/boreq/flightradar-backend/geo"
+	"github.com/boreq/flightradar-backend/metadata"
+	"github.com/boreq/flightradar-backend/storage"
+	"math"
+	"sort"
+)
+
+// proximityRadius is the distance in kilometers from an airport within which
+// the planes are considered to be using or overflying it.
+const proximityRadius = 10
+
+// lowAltitude is the altitude in feet above the elevation of an airport below
+// which the planes are considered to be taking off or landing.
+const lowAltitude = 2500
+
+// climbThreshold is the minimum difference in feet between the lowest and
+// the highest altitude of a track required to classify it as a departure or
+// an arrival. It prevents the planes flying low over an airport from being
+// classified as departing or arriving.
+const climbThreshold = 1000
+
+// Detector classifies the tracks of the planes as departures, arrivals or
+// overflights of the airports. The data points are split into tracks in the
+// same way as in the statistics and each track is classified once it is
+// completed.
+type Detector struct {
+	airports []metadata.Airport
+	splitter *storage.TrackSplitter
+}
+
+// NewDetector creates a detector which considers the given airports.
+func NewDetector(airports []metadata.Airport) *Detector {
+	return &Detector{
+		airports: airports,
+		splitter: storage.NewTrackSplitter(),
+	}
+}
+
+// Add adds a data point. The data points must be added in chronological
+// order. The movements detected in the tracks completed by this data point
+// are returned.
+func (d *Detector) Add(data storage.StoredData) []storage.Movement {
+	return d.classifyAll(d.splitter.Add(data))
+}
+
+// Flush classifies all tracks which are still open and resets the detector.
+func (d *Detector) Flush() []storage.Movement {
+	return d.classifyAll(d.splitter.Flush())
+}
+
+func (d *Detector) classifyAll(tracks [][]storage.StoredData) []storage.Movement {
+	var rv []storage.Movement
+	for _, track := range tracks {
+		rv = append(rv, Classify(d.airports, track)...)
+	}
+	return rv
+}
+
+// Classify returns the movements detected in a track sorted by time. A
+// track starting low near an airport and climbing later is a departure, a
+// track ending low near an airport after descending is an arrival. All other
+// airports the plane flew near are considered to be overflown. At most one
+// movement is returned for each airport.
+func Classify(airports []metadata.Airport, track []storage.StoredData) []storage.Movement {
+	var points []storage.StoredData
+	for _, d := range track {
+		if d.Data.Latitude != nil && d.Data.Longitude != nil {
+			points = append(points, d)
+		}
+	}
+	if len(points) == 0 {
+		return nil
+	}
+
+	var rv []storage.Movement
+	used := make(map[string]bool)
+
+	first := points[0]
+	if airport, ok := nearestLow(airports, first); ok && maxAltitude(points[1:]) >= *first.Data.Altitude+climbThreshold {
+		rv = append(rv, newMovement(airport, storage.MovementDeparture, first, departureHeading(points)))
+		used[airport.Icao] = true
+	}
+
+	last := points[len(points)-1]
+	if airport, ok := nearestLow(airports, last); ok && maxAltitude(points[:len(points)-1]) >= *last.Data.Altitude+climbThreshold {
+		if !used[airport.Icao] {
+			rv = append(rv, newMovement(airport, storage.MovementArrival, last, arrivalHeading(points)))
+			used[airport.Icao] = true
+		}
+	}
+
+	for _, airport := range airports {
+		if used[airport.Icao] {
+			continue
+		}
+		closest, distance := closestPoint(airport, points)
+		if distance <= proximityRadius {
+			rv = append(rv, newMovement(airport, storage.MovementOverflight, closest, nil))
+		}
+	}
+
+	sortMovements(rv)
+	return rv
+}
+
+func newMovement(airport metadata.Airport, t storage.MovementType, d storage.StoredData, heading *float64) storage.Movement {
+	m := storage.Movement{
+		Airport:      airport.Icao,
+		Type:         t,
+		Icao:         *d.Data.Icao,
+		FlightNumber: d.Data.FlightNumber,
+		Time:         d.Time,
+	}
+	if heading != nil {
+		m.Runway = airport.RunwayDesignator(*heading)
+	}
+	return m
+}
+
+func sortMovements(movements []storage.Movement) {
+	sort.SliceStable(movements, func(i, j int) bool { return movements[i].Time.Before(movements[j].Time) })
+}
+
+// nearestLow returns the nearest airport within proximityRadius for which
+// the data point is below lowAltitude.
+func nearestLow(airports []metadata.Airport, d storage.StoredData) (metadata.Airport, bool) {
+	if d.Data.Altitude == nil {
+		return metadata.Airport{}, false
+	}
+	var rv metadata.Airport
+	found := false
+	best := math.Inf(1)
+	for _, airport := range airports {
+		if *d.Data.Altitude-airport.Elevation > lowAltitude {
+			continue
+		}
+		distance := distanceTo(airport, d)
+		if distance <= proximityRadius && distance < best {
+			rv = airport
+			found = true
+			best = distance
+		}
+	}
+	return rv, found
+}
+
+// closestPoint returns the data point closest to the airport and its
+// distance in kilometers.
+func closestPoint(airport metadata.Airport, points []storage.StoredData) (storage.StoredData, float64) {
+	var rv storage.StoredData
+	best := math.Inf(1)
+	for _, d := range points {
+		if distance := distanceTo(airport, d); distance < best {
+			rv = d
+			best = distance
+		}
+	}
+	return rv, best
+}
+
+func distanceTo(airport metadata.Airport, d storage.StoredData) float64 {
+	return geo.Distance(airport.Longitude, airport.Latitude, *d.Data.Longitude, *d.Data.Latitude)
+}
+
+// maxAltitude returns the highest altitude reported by the data points or
+// math.MinInt32 if none of them reported the altitude.
+func maxAltitude(points []storage.StoredData) int {
+	rv := math.MinInt32
+	for _, d := range points {
+		if d.Data.Altitude != nil && *d.Data.Altitude > rv {
+			rv = *d.Data.Altitude
+		}
+	}
+	return rv
+}
+
+// departureHeading returns the heading of the plane at the beginning of the
+// track. The reported heading is preferred, otherwise the bearing between
+// the first two data points is used. Nil is returned if the heading can't be
+// determined.
+func departureHeading(points []storage.StoredData) *float64 {
+	if h := points[0].Data.Heading; h != nil {
+		rv := float64(*h)
+		return &rv
+	}
+	if len(points) < 2 {
+		return nil
+	}
+	return bearing(points[0], points[1])
+}
+
+// arrivalHeading returns the heading of the plane at the end of the track in
+// the same way as departureHeading.
+func arrivalHeading(points []storage.StoredData) *float64 {
+	last := len(points) - 1
+	if h := points[last].Data.Heading; h != nil {
+		rv := float64(*h)
+		return &rv
+	}
+	if len(points) < 2 {
+		return nil
+	}
+	return bearing(points[last-1], points[last])
+}
+
+func bearing(from, to storage.StoredData) *float64 {
+	rv := geo.Bearing(*from.Data.Longitude, *from.Data.Latitude, *to.Data.Longitude, *to.Data.Latitude)
+	return &rv
+}
@@ -0,0 +1,178 @@
+package movements
+
+import (
+	"github.com/boreq/flightradar-backend/metadata"
+	"github.com/boreq/flightradar-backend/storage"
+	"testing"
+	"time"
+)
+
+var airports = []metadata.Airport{
+	{
+		Icao:      "EPKK",
+		Latitude:  50.077702,
+		Longitude: 19.7848,
+		Elevation: 791,
+		Runways:   []metadata.Runway{{Ident: "07", Heading: 76.7}, {Ident: "25", Heading: 256.7}},
+	},
+	{
+		Icao:      "EPKT",
+		Latitude:  50.4743,
+		Longitude: 19.08,
+		Elevation: 995,
+	},
+}
+
+var start = time.Date(2018, 2, 8, 12, 0, 0, 0, time.UTC)
+
+type point struct {
+	Longitude float64
+	Latitude  float64
+	Altitude  int
+}
+
+func createTrack(icao string, from time.Time, points []point) []storage.StoredData {
+	var rv []storage.StoredData
+	for i, p := range points {
+		d := storage.StoredData{
+			Data: storage.Data{
+				Icao:      new(string),
+				Latitude:  new(float64),
+				Longitude: new(float64),
+				Altitude:  new(int),
+			},
+			Time: from.Add(time.Duration(i) * time.Minute),
+		}
+		*d.Data.Icao = icao
+		*d.Data.Latitude = p.Latitude
+		*d.Data.Longitude = p.Longitude
+		*d.Data.Altitude = p.Altitude
+		rv = append(rv, d)
+	}
+	return rv
+}
+
+var departure = []point{
+	{19.785, 50.078, 1200},
+	{19.810, 50.083, 2500},
+	{19.850, 50.090, 4500},
+	{19.950, 50.110, 8000},
+}
+
+var arrival = []point{
+	{19.950, 50.110, 8000},
+	{19.850, 50.090, 4500},
+	{19.810, 50.083, 2500},
+	{19.785, 50.078, 1200},
+}
+
+var overflight = []point{
+	{19.600, 50.000, 10000},
+	{19.780, 50.070, 10000},
+	{19.950, 50.150, 10000},
+}
+
+var lowPass = []point{
+	{19.700, 50.070, 2000},
+	{19.780, 50.078, 2000},
+	{19.850, 50.085, 2000},
+}
+
+var farAway = []point{
+	{21.000, 52.000, 1000},
+	{21.100, 52.100, 5000},
+}
+
+func TestClassify(t *testing.T) {
+	testCases := []struct {
+		Name     string
+		Points   []point
+		Heading  *int
+		Expected []storage.Movement
+	}{
+		{
+			Name:   "departure",
+			Points: departure,
+			Expected: []storage.Movement{
+				{Airport: "EPKK", Type: storage.MovementDeparture, Runway: "07", Icao: "aaaaaa", Time: start},
+			},
+		},
+		{
+			Name:   "arrival",
+			Points: arrival,
+			Expected: []storage.Movement{
+				{Airport: "EPKK", Type: storage.MovementArrival, Runway: "25", Icao: "aaaaaa", Time: start.Add(3 * time.Minute)},
+			},
+		},
+		{
+			Name:    "reported heading",
+			Points:  departure,
+			Heading: func() *int { h := 255; return &h }(),
+			Expected: []storage.Movement{
+				{Airport: "EPKK", Type: storage.MovementDeparture, Runway: "25", Icao: "aaaaaa", Time: start},
+			},
+		},
+		{
+			Name:   "overflight",
+			Points: overflight,
+			Expected: []storage.Movement{
+				{Airport: "EPKK", Type: storage.MovementOverflight, Icao: "aaaaaa", Time: start.Add(time.Minute)},
+			},
+		},
+		{
+			Name:   "low pass",
+			Points: lowPass,
+			Expected: []storage.Movement{
+				{Airport: "EPKK", Type: storage.MovementOverflight, Icao: "aaaaaa", Time: start.Add(time.Minute)},
+			},
+		},
+		{
+			Name:     "far away",
+			Points:   farAway,
+			Expected: nil,
+		},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			track := createTrack("aaaaaa", start, testCase.Points)
+			for i := range track {
+				track[i].Data.Heading = testCase.Heading
+			}
+			movements := Classify(airports, track)
+			if len(movements) != len(testCase.Expected) {
+				t.Fatalf("Wrong movements %+v", movements)
+			}
+			for i := range movements {
+				if movements[i] != testCase.Expected[i] {
+					t.Errorf("Expected %+v, got %+v", testCase.Expected[i], movements[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDetector(t *testing.T) {
+	detector := NewDetector(airports)
+
+	var data []storage.StoredData
+	data = append(data, createTrack("aaaaaa", start, departure)...)
+	data = append(data, createTrack("bbbbbb", start.Add(30*time.Minute), arrival)...)
+
+	var movements []storage.Movement
+	for _, d := range data {
+		movements = append(movements, detector.Add(d)...)
+	}
+	if len(movements) != 1 || movements[0].Icao != "aaaaaa" || movements[0].Type != storage.MovementDeparture {
+		t.Fatalf("Wrong movements %+v", movements)
+	}
+
+	movements = detector.Flush()
+	if len(movements) != 1 || movements[0].Icao != "bbbbbb" || movements[0].Type != storage.MovementArrival {
+		t.Fatalf("Wrong movements after flush %+v", movements)
+	}
+
+	if movements := detector.Flush(); len(movements) != 0 {
+		t.Errorf("Detector wasn't reset %+v", movements)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"github.com/boreq/flightradar-backend/logging"
 	"github.com/julienschmidt/httprouter"
+	"io"
 	"net/http"
 )
 
@@ -13,6 +14,8 @@ var log = logging.GetLogger("api")
 
 var InternalServerError = NewError(500, "Internal server error.")
 var BadRequest = NewError(400, "Bad request.")
+var Unauthorized = NewError(401, "Unauthorized.")
+var NotFound = NewError(404, "Not found.")
 
 type Error interface {
 	GetCode() int
@@ -38,9 +41,34 @@ func (err apiError) Error() string {
 
 type Handle func(r *http.Request, p httprouter.Params) (interface{}, Error)
 
+// RawResponse can be returned by a handler in order to send a response which
+// is not encoded as JSON.
+type RawResponse interface {
+	ContentType() string
+	Write(w io.Writer) error
+}
+
+// StreamedResponse is a RawResponse which is written directly to the client
+// instead of being buffered. It is meant for large responses. The status can't
+// be changed once the response is being written so if an error occurs the
+// connection is aborted in order to let the client know that the response is
+// incomplete.
+type StreamedResponse interface {
+	RawResponse
+
+	// Header returns the additional headers sent with the response.
+	Header() http.Header
+}
+
 func Call(w http.ResponseWriter, r *http.Request, p httprouter.Params, handle Handle) error {
 	code := 200
 	response, apiErr := handle(r, p)
+	if streamed, ok := response.(StreamedResponse); ok && apiErr == nil {
+		return callStreamed(w, streamed)
+	}
+	if raw, ok := response.(RawResponse); ok && apiErr == nil {
+		return callRaw(w, raw)
+	}
 	if apiErr != nil {
 		response = apiError{apiErr.GetCode(), apiErr.Error()}
 		code = apiErr.GetCode()
@@ -58,6 +86,39 @@ func Call(w http.ResponseWriter, r *http.Request, p httprouter.Params, handle Ha
 	return err
 }
 
+func callRaw(w http.ResponseWriter, response RawResponse) error {
+	// The response is buffered so that an error can still be reported.
+	buf := &bytes.Buffer{}
+	if err := response.Write(buf); err != nil {
+		log.Printf("Write error: %s", err)
+		j, _ := json.Marshal(InternalServerError)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(InternalServerError.GetCode())
+		_, err = w.Write(j)
+		return err
+	}
+	w.Header().Set("Content-Type", response.ContentType())
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.WriteHeader(200)
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func callStreamed(w http.ResponseWriter, response StreamedResponse) error {
+	for key, values := range response.Header() {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.Header().Set("Content-Type", response.ContentType())
+	w.WriteHeader(200)
+	if err := response.Write(w); err != nil {
+		log.Printf("Write error: %s", err)
+		panic(http.ErrAbortHandler)
+	}
+	return nil
+}
+
 func Wrap(handle Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		Call(w, r, p, handle)
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"github.com/boreq/flightradar-backend/backup"
+	"github.com/boreq/flightradar-backend/config"
+	"github.com/boreq/flightradar-backend/server/api"
+	"github.com/boreq/flightradar-backend/storage"
+	"github.com/julienschmidt/httprouter"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Backup streams a consistent snapshot of the database. The snapshot is
+// compressed using gzip if the gzip parameter is set to true. The endpoint
+// is available only if the admin token is configured.
+func (h *handler) Backup(r *http.Request, _ httprouter.Params) (interface{}, api.Error) {
+	if h.backuper == nil || config.Config.AdminToken == "" {
+		return nil, api.NotFound
+	}
+
+	if !isAdmin(r) {
+		return nil, api.Unauthorized
+	}
+
+	compress := r.URL.Query().Get("gzip") == "true"
+	return backupResponse{
+		backuper: h.backuper,
+		compress: compress,
+		filename: backup.Filename(time.Now(), compress),
+	}, nil
+}
+
+// isAdmin returns true if the request contains the configured admin token in
+// the Authorization header.
+func isAdmin(r *http.Request) bool {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, prefix) {
+		return false
+	}
+	token := strings.TrimPrefix(header, prefix)
+	return subtle.ConstantTimeCompare([]byte(token), []byte(config.Config.AdminToken)) == 1
+}
+
+type backupResponse struct {
+	backuper storage.Backuper
+	compress bool
+	filename string
+}
+
+func (b backupResponse) ContentType() string {
+	if b.compress {
+		return backup.GzipContentType
+	}
+	return backup.ContentType
+}
+
+func (b backupResponse) Header() http.Header {
+	header := make(http.Header)
+	header.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", b.filename))
+	return header
+}
+
+func (b backupResponse) Write(w io.Writer) error {
+	return backup.Write(w, b.backuper, b.compress)
+}
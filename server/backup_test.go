@@ -0,0 +1,134 @@
+package server
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"github.com/boreq/flightradar-backend/config"
+	"github.com/boreq/flightradar-backend/server/api"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type fakeBackuper struct {
+	content []byte
+}
+
+func (f fakeBackuper) Backup(w io.Writer) (int64, error) {
+	n, err := w.Write(f.content)
+	return int64(n), err
+}
+
+func TestBackup(t *testing.T) {
+	defer func(token string) { config.Config.AdminToken = token }(config.Config.AdminToken)
+	config.Config.AdminToken = "secret"
+
+	content := []byte("database")
+	h := &handler{backuper: fakeBackuper{content}}
+
+	testCases := []struct {
+		Name          string
+		URL           string
+		Authorization string
+		Code          int
+		ContentType   string
+	}{
+		{"no token", "/admin/backup", "", 401, "application/json"},
+		{"wrong token", "/admin/backup", "Bearer wrong", 401, "application/json"},
+		{"uncompressed", "/admin/backup", "Bearer secret", 200, "application/octet-stream"},
+		{"compressed", "/admin/backup?gzip=true", "Bearer secret", 200, "application/gzip"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", testCase.URL, nil)
+			if testCase.Authorization != "" {
+				r.Header.Set("Authorization", testCase.Authorization)
+			}
+			w := httptest.NewRecorder()
+			api.Call(w, r, nil, h.Backup)
+
+			if w.Code != testCase.Code {
+				t.Fatalf("Wrong code %d", w.Code)
+			}
+			if contentType := w.Header().Get("Content-Type"); contentType != testCase.ContentType {
+				t.Fatalf("Wrong content type %s", contentType)
+			}
+			if w.Code != 200 {
+				return
+			}
+			if !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment") {
+				t.Errorf("Wrong content disposition %s", w.Header().Get("Content-Disposition"))
+			}
+
+			var body io.Reader = w.Body
+			if testCase.ContentType == "application/gzip" {
+				gzipReader, err := gzip.NewReader(body)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body = gzipReader
+			}
+			received, err := ioutil.ReadAll(body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(received, content) {
+				t.Errorf("Wrong content %q", received)
+			}
+		})
+	}
+}
+
+func TestBackupDisabled(t *testing.T) {
+	defer func(token string) { config.Config.AdminToken = token }(config.Config.AdminToken)
+	config.Config.AdminToken = ""
+
+	h := &handler{backuper: fakeBackuper{}}
+	r := httptest.NewRequest("GET", "/admin/backup", nil)
+	r.Header.Set("Authorization", "Bearer ")
+	_, apiErr := h.Backup(r, nil)
+	if apiErr != api.NotFound {
+		t.Fatalf("Wrong error %v", apiErr)
+	}
+}
+
+type failingBackuper struct{}
+
+func (f failingBackuper) Backup(w io.Writer) (int64, error) {
+	n, err := w.Write(bytes.Repeat([]byte("a"), 64*1024))
+	if err != nil {
+		return int64(n), err
+	}
+	return int64(n), errors.New("Disk failure")
+}
+
+func TestBackupAbortedOnError(t *testing.T) {
+	defer func(token string) { config.Config.AdminToken = token }(config.Config.AdminToken)
+	config.Config.AdminToken = "secret"
+
+	h := &handler{backuper: failingBackuper{}}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.Call(w, r, nil, h.Backup)
+	}))
+	defer server.Close()
+
+	r, err := http.NewRequest("GET", server.URL+"/admin/backup", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Authorization", "Bearer secret")
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if _, err := ioutil.ReadAll(resp.Body); err == nil {
+		t.Fatal("Incomplete response was received without an error")
+	}
+}
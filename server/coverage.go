@@ -0,0 +1,87 @@
+package server
+
+import (
+	"github.com/boreq/flightradar-backend/server/api"
+	"github.com/boreq/flightradar-backend/storage"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+)
+
+type coverageBand struct {
+	// MinAltitude is nil for the lowest band.
+	MinAltitude *int `json:"min_altitude"`
+
+	// MaxAltitude is nil for the highest band.
+	MaxAltitude *int `json:"max_altitude"`
+
+	// Outline is a polygon formed by the farthest points recorded at
+	// each degree of bearing, sorted by bearing.
+	Outline []storage.CoveragePoint `json:"outline"`
+}
+
+// Coverage returns the range outlines for each altitude band. If the time
+// range isn't specified the persisted outlines calculated using all data
+// points are returned.
+func (h *handler) Coverage(r *http.Request, _ httprouter.Params) (interface{}, api.Error) {
+	coverage, apiErr := h.coverage(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	bands := toCoverageBands(coverage)
+	geoJSON, apiErr := wantsGeoJSON(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if geoJSON {
+		return coverageToGeoJSON(bands), nil
+	}
+	return bands, nil
+}
+
+func (h *handler) coverage(r *http.Request) (*storage.Coverage, api.Error) {
+	query := r.URL.Query()
+	_, hasFrom := query["from"]
+	_, hasTo := query["to"]
+	if !hasFrom && !hasTo {
+		coverage, err := h.aggr.RetrieveCoverage()
+		if err != nil {
+			log.Printf("Coverage error: %s", err)
+			return nil, api.InternalServerError
+		}
+		return coverage, nil
+	}
+
+	from, to, err := optionalTimerangeParams(r)
+	if err != nil {
+		return nil, api.BadRequest
+	}
+
+	data, err := h.aggr.RetrieveTimerange(from, to)
+	if err != nil {
+		return nil, api.InternalServerError
+	}
+
+	station := stationPosition()
+	coverage := storage.NewCoverage()
+	for _, d := range data {
+		coverage.Add(d, station)
+	}
+	return coverage, nil
+}
+
+func toCoverageBands(coverage *storage.Coverage) []coverageBand {
+	var rv []coverageBand
+	for band := 0; band <= len(storage.CoverageAltitudeBands); band++ {
+		b := coverageBand{
+			Outline: coverage.Outline(band),
+		}
+		if band > 0 {
+			b.MinAltitude = &storage.CoverageAltitudeBands[band-1]
+		}
+		if band < len(storage.CoverageAltitudeBands) {
+			b.MaxAltitude = &storage.CoverageAltitudeBands[band]
+		}
+		rv = append(rv, b)
+	}
+	return rv
+}
@@ -0,0 +1,90 @@
+package server
+
+import (
+	"github.com/boreq/flightradar-backend/geojson"
+	"github.com/boreq/flightradar-backend/kml"
+	"github.com/boreq/flightradar-backend/server/api"
+	"github.com/boreq/flightradar-backend/storage"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Response formats which can be selected using the "format" parameter.
+const (
+	formatJSON    = "json"
+	formatGeoJSON = "geojson"
+	formatKML     = "kml"
+	formatKMZ     = "kmz"
+)
+
+var formatContentTypes = map[string]string{
+	formatGeoJSON: geojson.ContentType,
+	formatKML:     kml.ContentType,
+	formatKMZ:     kml.KMZContentType,
+}
+
+// responseFormat returns the format requested by the client using the
+// "format" parameter or the Accept header. JSON is used by default. An
+// unknown value of the "format" parameter results in api.BadRequest.
+func responseFormat(r *http.Request) (string, api.Error) {
+	if format := r.URL.Query().Get("format"); format != "" {
+		if _, ok := formatContentTypes[format]; !ok && format != formatJSON {
+			return "", api.BadRequest
+		}
+		return format, nil
+	}
+	accept := r.Header.Get("Accept")
+	for format, contentType := range formatContentTypes {
+		if strings.Contains(accept, contentType) {
+			return format, nil
+		}
+	}
+	return formatJSON, nil
+}
+
+// wantsGeoJSON returns true if the client requested a GeoJSON response.
+func wantsGeoJSON(r *http.Request) (bool, api.Error) {
+	format, apiErr := responseFormat(r)
+	return format == formatGeoJSON, apiErr
+}
+
+// tracksResponse returns the data points encoded in the requested format.
+// The name is used as the title of the KML documents. The JSON responses
+// include the information about the aircraft and the flights.
+func (h *handler) tracksResponse(r *http.Request, name string, data []storage.StoredData) (interface{}, api.Error) {
+	format, apiErr := responseFormat(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	switch format {
+	case formatGeoJSON:
+		return geojson.Tracks(data), nil
+	case formatKML:
+		return kmlResponse{name: name, data: data}, nil
+	case formatKMZ:
+		return kmlResponse{name: name, data: data, compressed: true}, nil
+	default:
+		return h.storedDataWithInfo(data), nil
+	}
+}
+
+type kmlResponse struct {
+	name       string
+	data       []storage.StoredData
+	compressed bool
+}
+
+func (k kmlResponse) ContentType() string {
+	if k.compressed {
+		return kml.KMZContentType
+	}
+	return kml.ContentType
+}
+
+func (k kmlResponse) Write(w io.Writer) error {
+	if k.compressed {
+		return kml.WriteKMZ(w, k.name, k.data)
+	}
+	return kml.Write(w, k.name, k.data)
+}
@@ -0,0 +1,59 @@
+package server
+
+import (
+	"github.com/boreq/flightradar-backend/kml"
+	"github.com/boreq/flightradar-backend/server/api"
+	"github.com/julienschmidt/httprouter"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResponseFormat(t *testing.T) {
+	testCases := []struct {
+		URL      string
+		Accept   string
+		Expected string
+		Error    api.Error
+	}{
+		{"/range.json", "", formatJSON, nil},
+		{"/range.json", "application/json", formatJSON, nil},
+		{"/range.json?format=geojson", "", formatGeoJSON, nil},
+		{"/range.json", "application/geo+json", formatGeoJSON, nil},
+		{"/range.json?format=json", "application/geo+json", formatJSON, nil},
+		{"/range.json?format=kml", "", formatKML, nil},
+		{"/range.json", "application/vnd.google-earth.kmz", formatKMZ, nil},
+		{"/range.json?format=xml", "", "", api.BadRequest},
+		{"/range.json?format=xml", "application/geo+json", "", api.BadRequest},
+	}
+
+	for _, testCase := range testCases {
+		r := httptest.NewRequest("GET", testCase.URL, nil)
+		r.Header.Set("Accept", testCase.Accept)
+		format, apiErr := responseFormat(r)
+		if format != testCase.Expected || apiErr != testCase.Error {
+			t.Errorf("Invalid format for %s %s: %s %v", testCase.URL, testCase.Accept, format, apiErr)
+		}
+	}
+}
+
+func TestPlaneKML(t *testing.T) {
+	h := createHeatmapHandler(t)
+
+	router := httprouter.New()
+	router.GET("/plane/:icao", api.Wrap(h.Plane))
+
+	for _, format := range []string{formatKML, formatKMZ} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/plane/000000.json?format="+format, nil))
+		if w.Code != 200 {
+			t.Fatalf("Invalid code %d", w.Code)
+		}
+		expected := kml.ContentType
+		if format == formatKMZ {
+			expected = kml.KMZContentType
+		}
+		if w.Header().Get("Content-Type") != expected {
+			t.Fatalf("Invalid content type %s", w.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"github.com/boreq/flightradar-backend/geojson"
+	"sort"
+)
+
+type polarProperties struct {
+	Distance float64 `json:"distance"`
+}
+
+// polarToGeoJSON returns a Polygon feature formed by the points of the polar
+// range plot ordered by bearing.
+func polarToGeoJSON(polar map[int]polarResponse) geojson.FeatureCollection {
+	var bearings []int
+	var maxDistance float64
+	for bearing, v := range polar {
+		bearings = append(bearings, bearing)
+		if v.Distance > maxDistance {
+			maxDistance = v.Distance
+		}
+	}
+	sort.Ints(bearings)
+
+	var positions []geojson.Position
+	for _, bearing := range bearings {
+		data := polar[bearing].Data.Data
+		positions = append(positions, geojson.Position{*data.Longitude, *data.Latitude})
+	}
+
+	var features []geojson.Feature
+	if feature, ok := geojson.PolygonFeature(positions, polarProperties{maxDistance}); ok {
+		features = append(features, feature)
+	}
+	return geojson.NewFeatureCollection(features)
+}
+
+type coverageProperties struct {
+	MinAltitude *int `json:"min_altitude"`
+	MaxAltitude *int `json:"max_altitude"`
+}
+
+// coverageToGeoJSON returns a Polygon feature per altitude band.
+func coverageToGeoJSON(bands []coverageBand) geojson.FeatureCollection {
+	var features []geojson.Feature
+	for _, band := range bands {
+		properties := coverageProperties{
+			MinAltitude: band.MinAltitude,
+			MaxAltitude: band.MaxAltitude,
+		}
+		if feature, ok := geojson.PolygonFeature(geojson.Outline(band.Outline), properties); ok {
+			features = append(features, feature)
+		}
+	}
+	return geojson.NewFeatureCollection(features)
+}
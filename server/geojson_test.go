@@ -0,0 +1,35 @@
+package server
+
+import (
+	"github.com/boreq/flightradar-backend/geojson"
+	"github.com/boreq/flightradar-backend/server/api"
+	"github.com/julienschmidt/httprouter"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTimeRangeGeoJSON(t *testing.T) {
+	h := createHeatmapHandler(t)
+
+	router := httprouter.New()
+	router.GET("/range.json", api.Wrap(h.TimeRange))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/range.json?from=0&to=200&format=geojson", nil))
+	if w.Code != 200 {
+		t.Fatalf("Invalid code %d", w.Code)
+	}
+	if w.Header().Get("Content-Type") != geojson.ContentType {
+		t.Fatalf("Invalid content type %s", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestPolarGeoJSON(t *testing.T) {
+	collection := polarToGeoJSON(toPolar(createDeteministicStoredData()))
+	if len(collection.Features) != 1 {
+		t.Fatalf("Invalid number of features %d", len(collection.Features))
+	}
+	if collection.Features[0].Geometry.Type != geojson.TypePolygon {
+		t.Fatalf("Invalid geometry %s", collection.Features[0].Geometry.Type)
+	}
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"github.com/boreq/flightradar-backend/heatmap"
+	"github.com/boreq/flightradar-backend/server/api"
+	"github.com/boreq/flightradar-backend/storage"
+	"github.com/julienschmidt/httprouter"
+	"image"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const defaultHeatmapCellSize = 0.1
+const minHeatmapCellSize = 0.001
+
+// Number of cells along each side of a tile in the JSON tile responses.
+const defaultHeatmapTileCells = 64
+const maxHeatmapTileCells = heatmap.TileSize
+
+type heatmapCell struct {
+	X     int `json:"x"`
+	Y     int `json:"y"`
+	Count int `json:"count"`
+}
+
+type heatmapResponse struct {
+	// CellSize is the size of a cell in degrees. A cell covers the area
+	// from x * cell_size to (x + 1) * cell_size degrees of longitude and
+	// from y * cell_size to (y + 1) * cell_size degrees of latitude.
+	CellSize float64       `json:"cell_size"`
+	Max      int           `json:"max"`
+	Cells    []heatmapCell `json:"cells"`
+}
+
+type heatmapTileResponse struct {
+	Z int `json:"z"`
+	X int `json:"x"`
+	Y int `json:"y"`
+
+	// Size is the number of cells along each side of the tile. The cells
+	// are indexed from the top left corner of the tile.
+	Size  int           `json:"size"`
+	Max   int           `json:"max"`
+	Cells []heatmapCell `json:"cells"`
+}
+
+type pngResponse struct {
+	img image.Image
+}
+
+func (p pngResponse) ContentType() string {
+	return "image/png"
+}
+
+func (p pngResponse) Write(w io.Writer) error {
+	return heatmap.EncodeTile(w, p.img)
+}
+
+// Heatmap returns the number of data points recorded in the given time range
+// in each cell of a grid. The data can be limited to a bounding box.
+func (h *handler) Heatmap(r *http.Request, _ httprouter.Params) (interface{}, api.Error) {
+	from, err := timestampParamToTime(r, "from")
+	if err != nil {
+		return nil, api.BadRequest
+	}
+
+	to, err := timestampParamToTime(r, "to")
+	if err != nil {
+		return nil, api.BadRequest
+	}
+
+	cellSize := defaultHeatmapCellSize
+	if _, ok := r.URL.Query()["cell_size"]; ok {
+		cellSize, err = floatParam(r, "cell_size")
+		if err != nil || cellSize < minHeatmapCellSize {
+			return nil, api.BadRequest
+		}
+	}
+
+	var data []storage.StoredData
+	if _, ok := r.URL.Query()["min_latitude"]; ok {
+		bbox, err := boundingBoxParams(r)
+		if err != nil {
+			return nil, api.BadRequest
+		}
+		data, err = h.aggr.RetrieveArea(bbox, from, to)
+	} else {
+		data, err = h.aggr.RetrieveTimerange(from, to)
+	}
+	if err != nil {
+		return nil, api.InternalServerError
+	}
+
+	g := heatmap.DegreesGrid(data, cellSize)
+	response := heatmapResponse{
+		CellSize: cellSize,
+		Max:      g.Max(),
+		Cells:    toHeatmapCells(g),
+	}
+	return response, nil
+}
+
+// HeatmapTile returns the heatmap for a slippy map tile either as JSON or
+// as a PNG image depending on the extension.
+func (h *handler) HeatmapTile(r *http.Request, ps httprouter.Params) (interface{}, api.Error) {
+	from, err := timestampParamToTime(r, "from")
+	if err != nil {
+		return nil, api.BadRequest
+	}
+
+	to, err := timestampParamToTime(r, "to")
+	if err != nil {
+		return nil, api.BadRequest
+	}
+
+	y := ps.ByName("y")
+	extension := "json"
+	if i := strings.LastIndex(y, "."); i >= 0 {
+		extension = y[i+1:]
+		y = y[:i]
+	}
+	if extension != "json" && extension != "png" {
+		return nil, api.BadRequest
+	}
+
+	tile, err := tileParams(ps.ByName("z"), ps.ByName("x"), y)
+	if err != nil {
+		return nil, api.BadRequest
+	}
+
+	size := defaultHeatmapTileCells
+	if text := r.URL.Query().Get("size"); text != "" {
+		size, err = strconv.Atoi(text)
+		if err != nil || size < 1 || size > maxHeatmapTileCells {
+			return nil, api.BadRequest
+		}
+	}
+
+	data, err := h.aggr.RetrieveArea(tile.BoundingBox(), from, to)
+	if err != nil {
+		return nil, api.InternalServerError
+	}
+
+	if extension == "png" {
+		return pngResponse{heatmap.RenderTile(data, tile)}, nil
+	}
+
+	g := heatmap.TileGrid(data, tile, size)
+	response := heatmapTileResponse{
+		Z:     tile.Z,
+		X:     tile.X,
+		Y:     tile.Y,
+		Size:  size,
+		Max:   g.Max(),
+		Cells: toHeatmapCells(g),
+	}
+	return response, nil
+}
+
+func tileParams(zText, xText, yText string) (heatmap.Tile, error) {
+	z, err := strconv.Atoi(zText)
+	if err != nil {
+		return heatmap.Tile{}, err
+	}
+	x, err := strconv.Atoi(xText)
+	if err != nil {
+		return heatmap.Tile{}, err
+	}
+	y, err := strconv.Atoi(yText)
+	if err != nil {
+		return heatmap.Tile{}, err
+	}
+	return heatmap.NewTile(z, x, y)
+}
+
+func toHeatmapCells(g *heatmap.Grid) []heatmapCell {
+	rv := make([]heatmapCell, 0, len(g.Cells))
+	for cell, count := range g.Cells {
+		rv = append(rv, heatmapCell{cell.X, cell.Y, count})
+	}
+	sort.Slice(rv, func(i, j int) bool {
+		if rv[i].Y != rv[j].Y {
+			return rv[i].Y < rv[j].Y
+		}
+		return rv[i].X < rv[j].X
+	})
+	return rv
+}
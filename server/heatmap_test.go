@@ -0,0 +1,122 @@
+package server
+
+import (
+	"fmt"
+	"github.com/boreq/flightradar-backend/aggregator"
+	"github.com/boreq/flightradar-backend/server/api"
+	"github.com/boreq/flightradar-backend/storage"
+	"github.com/boreq/flightradar-backend/storage/memory"
+	"github.com/julienschmidt/httprouter"
+	"image/png"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func createHeatmapHandler(t *testing.T) *handler {
+	s := memory.New(0, stationPosition())
+	for i, position := range [][2]float64{{50.06, 19.94}, {50.061, 19.941}, {52.23, 21.01}} {
+		icao := fmt.Sprintf("%06d", i)
+		lat := position[0]
+		lon := position[1]
+		data := storage.StoredData{
+			Time: time.Unix(100, 0),
+			Data: storage.Data{Icao: &icao, Latitude: &lat, Longitude: &lon},
+		}
+		if err := s.Store(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &handler{aggr: aggregator.New(s), location: time.UTC}
+}
+
+func TestHeatmap(t *testing.T) {
+	h := createHeatmapHandler(t)
+
+	response, apiErr := h.Heatmap(httptest.NewRequest("GET", "/heatmap.json?from=0&to=200&cell_size=1", nil), nil)
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	r := response.(heatmapResponse)
+	if r.Max != 2 || len(r.Cells) != 2 {
+		t.Fatalf("Invalid response %v", r)
+	}
+	if r.Cells[0] != (heatmapCell{19, 50, 2}) || r.Cells[1] != (heatmapCell{21, 52, 1}) {
+		t.Fatalf("Invalid cells %v", r.Cells)
+	}
+}
+
+func TestHeatmapTilePNG(t *testing.T) {
+	h := createHeatmapHandler(t)
+
+	router := httprouter.New()
+	router.GET("/heatmap/:z/:x/:y", api.Wrap(h.HeatmapTile))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/heatmap/10/568/347.png?from=0&to=200", nil))
+	if w.Code != 200 {
+		t.Fatalf("Invalid code %d", w.Code)
+	}
+	if w.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("Invalid content type %s", w.Header().Get("Content-Type"))
+	}
+	if _, err := png.Decode(w.Body); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHeatmapTileJSON(t *testing.T) {
+	h := createHeatmapHandler(t)
+
+	params := httprouter.Params{{Key: "z", Value: "10"}, {Key: "x", Value: "568"}, {Key: "y", Value: "347.json"}}
+	response, apiErr := h.HeatmapTile(httptest.NewRequest("GET", "/heatmap/10/568/347.json?from=0&to=200&size=1", nil), params)
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	r := response.(heatmapTileResponse)
+	if r.Max != 2 || len(r.Cells) != 1 {
+		t.Fatalf("Invalid response %v", r)
+	}
+}
+
+func TestInvalidBoundingBox(t *testing.T) {
+	h := createHeatmapHandler(t)
+
+	testCases := []string{
+		"min_latitude=-1e9&max_latitude=1e9&min_longitude=19&max_longitude=20",
+		"min_latitude=50&max_latitude=51&min_longitude=-1e9&max_longitude=1e9",
+		"min_latitude=NaN&max_latitude=51&min_longitude=19&max_longitude=20",
+		"min_latitude=50&max_latitude=51&min_longitude=19&max_longitude=Inf",
+		"min_latitude=-Inf&max_latitude=51&min_longitude=19&max_longitude=20",
+		"min_latitude=-91&max_latitude=51&min_longitude=19&max_longitude=20",
+		"min_latitude=50&max_latitude=51&min_longitude=19&max_longitude=181",
+		"min_latitude=51&max_latitude=50&min_longitude=19&max_longitude=20",
+		"min_latitude=50&max_latitude=51&min_longitude=19",
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase, func(t *testing.T) {
+			url := "/area.json?from=0&to=200&" + testCase
+			if _, apiErr := h.Area(httptest.NewRequest("GET", url, nil), nil); apiErr != api.BadRequest {
+				t.Errorf("Area: expected bad request, got %v", apiErr)
+			}
+			url = "/heatmap.json?from=0&to=200&" + testCase
+			if _, apiErr := h.Heatmap(httptest.NewRequest("GET", url, nil), nil); apiErr != api.BadRequest {
+				t.Errorf("Heatmap: expected bad request, got %v", apiErr)
+			}
+		})
+	}
+}
+
+func TestWholeWorldBoundingBox(t *testing.T) {
+	h := createHeatmapHandler(t)
+
+	url := "/heatmap.json?from=0&to=200&cell_size=1&min_latitude=-90&max_latitude=90&min_longitude=-180&max_longitude=180"
+	response, apiErr := h.Heatmap(httptest.NewRequest("GET", url, nil), nil)
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	if r := response.(heatmapResponse); r.Max != 2 || len(r.Cells) != 2 {
+		t.Fatalf("Invalid response %v", r)
+	}
+}
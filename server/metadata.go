@@ -0,0 +1,145 @@
+package server
+
+import (
+	"github.com/boreq/flightradar-backend/icao"
+	"github.com/boreq/flightradar-backend/metadata"
+	"github.com/boreq/flightradar-backend/storage"
+)
+
+// Metadata holds the optional databases used to extend the responses with
+// the information which isn't broadcast by the aircraft. Any of them can be
+// nil.
+type Metadata struct {
+	Aircraft *metadata.Database
+	Airlines *metadata.Airlines
+	Routes   *metadata.Routes
+}
+
+// aircraftInfo holds the information about an aircraft which isn't broadcast
+// by it.
+type aircraftInfo struct {
+	// Aircraft is set if the aircraft is present in the aircraft
+	// database.
+	Aircraft *metadata.Aircraft `json:"aircraft,omitempty"`
+
+	// Country and CountryCode describe the state of registry decoded from
+	// the ICAO address.
+	Country     string `json:"country,omitempty"`
+	CountryCode string `json:"country_code,omitempty"`
+
+	// Military is true if the ICAO address belongs to a military block or
+	// the aircraft database marks the aircraft as military.
+	Military bool `json:"military,omitempty"`
+}
+
+// flightInfo holds the information inferred from the flight number.
+type flightInfo struct {
+	// Airline is set if the flight number is a callsign of an airline.
+	// Only the designator is set if the airline is unknown.
+	Airline *metadata.Airline `json:"airline,omitempty"`
+
+	// Route is set if the flight is present in the route table.
+	Route *metadata.Route `json:"route,omitempty"`
+}
+
+// planeResponse is the newest data of a plane with the information about the
+// aircraft and the flight.
+type planeResponse struct {
+	storage.Data
+	aircraftInfo
+	flightInfo
+}
+
+// storedDataResponse is a data point with the information about the
+// aircraft and the flight.
+type storedDataResponse struct {
+	storage.StoredData
+	aircraftInfo
+	flightInfo
+}
+
+// infoCache looks up the information about the aircraft and the flights
+// remembering the results so that each of them is looked up only once per
+// response.
+type infoCache struct {
+	metadata Metadata
+	aircraft map[string]aircraftInfo
+	flights  map[string]flightInfo
+}
+
+func newInfoCache(metadata Metadata) *infoCache {
+	return &infoCache{
+		metadata: metadata,
+		aircraft: make(map[string]aircraftInfo),
+		flights:  make(map[string]flightInfo),
+	}
+}
+
+func (c *infoCache) Aircraft(icaoAddress *string) aircraftInfo {
+	if icaoAddress == nil {
+		return aircraftInfo{}
+	}
+	if info, ok := c.aircraft[*icaoAddress]; ok {
+		return info
+	}
+	var rv aircraftInfo
+	if allocation, ok := icao.Lookup(*icaoAddress); ok {
+		rv.Country = allocation.Country
+		rv.CountryCode = allocation.CountryCode
+		rv.Military = allocation.Military
+	}
+	if a, ok := c.metadata.Aircraft.Lookup(*icaoAddress); ok {
+		rv.Aircraft = &a
+		rv.Military = rv.Military || a.Military
+	}
+	c.aircraft[*icaoAddress] = rv
+	return rv
+}
+
+func (c *infoCache) Flight(flightNumber *string) flightInfo {
+	if flightNumber == nil {
+		return flightInfo{}
+	}
+	if info, ok := c.flights[*flightNumber]; ok {
+		return info
+	}
+	var rv flightInfo
+	if callsign, ok := metadata.ParseCallsign(*flightNumber); ok {
+		airline, ok := c.metadata.Airlines.Lookup(callsign.Airline)
+		if !ok {
+			airline = metadata.Airline{Icao: callsign.Airline}
+		}
+		rv.Airline = &airline
+	}
+	if route, ok := c.metadata.Routes.Lookup(*flightNumber); ok {
+		rv.Route = &route
+	}
+	c.flights[*flightNumber] = rv
+	return rv
+}
+
+func (h *handler) planesWithInfo(data []storage.Data) []planeResponse {
+	cache := newInfoCache(h.metadata)
+	rv := make([]planeResponse, 0, len(data))
+	for _, d := range data {
+		rv = append(rv, planeResponse{
+			Data:         d,
+			aircraftInfo: cache.Aircraft(d.Icao),
+			flightInfo:   cache.Flight(d.FlightNumber),
+		})
+	}
+	return rv
+}
+
+func (h *handler) storedDataWithInfo(data []storage.StoredData) []storedDataResponse {
+	cache := newInfoCache(h.metadata)
+	rv := make([]storedDataResponse, 0, len(data))
+	for _, d := range data {
+		rv = append(rv, storedDataResponse{
+			StoredData:   d,
+			aircraftInfo: cache.Aircraft(d.Data.Icao),
+			flightInfo:   cache.Flight(d.Data.FlightNumber),
+		})
+	}
+	return rv
+}
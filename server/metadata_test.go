@@ -0,0 +1,117 @@
+package server
+
+import (
+	"github.com/boreq/flightradar-backend/aggregator"
+	"github.com/boreq/flightradar-backend/metadata"
+	"github.com/boreq/flightradar-backend/storage"
+	"github.com/boreq/flightradar-backend/storage/memory"
+	"github.com/julienschmidt/httprouter"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPlaneWithAircraftInfo(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.csv")
+	if err := ioutil.WriteFile(source, []byte("icao,registration\naaaaaa,SP-LWA\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	destination := filepath.Join(dir, "aircraft.csv")
+	if _, err := metadata.Update(source, metadata.FormatCSV, destination); err != nil {
+		t.Fatal(err)
+	}
+	database, err := metadata.Load(destination)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := memory.New(0, stationPosition())
+	for _, icao := range []string{"aaaaaa", "bbbbbb", "48ae22"} {
+		icao := icao
+		if err := s.Store(storage.StoredData{Time: time.Unix(10, 0), Data: storage.Data{Icao: &icao}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &handler{aggr: aggregator.New(s), metadata: Metadata{Aircraft: database}, location: time.UTC}
+
+	testCases := []struct {
+		Icao         string
+		Registration string
+		Country      string
+	}{
+		{"aaaaaa", "SP-LWA", "United States"},
+		{"bbbbbb", "", ""},
+		{"48ae22", "", "Poland"},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Icao, func(t *testing.T) {
+			ps := httprouter.Params{{Key: "icao", Value: testCase.Icao + ".json"}}
+			response, apiErr := h.Plane(httptest.NewRequest("GET", "/plane/"+testCase.Icao+".json", nil), ps)
+			if apiErr != nil {
+				t.Fatal(apiErr)
+			}
+			data := response.([]storedDataResponse)
+			if len(data) != 1 {
+				t.Fatalf("Wrong data %v", data)
+			}
+			registration := ""
+			if data[0].Aircraft != nil {
+				registration = data[0].Aircraft.Registration
+			}
+			if registration != testCase.Registration {
+				t.Errorf("Wrong registration %q", registration)
+			}
+			if data[0].Country != testCase.Country {
+				t.Errorf("Wrong country %q", data[0].Country)
+			}
+		})
+	}
+}
+
+func TestFlightInfo(t *testing.T) {
+	dir := t.TempDir()
+	airlinesFile := filepath.Join(dir, "airlines.csv")
+	if err := ioutil.WriteFile(airlinesFile, []byte("icao,name\nLOT,LOT Polish Airlines\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	routesFile := filepath.Join(dir, "routes.csv")
+	if err := ioutil.WriteFile(routesFile, []byte("callsign,origin,destination\nLOT3NV,EPWA,EGLL\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	airlines, err := metadata.LoadAirlines(airlinesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes, err := metadata.LoadRoutes(routesFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cache := newInfoCache(Metadata{Airlines: airlines, Routes: routes})
+
+	testCases := []struct {
+		FlightNumber string
+		Airline      *metadata.Airline
+		Route        *metadata.Route
+	}{
+		{"LOT3NV", &metadata.Airline{Icao: "LOT", Name: "LOT Polish Airlines"}, &metadata.Route{Origin: "EPWA", Destination: "EGLL"}},
+		{"LOT12", &metadata.Airline{Icao: "LOT", Name: "LOT Polish Airlines"}, nil},
+		{"RYR1", &metadata.Airline{Icao: "RYR"}, nil},
+		{"SPLWA", nil, nil},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.FlightNumber, func(t *testing.T) {
+			info := cache.Flight(&testCase.FlightNumber)
+			if (info.Airline == nil) != (testCase.Airline == nil) || info.Airline != nil && *info.Airline != *testCase.Airline {
+				t.Errorf("Wrong airline %v", info.Airline)
+			}
+			if (info.Route == nil) != (testCase.Route == nil) || info.Route != nil && *info.Route != *testCase.Route {
+				t.Errorf("Wrong route %v", info.Route)
+			}
+		})
+	}
+}
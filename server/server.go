@@ -4,13 +4,14 @@ import (
 	"fmt"
 	"github.com/boreq/flightradar-backend/aggregator"
 	"github.com/boreq/flightradar-backend/config"
+	"github.com/boreq/flightradar-backend/geo"
+	"github.com/boreq/flightradar-backend/geojson"
 	"github.com/boreq/flightradar-backend/logging"
 	"github.com/boreq/flightradar-backend/server/api"
 	"github.com/boreq/flightradar-backend/storage"
 	"github.com/julienschmidt/httprouter"
 	"math"
 	"net/http"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -19,8 +20,10 @@ import (
 var log = logging.GetLogger("server")
 
 type handler struct {
-	aggr       aggregator.Aggregator
-	statsCache map[string]stats
+	aggr     aggregator.Aggregator
+	backuper storage.Backuper
+	metadata Metadata
+	location *time.Location
 }
 
 func (h *handler) Planes(r *http.Request, _ httprouter.Params) (interface{}, api.Error) {
@@ -30,7 +33,15 @@ func (h *handler) Planes(r *http.Request, _ httprouter.Params) (interface{}, api
 		response = append(response, value)
 	}
 
-	return response, nil
+	geoJSON, apiErr := wantsGeoJSON(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if geoJSON {
+		return geojson.Points(response), nil
+	}
+
+	return h.planesWithInfo(response), nil
 }
 
 func (h *handler) TimeRange(r *http.Request, _ httprouter.Params) (interface{}, api.Error) {
@@ -49,7 +60,31 @@ func (h *handler) TimeRange(r *http.Request, _ httprouter.Params) (interface{},
 		return nil, api.InternalServerError
 	}
 
-	return response, nil
+	return h.tracksResponse(r, "Time range", response)
+}
+
+func (h *handler) Area(r *http.Request, _ httprouter.Params) (interface{}, api.Error) {
+	from, err := timestampParamToTime(r, "from")
+	if err != nil {
+		return nil, api.BadRequest
+	}
+
+	to, err := timestampParamToTime(r, "to")
+	if err != nil {
+		return nil, api.BadRequest
+	}
+
+	bbox, err := boundingBoxParams(r)
+	if err != nil {
+		return nil, api.BadRequest
+	}
+
+	response, err := h.aggr.RetrieveArea(bbox, from, to)
+	if err != nil {
+		return nil, api.InternalServerError
+	}
+
+	return h.tracksResponse(r, "Area", response)
 }
 
 type polarResponse struct {
@@ -73,7 +108,16 @@ func (h *handler) Polar(r *http.Request, _ httprouter.Params) (interface{}, api.
 		return nil, api.InternalServerError
 	}
 
-	return toPolar(data), nil
+	polar := toPolar(data)
+	geoJSON, apiErr := wantsGeoJSON(r)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if geoJSON {
+		return polarToGeoJSON(polar), nil
+	}
+
+	return polar, nil
 }
 
 func (h *handler) Plane(r *http.Request, ps httprouter.Params) (interface{}, api.Error) {
@@ -87,161 +131,41 @@ func (h *handler) Plane(r *http.Request, ps httprouter.Params) (interface{}, api
 		return nil, api.InternalServerError
 	}
 
-	return response, nil
+	return h.tracksResponse(r, icao, response)
 }
 
-type stats struct {
-	DataPointsNumber               int         `json:"data_points_number"`
-	DataPointsAltitudeCrossSection map[int]int `json:"data_points_altitude_cross_section"`
-	PlanesNumber                   int         `json:"planes_number"`
-	FlightsNumber                  int         `json:"flights_number"`
-	AverageDistance                float64     `json:"average_distance"`
-	MedianDistance                 float64     `json:"median_distance"`
-	MaxDistance                    float64     `json:"max_distance"`
-}
-
-type dailyStats struct {
-	Date string `json:"date"`
-	Data stats  `json:"data"`
-}
-
-type statsResponse struct {
-	Stats                    []dailyStats `json:"stats"`
-	AltitudeCrossSectionStep int          `json:"altitude_cross_section_step"`
-}
-
-func (h *handler) Stats(r *http.Request, ps httprouter.Params) (interface{}, api.Error) {
-	response := statsResponse{
-		AltitudeCrossSectionStep: statsAltitudeCrossSectionStep,
-		Stats:                    make([]dailyStats, 0),
-	}
-	for k, v := range h.statsCache {
-		response.Stats = append(response.Stats, dailyStats{k, v})
+func (h *handler) Callsign(r *http.Request, ps httprouter.Params) (interface{}, api.Error) {
+	from, to, err := optionalTimerangeParams(r)
+	if err != nil {
+		return nil, api.BadRequest
 	}
-	sort.Slice(response.Stats, func(i, j int) bool { return response.Stats[i].Date < response.Stats[j].Date })
-	return response, nil
-}
-
-const statsCacheDateLayout = "2006-01-02"
-const statsDataPoints = 30 // number of days stats are generated for
-const statsUpdateEvery = 60 * time.Minute
-const statsAltitudeCrossSectionStep = 5000
 
-func (h *handler) runStats() {
-	h.updateStats()
-
-	ticker := time.NewTicker(statsUpdateEvery)
-	for range ticker.C {
-		h.updateStats()
-	}
-}
-
-func (h *handler) updateStats() {
-	h.cleanupStats()
-	h.loadStats()
-}
-
-func (h *handler) cleanupStats() {
-	for k, _ := range h.statsCache {
-		t, err := time.Parse(statsCacheDateLayout, k)
-		if err != nil {
-			log.Printf("updateStats cleanup error: %s", err)
-			delete(h.statsCache, k)
-		}
-		if time.Since(t) > statsDataPoints*24*time.Hour {
-			log.Debugf("updateStats cleanup: %s", k)
-			delete(h.statsCache, k)
-		}
+	flightNumber := strings.TrimSuffix(ps.ByName("callsign"), ".json")
+	response, err := h.aggr.RetrieveByFlightNumber(flightNumber, from, to)
+	if err != nil {
+		return nil, api.InternalServerError
 	}
-}
 
-func (h *handler) loadStats() {
-	for i := 0; i < statsDataPoints; i++ {
-		start := time.Now().UTC().AddDate(0, 0, -i).Truncate(24 * time.Hour)
-		end := start.AddDate(0, 0, 1)
-		key := start.Format(statsCacheDateLayout)
-		_, ok := h.statsCache[key]
-		if !ok || i <= 1 {
-			log.Debugf("updateStats loading: %s", key)
-			stats, err := h.getStatsForRange(start, end)
-			if err != nil {
-				log.Printf("updateStats error: %s", err)
-				continue
-			}
-			h.statsCache[key] = stats
-		}
-	}
+	return h.tracksResponse(r, flightNumber, response)
 }
 
-const distanceThreshold = 1000
-
-func (h *handler) getStatsForRange(from, to time.Time) (stats, error) {
-	rv := stats{}
-
-	data, err := h.aggr.RetrieveTimerange(from, to)
+func (h *handler) Squawk(r *http.Request, ps httprouter.Params) (interface{}, api.Error) {
+	from, to, err := optionalTimerangeParams(r)
 	if err != nil {
-		return rv, err
-	}
-
-	// Data points calculations
-	uniquePlanes := make(map[string]bool)
-	uniqueFlights := make(map[string]bool)
-	altitudeCrossSection := make(map[int]int)
-	for _, storedData := range data {
-		rv.DataPointsNumber++
-
-		if storedData.Data.Icao != nil {
-			uniquePlanes[*storedData.Data.Icao] = true
-		}
-
-		if storedData.Data.FlightNumber != nil {
-			uniqueFlights[*storedData.Data.FlightNumber] = true
-		}
-
-		var key int = -1
-		if storedData.Data.Altitude != nil {
-			key = *storedData.Data.Altitude / statsAltitudeCrossSectionStep
-		}
-		value, ok := altitudeCrossSection[key]
-		if !ok {
-			value = 0
-		}
-		altitudeCrossSection[key] = value + 1
+		return nil, api.BadRequest
 	}
-	rv.PlanesNumber = len(uniquePlanes)
-	rv.FlightsNumber = len(uniqueFlights)
-	rv.DataPointsAltitudeCrossSection = altitudeCrossSection
-
-	// Range calculations
-	var sum float64 = 0
-	var max float64 = 0
-	var distances []float64
 
-	polar := toPolar(data)
-
-	for _, v := range polar {
-		if v.Distance > distanceThreshold {
-			continue
-		}
-		sum += v.Distance
-		if v.Distance > max {
-			max = v.Distance
-		}
-		distances = append(distances, v.Distance)
+	transponderCode, err := strconv.Atoi(strings.TrimSuffix(ps.ByName("code"), ".json"))
+	if err != nil {
+		return nil, api.BadRequest
 	}
 
-	sort.Slice(distances, func(i, j int) bool { return distances[i] < distances[j] })
-
-	rv.MaxDistance = max
-	if len(distances) > 0 {
-		rv.MedianDistance = distances[len(distances)/2]
-		rv.AverageDistance = sum / float64(len(polar))
-	} else {
-		rv.MedianDistance = 0
-		rv.AverageDistance = 0
+	response, err := h.aggr.RetrieveBySquawk(transponderCode, from, to)
+	if err != nil {
+		return nil, api.InternalServerError
 	}
 
-	return rv, nil
+	return h.tracksResponse(r, fmt.Sprintf("Squawk %04d", transponderCode), response)
 }
 
 func timestampParamToTime(r *http.Request, name string) (time.Time, error) {
@@ -256,6 +180,58 @@ func timestampParamToTime(r *http.Request, name string) (time.Time, error) {
 	return time.Unix(timestamp, 0), nil
 }
 
+// optionalTimerangeParams reads the "from" and "to" parameters. If a
+// parameter is missing the time range is unbounded on that side.
+func optionalTimerangeParams(r *http.Request) (time.Time, time.Time, error) {
+	from := time.Unix(0, 0)
+	to := time.Now()
+	query := r.URL.Query()
+	if _, ok := query["from"]; ok {
+		t, err := timestampParamToTime(r, "from")
+		if err != nil {
+			return from, to, err
+		}
+		from = t
+	}
+	if _, ok := query["to"]; ok {
+		t, err := timestampParamToTime(r, "to")
+		if err != nil {
+			return from, to, err
+		}
+		to = t
+	}
+	return from, to, nil
+}
+
+func floatParam(r *http.Request, name string) (float64, error) {
+	texts, ok := r.URL.Query()[name]
+	if !ok {
+		return 0, fmt.Errorf("Parameter %s missing", name)
+	}
+	return strconv.ParseFloat(texts[0], 64)
+}
+
+func boundingBoxParams(r *http.Request) (storage.BoundingBox, error) {
+	var bbox storage.BoundingBox
+	params := []struct {
+		Name  string
+		Value *float64
+	}{
+		{"min_latitude", &bbox.MinLatitude},
+		{"max_latitude", &bbox.MaxLatitude},
+		{"min_longitude", &bbox.MinLongitude},
+		{"max_longitude", &bbox.MaxLongitude},
+	}
+	for _, param := range params {
+		v, err := floatParam(r, param.Name)
+		if err != nil {
+			return bbox, err
+		}
+		*param.Value = v
+	}
+	return bbox, bbox.Validate()
+}
+
 const multiplier = (2.0 * math.Pi * 6371.0 / 360.0)
 
 func fakeDistance(lon1, lat1, lon2, lat2 float64) float64 {
@@ -265,14 +241,16 @@ func fakeDistance(lon1, lat1, lon2, lat2 float64) float64 {
 }
 
 func fakeBearing(lon1, lat1, lon2, lat2 float64) float64 {
-	return degrees(math.Atan2(lon2-lon1, lat2-lat1))
+	return geo.Degrees(math.Atan2(lon2-lon1, lat2-lat1))
 }
 
 func toPolar(data []storage.StoredData) map[int]polarResponse {
 	// Initial calculations with faster cartesian functions
 	result := make(map[int]polarResponse)
 	for i := 0; i < len(data); i++ {
-		if data[i].Data.Longitude == nil || data[i].Data.Latitude == nil {
+		// The data points merged from other stations were received
+		// from a different position.
+		if data[i].Data.Longitude == nil || data[i].Data.Latitude == nil || data[i].Data.Station != nil {
 			continue
 		}
 		b := fakeBearing(
@@ -288,6 +266,13 @@ func toPolar(data []storage.StoredData) map[int]polarResponse {
 		b = b + 180
 		v, ok := result[int(b)]
 		if !ok || v.Distance < d {
+			if beyondReceiverRange(geo.Distance(
+				config.Config.StationLongitude,
+				config.Config.StationLatitude,
+				*data[i].Data.Longitude,
+				*data[i].Data.Latitude)) {
+				continue
+			}
 			result[int(b)] = polarResponse{
 				Distance: d,
 				Data:     data[i],
@@ -298,7 +283,7 @@ func toPolar(data []storage.StoredData) map[int]polarResponse {
 	// Recalculate the selected points for increased accuracy
 	rv := make(map[int]polarResponse)
 	for k, v := range result {
-		d := distance(
+		d := geo.Distance(
 			config.Config.StationLongitude,
 			config.Config.StationLatitude,
 			*v.Data.Data.Longitude,
@@ -310,19 +295,55 @@ func toPolar(data []storage.StoredData) map[int]polarResponse {
 	return result
 }
 
-func Serve(aggr aggregator.Aggregator, address string) error {
+// beyondReceiverRange returns true if the distance from the station in
+// kilometers exceeds the configured range of the receiver. Such positions
+// are implausible and are ignored when calculating the range.
+func beyondReceiverRange(distance float64) bool {
+	return config.Config.ReceiverRange > 0 && distance > config.Config.ReceiverRange
+}
+
+// stationPosition returns the configured position of the station and the
+// range of its receiver.
+func stationPosition() storage.Position {
+	return storage.Position{
+		Latitude:  config.Config.StationLatitude,
+		Longitude: config.Config.StationLongitude,
+		Range:     config.Config.ReceiverRange,
+	}
+}
+
+// Serve serves the API. The backup endpoint is available only if the backuper
+// isn't nil. The responses are extended using the provided metadata.
+func Serve(aggr aggregator.Aggregator, backuper storage.Backuper, metadata Metadata, address string) error {
+	location, err := time.LoadLocation(config.Config.Timezone)
+	if err != nil {
+		return err
+	}
+	if !wholeHourOffset(time.Now().In(location)) {
+		return fmt.Errorf("Offset of the time zone %s isn't expressed in full hours", location)
+	}
+
 	h := &handler{
-		aggr:       aggr,
-		statsCache: make(map[string]stats),
+		aggr:     aggr,
+		backuper: backuper,
+		metadata: metadata,
+		location: location,
 	}
-	go h.runStats()
 
 	router := httprouter.New()
 	router.GET("/planes.json", api.Wrap(h.Planes))
 	router.GET("/plane/:icao", api.Wrap(h.Plane))
 	router.GET("/range.json", api.Wrap(h.TimeRange))
 	router.GET("/polar.json", api.Wrap(h.Polar))
+	router.GET("/coverage.json", api.Wrap(h.Coverage))
+	router.GET("/heatmap.json", api.Wrap(h.Heatmap))
+	router.GET("/heatmap/:z/:x/:y", api.Wrap(h.HeatmapTile))
+	router.GET("/area.json", api.Wrap(h.Area))
+	router.GET("/callsign/:callsign", api.Wrap(h.Callsign))
+	router.GET("/squawk/:code", api.Wrap(h.Squawk))
 	router.GET("/stats.json", api.Wrap(h.Stats))
+	router.GET("/top/:category", api.Wrap(h.Top))
+	router.GET("/admin/backup", api.Wrap(h.Backup))
 
 	return http.ListenAndServe(address, router)
 }
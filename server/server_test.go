@@ -37,6 +37,34 @@ func TestPolar(t *testing.T) {
 	}
 }
 
+func TestPolarReceiverRange(t *testing.T) {
+	var data []storage.StoredData
+	for i, latitude := range []float64{50.5, 70.0} {
+		d := storage.StoredData{
+			Time: time.Unix(int64(i), 0),
+			Data: storage.Data{
+				Icao:      new(string),
+				Latitude:  new(float64),
+				Longitude: new(float64),
+			},
+		}
+		*d.Data.Icao = fmt.Sprintf("%06d", i)
+		*d.Data.Latitude = latitude
+		*d.Data.Longitude = 19.97605
+		data = append(data, d)
+	}
+
+	polar := toPolar(data)
+	if len(polar) != 1 {
+		t.Fatalf("Wrong polar %v", polar)
+	}
+	for _, v := range polar {
+		if *v.Data.Data.Icao != "000000" {
+			t.Errorf("Wrong data point %v", v.Data)
+		}
+	}
+}
+
 func calculateHash(polar map[int]polarResponse) int64 {
 	var result int64
 	for k, v := range polar {
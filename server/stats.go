@@ -0,0 +1,319 @@
+package server
+
+import (
+	"fmt"
+	"github.com/boreq/flightradar-backend/config"
+	"github.com/boreq/flightradar-backend/icao"
+	"github.com/boreq/flightradar-backend/server/api"
+	"github.com/boreq/flightradar-backend/storage"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"sort"
+	"time"
+)
+
+type stats struct {
+	DataPointsNumber               int            `json:"data_points_number"`
+	DataPointsAltitudeCrossSection map[int]int    `json:"data_points_altitude_cross_section"`
+	PlanesNumber                   int            `json:"planes_number"`
+	FlightsNumber                  int            `json:"flights_number"`
+	PlanesByCountry                map[string]int `json:"planes_by_country"`
+	MilitaryPlanesNumber           int            `json:"military_planes_number"`
+	FlightsByAirline               map[string]int `json:"flights_by_airline"`
+	AverageDistance                float64        `json:"average_distance"`
+	MedianDistance                 float64        `json:"median_distance"`
+	MaxDistance                    float64        `json:"max_distance"`
+
+	// Movements holds the departures, arrivals and overflights of the
+	// nearby airports identified by their ICAO codes.
+	Movements map[string]*storage.AirportStats `json:"movements"`
+
+	// Rejections holds the number of implausible data points rejected for
+	// each reason.
+	Rejections map[storage.RejectionReason]int `json:"rejections"`
+}
+
+// periodStats holds the statistics for a single group of periods. The
+// group is identified by the date field which depending on the granularity
+// contains a date, an hour of the day, a day of the week etc.
+type periodStats struct {
+	Date string `json:"date"`
+	Data stats  `json:"data"`
+}
+
+type statsResponse struct {
+	Stats                    []periodStats `json:"stats"`
+	Granularity              string        `json:"granularity"`
+	Timezone                 string        `json:"timezone"`
+	AltitudeCrossSectionStep int           `json:"altitude_cross_section_step"`
+}
+
+// granularity describes how the statistics are grouped.
+type granularity struct {
+	// Key returns the key of the group containing the period which
+	// starts at the given time.
+	Key func(t time.Time) string
+
+	// Next returns the start of the next period of time which should be
+	// considered when listing all groups in a time range.
+	Next func(t time.Time) time.Time
+
+	// Keys lists all groups if the granularity is cyclic, in that case
+	// the groups don't depend on the time range.
+	Keys []string
+
+	// Daily is set if every group consists of whole days in which case
+	// the daily statistics can be retrieved instead of the hourly ones.
+	Daily bool
+}
+
+func nextHour(t time.Time) time.Time {
+	return t.Add(time.Hour)
+}
+
+func nextDay(t time.Time) time.Time {
+	return t.AddDate(0, 0, 1)
+}
+
+const defaultGranularity = "day"
+
+var granularities = map[string]granularity{
+	"hour": {
+		Key:  func(t time.Time) string { return t.Format("2006-01-02T15") },
+		Next: nextHour,
+	},
+	"day": {
+		Key:   func(t time.Time) string { return t.Format("2006-01-02") },
+		Next:  nextDay,
+		Daily: true,
+	},
+	"week": {
+		Key: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%04d-W%02d", year, week)
+		},
+		Next:  nextDay,
+		Daily: true,
+	},
+	"month": {
+		Key:   func(t time.Time) string { return t.Format("2006-01") },
+		Next:  nextDay,
+		Daily: true,
+	},
+	"hour_of_day": {
+		Key: func(t time.Time) string { return t.Format("15") },
+		Keys: []string{
+			"00", "01", "02", "03", "04", "05", "06", "07", "08", "09", "10", "11",
+			"12", "13", "14", "15", "16", "17", "18", "19", "20", "21", "22", "23",
+		},
+	},
+	"day_of_week": {
+		Key: func(t time.Time) string { return t.Weekday().String() },
+		Keys: []string{
+			time.Monday.String(),
+			time.Tuesday.String(),
+			time.Wednesday.String(),
+			time.Thursday.String(),
+			time.Friday.String(),
+			time.Saturday.String(),
+			time.Sunday.String(),
+		},
+		Daily: true,
+	},
+}
+
+// keys lists the groups in the given time range. The start of the range
+// doesn't have to be aligned with the start of a group, stepping from it
+// can therefore skip over the end of the range and the group containing it
+// is always listed explicitly.
+func (g granularity) keys(from, to time.Time) []string {
+	if g.Keys != nil {
+		return g.Keys
+	}
+	var rv []string
+	add := func(key string) {
+		if len(rv) == 0 || rv[len(rv)-1] != key {
+			rv = append(rv, key)
+		}
+	}
+	for t := from; !t.After(to); t = g.Next(t) {
+		add(g.Key(t))
+	}
+	if !from.After(to) {
+		add(g.Key(to))
+	}
+	return rv
+}
+
+// Stats returns the statistics grouped according to the granularity
+// parameter. By default the daily statistics for the number of days specified
+// in the config are returned. The groups are created in the time zone
+// returned by statsLocation.
+func (h *handler) Stats(r *http.Request, ps httprouter.Params) (interface{}, api.Error) {
+	location, err := h.statsLocation(r)
+	if err != nil {
+		return nil, api.BadRequest
+	}
+
+	granularityName := r.URL.Query().Get("granularity")
+	if granularityName == "" {
+		granularityName = defaultGranularity
+	}
+	g, ok := granularities[granularityName]
+	if !ok {
+		return nil, api.BadRequest
+	}
+
+	from, to, err := statsTimerangeParams(r, location)
+	if err != nil {
+		return nil, api.BadRequest
+	}
+
+	periods, err := h.retrieveStats(from, to, g.Daily && location.String() == h.location.String())
+	if err != nil {
+		log.Printf("Stats error: %s", err)
+		return nil, api.InternalServerError
+	}
+
+	groups := make(map[string]*storage.Stats)
+	for _, period := range periods {
+		key := g.Key(period.Time.In(location))
+		s, ok := groups[key]
+		if !ok {
+			s = storage.NewStats()
+			groups[key] = s
+		}
+		s.Merge(period.Stats)
+	}
+
+	response := statsResponse{
+		Granularity:              granularityName,
+		Timezone:                 location.String(),
+		AltitudeCrossSectionStep: storage.StatsAltitudeCrossSectionStep,
+		Stats:                    make([]periodStats, 0),
+	}
+	for _, key := range g.keys(from, to) {
+		s, ok := groups[key]
+		if !ok {
+			s = storage.NewStats()
+		}
+		response.Stats = append(response.Stats, periodStats{key, toStats(s)})
+	}
+	return response, nil
+}
+
+// retrieveStats returns the statistics for the given time range. If daily is
+// set the daily statistics created in the configured time zone are retrieved
+// for the closed days which are fully contained in the time range.
+func (h *handler) retrieveStats(from, to time.Time, daily bool) ([]storage.StatsForPeriod, error) {
+	if daily {
+		return h.aggr.RetrieveDailyStats(from, to)
+	}
+	return h.aggr.RetrieveStats(from, to)
+}
+
+// statsLocation returns the time zone specified by the tz parameter or the
+// configured time zone. It is used by all endpoints which return the
+// statistics to determine the default time range and to group the
+// statistics.
+func (h *handler) statsLocation(r *http.Request) (*time.Location, error) {
+	tz := r.URL.Query().Get("tz")
+	if tz == "" {
+		return h.location, nil
+	}
+	return time.LoadLocation(tz)
+}
+
+// wholeHourOffset returns true if the offset of the time zone at the given
+// time is expressed in full hours. The statistics are persisted for hourly
+// periods in UTC so they can't be grouped in other time zones.
+func wholeHourOffset(t time.Time) bool {
+	_, offset := t.Zone()
+	return offset%int(time.Hour/time.Second) == 0
+}
+
+// statsTimerangeParams reads the optional "from" and "to" parameters. By
+// default the time range covers the number of days specified in the config.
+// The returned times are expressed in the given location. An error is
+// returned if the offset of the location isn't expressed in full hours.
+func statsTimerangeParams(r *http.Request, location *time.Location) (time.Time, time.Time, error) {
+	to := time.Now().In(location)
+	if _, ok := r.URL.Query()["to"]; ok {
+		t, err := timestampParamToTime(r, "to")
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		to = t.In(location)
+	}
+
+	days := config.Config.StatsHistoryDays
+	if days < 1 {
+		days = 1
+	}
+	from := time.Date(to.Year(), to.Month(), to.Day()-(days-1), 0, 0, 0, 0, location)
+	if _, ok := r.URL.Query()["from"]; ok {
+		t, err := timestampParamToTime(r, "from")
+		if err != nil {
+			return time.Time{}, time.Time{}, err
+		}
+		from = storage.StatsPeriodStart(t).In(location)
+	}
+
+	if !wholeHourOffset(from) || !wholeHourOffset(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("Offset of %s isn't expressed in full hours", location)
+	}
+	return from, to, nil
+}
+
+// unknownCountry groups the planes with addresses outside of the known
+// blocks.
+const unknownCountry = "Unknown"
+
+func toStats(s *storage.Stats) stats {
+	rv := stats{
+		DataPointsNumber:               s.DataPoints,
+		DataPointsAltitudeCrossSection: s.AltitudeCrossSection,
+		PlanesNumber:                   len(s.Planes),
+		FlightsNumber:                  len(s.Flights),
+		PlanesByCountry:                make(map[string]int),
+		FlightsByAirline:               s.FlightsByAirline(),
+		Movements:                      s.Airports,
+		Rejections:                     s.Rejections,
+	}
+
+	// Countries are decoded from the ICAO addresses
+	for address := range s.Planes {
+		allocation, ok := icao.Lookup(address)
+		if !ok {
+			rv.PlanesByCountry[unknownCountry]++
+			continue
+		}
+		rv.PlanesByCountry[allocation.Country]++
+		if allocation.Military {
+			rv.MilitaryPlanesNumber++
+		}
+	}
+
+	// Range calculations
+	var sum float64 = 0
+	var max float64 = 0
+	var distances []float64
+
+	for _, d := range s.Polar {
+		sum += d
+		if d > max {
+			max = d
+		}
+		distances = append(distances, d)
+	}
+
+	sort.Slice(distances, func(i, j int) bool { return distances[i] < distances[j] })
+
+	rv.MaxDistance = max
+	if len(distances) > 0 {
+		rv.MedianDistance = distances[len(distances)/2]
+		rv.AverageDistance = sum / float64(len(distances))
+	}
+
+	return rv
+}
@@ -0,0 +1,403 @@
+package server
+
+import (
+	"fmt"
+	"github.com/boreq/flightradar-backend/aggregator"
+	"github.com/boreq/flightradar-backend/server/api"
+	"github.com/boreq/flightradar-backend/storage"
+	"github.com/boreq/flightradar-backend/storage/memory"
+	"github.com/julienschmidt/httprouter"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStatsGranularity(t *testing.T) {
+	s := memory.New(0, stationPosition())
+	times := []time.Time{
+		time.Date(2018, 1, 29, 7, 10, 0, 0, time.UTC),
+		time.Date(2018, 1, 29, 7, 50, 0, 0, time.UTC),
+		time.Date(2018, 1, 31, 7, 10, 0, 0, time.UTC),
+		time.Date(2018, 2, 5, 20, 0, 0, 0, time.UTC),
+	}
+	for _, tm := range times {
+		icao := "aaaaaa"
+		if err := s.Store(storage.StoredData{Time: tm, Data: storage.Data{Icao: &icao}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &handler{aggr: aggregator.New(s), location: time.UTC}
+
+	from := time.Date(2018, 1, 29, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2018, 2, 5, 23, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		Granularity string
+		Expected    map[string]int
+		Groups      int
+	}{
+		{"day", map[string]int{"2018-01-29": 2, "2018-01-31": 1, "2018-02-05": 1}, 8},
+		{"hour", map[string]int{"2018-01-29T07": 2, "2018-01-31T07": 1, "2018-02-05T20": 1}, 8 * 24},
+		{"week", map[string]int{"2018-W05": 3, "2018-W06": 1}, 2},
+		{"month", map[string]int{"2018-01": 3, "2018-02": 1}, 2},
+		{"hour_of_day", map[string]int{"07": 3, "20": 1}, 24},
+		{"day_of_week", map[string]int{"Monday": 3, "Wednesday": 1}, 7},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Granularity, func(t *testing.T) {
+			url := fmt.Sprintf("/stats.json?granularity=%s&from=%d&to=%d", testCase.Granularity, from.Unix(), to.Unix())
+			response, apiErr := h.Stats(httptest.NewRequest("GET", url, nil), nil)
+			if apiErr != nil {
+				t.Fatal(apiErr)
+			}
+			groups := response.(statsResponse).Stats
+			if len(groups) != testCase.Groups {
+				t.Errorf("Wrong number of groups %d", len(groups))
+			}
+			for _, group := range groups {
+				if group.Data.DataPointsNumber != testCase.Expected[group.Date] {
+					t.Errorf("Wrong number of data points in %s: %d", group.Date, group.Data.DataPointsNumber)
+				}
+			}
+		})
+	}
+}
+
+func TestStatsUnalignedFrom(t *testing.T) {
+	s := memory.New(0, stationPosition())
+	icao := "aaaaaa"
+	tm := time.Date(2018, 2, 1, 8, 30, 0, 0, time.UTC)
+	if err := s.Store(storage.StoredData{Time: tm, Data: storage.Data{Icao: &icao}}); err != nil {
+		t.Fatal(err)
+	}
+	h := &handler{aggr: aggregator.New(s), location: time.UTC}
+
+	testCases := []struct {
+		Granularity string
+		From        time.Time
+		To          time.Time
+		Expected    []string
+	}{
+		{"day", time.Date(2018, 1, 31, 10, 0, 0, 0, time.UTC), time.Date(2018, 2, 1, 9, 0, 0, 0, time.UTC), []string{"2018-01-31", "2018-02-01"}},
+		{"week", time.Date(2018, 1, 25, 10, 0, 0, 0, time.UTC), time.Date(2018, 2, 1, 9, 0, 0, 0, time.UTC), []string{"2018-W04", "2018-W05"}},
+		{"month", time.Date(2018, 1, 31, 10, 0, 0, 0, time.UTC), time.Date(2018, 2, 1, 9, 0, 0, 0, time.UTC), []string{"2018-01", "2018-02"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Granularity, func(t *testing.T) {
+			url := fmt.Sprintf("/stats.json?granularity=%s&from=%d&to=%d", testCase.Granularity, testCase.From.Unix(), testCase.To.Unix())
+			response, apiErr := h.Stats(httptest.NewRequest("GET", url, nil), nil)
+			if apiErr != nil {
+				t.Fatal(apiErr)
+			}
+			groups := response.(statsResponse).Stats
+			if len(groups) != len(testCase.Expected) {
+				t.Fatalf("Wrong groups %v", groups)
+			}
+			for i, group := range groups {
+				if group.Date != testCase.Expected[i] {
+					t.Errorf("Wrong group %s", group.Date)
+				}
+			}
+			if last := groups[len(groups)-1]; last.Data.DataPointsNumber != 1 {
+				t.Errorf("Wrong number of data points in %s: %d", last.Date, last.Data.DataPointsNumber)
+			}
+		})
+	}
+}
+
+func TestStatsInvalidGranularity(t *testing.T) {
+	h := &handler{aggr: aggregator.New(memory.New(0, stationPosition())), location: time.UTC}
+	_, apiErr := h.Stats(httptest.NewRequest("GET", "/stats.json?granularity=invalid", nil), nil)
+	if apiErr == nil {
+		t.Fatal("Expected an error")
+	}
+}
+
+func TestStatsTimezone(t *testing.T) {
+	s := memory.New(0, stationPosition())
+	icao := "aaaaaa"
+	tm := time.Date(2018, 1, 28, 23, 30, 0, 0, time.UTC)
+	if err := s.Store(storage.StoredData{Time: tm, Data: storage.Data{Icao: &icao}}); err != nil {
+		t.Fatal(err)
+	}
+	h := &handler{aggr: aggregator.New(s), location: time.UTC}
+
+	testCases := []struct {
+		Query    string
+		Expected string
+	}{
+		{"", "2018-01-28"},
+		{"&tz=UTC", "2018-01-28"},
+		{"&tz=Europe/Warsaw", "2018-01-29"},
+	}
+
+	for _, testCase := range testCases {
+		url := fmt.Sprintf("/stats.json?from=%d&to=%d%s", tm.Add(-24*time.Hour).Unix(), tm.Add(24*time.Hour).Unix(), testCase.Query)
+		response, apiErr := h.Stats(httptest.NewRequest("GET", url, nil), nil)
+		if apiErr != nil {
+			t.Fatal(apiErr)
+		}
+		for _, group := range response.(statsResponse).Stats {
+			if group.Data.DataPointsNumber > 0 && group.Date != testCase.Expected {
+				t.Errorf("%s: wrong date %s", testCase.Query, group.Date)
+			}
+		}
+	}
+}
+
+func TestStatsTimezoneNotWholeHours(t *testing.T) {
+	h := &handler{aggr: aggregator.New(memory.New(0, stationPosition())), location: time.UTC}
+	params := httprouter.Params{{Key: "category", Value: "planes"}}
+
+	for _, tz := range []string{"Asia/Kolkata", "Asia/Kathmandu", "Invalid/Zone"} {
+		url := "/stats.json?tz=" + tz
+		if _, apiErr := h.Stats(httptest.NewRequest("GET", url, nil), nil); apiErr != api.BadRequest {
+			t.Errorf("%s: expected bad request, got %v", url, apiErr)
+		}
+		url = "/top/planes?tz=" + tz
+		if _, apiErr := h.Top(httptest.NewRequest("GET", url, nil), params); apiErr != api.BadRequest {
+			t.Errorf("%s: expected bad request, got %v", url, apiErr)
+		}
+	}
+
+	if _, apiErr := h.Top(httptest.NewRequest("GET", "/top/planes?tz=Europe/Warsaw", nil), params); apiErr != nil {
+		t.Errorf("Unexpected error %v", apiErr)
+	}
+}
+
+func TestTop(t *testing.T) {
+	s := memory.New(0, stationPosition())
+	start := time.Date(2018, 1, 29, 7, 10, 0, 0, time.UTC)
+	points := []struct {
+		Icao         string
+		FlightNumber string
+		Altitude     int
+		Offset       time.Duration
+	}{
+		{"aaaaaa", "LOT3NV", 10000, 0},
+		{"aaaaaa", "LOT3NV", 12000, 5 * time.Minute},
+		{"aaaaaa", "LOT3NV", 11000, 10 * time.Minute},
+		{"bbbbbb", "LOT12", 38000, 0},
+		{"cccccc", "RYR1", 20000, 0},
+		{"cccccc", "RYR1", 20000, 1 * time.Minute},
+	}
+	for i := range points {
+		p := points[i]
+		data := storage.StoredData{
+			Time: start.Add(p.Offset),
+			Data: storage.Data{
+				Icao:         &p.Icao,
+				FlightNumber: &p.FlightNumber,
+				Altitude:     &p.Altitude,
+			},
+		}
+		if err := s.Store(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &handler{aggr: aggregator.New(s), location: time.UTC}
+
+	testCases := []struct {
+		Category string
+		Expected []string
+	}{
+		{"planes", []string{"aaaaaa", "cccccc", "bbbbbb"}},
+		{"altitude.json", []string{"bbbbbb", "cccccc", "aaaaaa"}},
+		{"airlines", []string{"LOT", "RYR"}},
+		{"duration", []string{"LOT3NV", "RYR1", "LOT12"}},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.Category, func(t *testing.T) {
+			url := fmt.Sprintf("/top/%s?from=%d&to=%d", testCase.Category, start.Unix(), start.Add(3*time.Hour).Unix())
+			params := httprouter.Params{{Key: "category", Value: testCase.Category}}
+			response, apiErr := h.Top(httptest.NewRequest("GET", url, nil), params)
+			if apiErr != nil {
+				t.Fatal(apiErr)
+			}
+			entries := response.(topResponse).Entries
+			if len(entries) != len(testCase.Expected) {
+				t.Fatalf("Wrong number of entries %v", entries)
+			}
+			for i, entry := range entries {
+				if entry.Key != testCase.Expected[i] {
+					t.Errorf("Wrong entry %d: %s != %s", i, entry.Key, testCase.Expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStatsCountries(t *testing.T) {
+	s := memory.New(0, stationPosition())
+	tm := time.Date(2018, 1, 29, 7, 10, 0, 0, time.UTC)
+	for _, icao := range []string{"48ae22", "48d810", "3c4b26", "ffffff"} {
+		icao := icao
+		if err := s.Store(storage.StoredData{Time: tm, Data: storage.Data{Icao: &icao}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &handler{aggr: aggregator.New(s), location: time.UTC}
+
+	url := fmt.Sprintf("/stats.json?from=%d&to=%d", tm.Unix(), tm.Unix())
+	response, apiErr := h.Stats(httptest.NewRequest("GET", url, nil), nil)
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	groups := response.(statsResponse).Stats
+	if len(groups) != 1 {
+		t.Fatalf("Wrong number of groups %d", len(groups))
+	}
+
+	expected := map[string]int{"Poland": 2, "Germany": 1, unknownCountry: 1}
+	countries := groups[0].Data.PlanesByCountry
+	if len(countries) != len(expected) {
+		t.Errorf("Wrong countries %v", countries)
+	}
+	for country, n := range expected {
+		if countries[country] != n {
+			t.Errorf("Wrong number of planes from %s: %d", country, countries[country])
+		}
+	}
+	if groups[0].Data.MilitaryPlanesNumber != 1 {
+		t.Errorf("Wrong number of military planes %d", groups[0].Data.MilitaryPlanesNumber)
+	}
+}
+
+func TestStatsAirlines(t *testing.T) {
+	s := memory.New(0, stationPosition())
+	tm := time.Date(2018, 1, 29, 7, 10, 0, 0, time.UTC)
+	points := []struct {
+		Icao         string
+		FlightNumber string
+	}{
+		{"aaaaaa", "LOT3NV"},
+		{"bbbbbb", "LOT12"},
+		{"cccccc", "RYR1"},
+		{"dddddd", "SPLWA"},
+	}
+	for i := range points {
+		p := points[i]
+		data := storage.StoredData{Time: tm, Data: storage.Data{Icao: &p.Icao, FlightNumber: &p.FlightNumber}}
+		if err := s.Store(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h := &handler{aggr: aggregator.New(s), location: time.UTC}
+
+	url := fmt.Sprintf("/stats.json?from=%d&to=%d", tm.Unix(), tm.Unix())
+	response, apiErr := h.Stats(httptest.NewRequest("GET", url, nil), nil)
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	groups := response.(statsResponse).Stats
+	if len(groups) != 1 {
+		t.Fatalf("Wrong number of groups %d", len(groups))
+	}
+
+	airlines := groups[0].Data.FlightsByAirline
+	if len(airlines) != 2 || airlines["LOT"] != 2 || airlines["RYR"] != 1 {
+		t.Errorf("Wrong airlines %v", airlines)
+	}
+}
+
+func TestStatsMovements(t *testing.T) {
+	s := memory.New(0, stationPosition())
+	tm := time.Date(2018, 1, 29, 7, 10, 0, 0, time.UTC)
+	movements := []storage.Movement{
+		{Airport: "EPKK", Type: storage.MovementDeparture, Runway: "25", Icao: "aaaaaa", Time: tm},
+		{Airport: "EPKK", Type: storage.MovementArrival, Runway: "25", Icao: "bbbbbb", Time: tm.Add(2 * time.Hour)},
+		{Airport: "EPKK", Type: storage.MovementDeparture, Runway: "07", Icao: "cccccc", Time: tm.Add(24 * time.Hour)},
+	}
+	if err := s.StoreMovements(movements); err != nil {
+		t.Fatal(err)
+	}
+	h := &handler{aggr: aggregator.New(s), location: time.UTC}
+
+	url := fmt.Sprintf("/stats.json?from=%d&to=%d", tm.Unix(), tm.Add(24*time.Hour).Unix())
+	response, apiErr := h.Stats(httptest.NewRequest("GET", url, nil), nil)
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	groups := response.(statsResponse).Stats
+	if len(groups) != 2 {
+		t.Fatalf("Wrong number of groups %d", len(groups))
+	}
+
+	first := groups[0].Data.Movements["EPKK"]
+	if first == nil || first.Departures != 1 || first.Arrivals != 1 || first.Runways["25"] != 2 {
+		t.Errorf("Wrong movements %+v", first)
+	}
+	second := groups[1].Data.Movements["EPKK"]
+	if second == nil || second.Departures != 1 || second.Arrivals != 0 || second.Runways["07"] != 1 {
+		t.Errorf("Wrong movements %+v", second)
+	}
+}
+
+func TestStatsReceiverRange(t *testing.T) {
+	s := memory.New(0, stationPosition())
+	tm := time.Date(2018, 1, 29, 7, 10, 0, 0, time.UTC)
+	altitude := 10000
+	positions := []struct {
+		Icao      string
+		Latitude  float64
+		Longitude float64
+	}{
+		{"aaaaaa", 50.5, 19.97605},
+		{"bbbbbb", 30.0, 19.97605},
+	}
+	for i := range positions {
+		p := positions[i]
+		data := storage.StoredData{Time: tm, Data: storage.Data{Icao: &p.Icao, Latitude: &p.Latitude, Longitude: &p.Longitude, Altitude: &altitude}}
+		if err := s.Store(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rejections := []storage.Rejection{
+		{Icao: "cccccc", Reason: storage.RejectionSpeed, Time: tm},
+	}
+	if err := s.StoreRejections(rejections); err != nil {
+		t.Fatal(err)
+	}
+	h := &handler{aggr: aggregator.New(s), location: time.UTC}
+
+	url := fmt.Sprintf("/stats.json?from=%d&to=%d", tm.Unix(), tm.Unix())
+	response, apiErr := h.Stats(httptest.NewRequest("GET", url, nil), nil)
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	groups := response.(statsResponse).Stats
+	if len(groups) != 1 {
+		t.Fatalf("Wrong number of groups %d", len(groups))
+	}
+
+	if d := groups[0].Data.MaxDistance; d < 40 || d > 50 {
+		t.Errorf("Wrong max distance %f", d)
+	}
+	if r := groups[0].Data.Rejections; len(r) != 1 || r[storage.RejectionSpeed] != 1 {
+		t.Errorf("Wrong rejections %v", r)
+	}
+
+	url = fmt.Sprintf("/top/distance?from=%d&to=%d", tm.Unix(), tm.Unix())
+	params := httprouter.Params{{Key: "category", Value: "distance"}}
+	response, apiErr = h.Top(httptest.NewRequest("GET", url, nil), params)
+	if apiErr != nil {
+		t.Fatal(apiErr)
+	}
+	if entries := response.(topResponse).Entries; len(entries) != 1 || entries[0].Key != "aaaaaa" {
+		t.Errorf("Wrong top entries %v", entries)
+	}
+
+	band := storage.CoverageBand(altitude)
+	for _, url := range []string{"/coverage.json", fmt.Sprintf("/coverage.json?from=%d&to=%d", tm.Unix(), tm.Unix())} {
+		response, apiErr = h.Coverage(httptest.NewRequest("GET", url, nil), nil)
+		if apiErr != nil {
+			t.Fatal(apiErr)
+		}
+		if outline := response.([]coverageBand)[band].Outline; len(outline) != 1 || outline[0].Latitude != 50.5 {
+			t.Errorf("%s: wrong outline %v", url, outline)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package server
+
+import (
+	"github.com/boreq/flightradar-backend/metadata"
+	"github.com/boreq/flightradar-backend/server/api"
+	"github.com/boreq/flightradar-backend/storage"
+	"github.com/julienschmidt/httprouter"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultTopLimit = 10
+const maxTopLimit = 1000
+
+type topEntry struct {
+	Key   string  `json:"key"`
+	Value float64 `json:"value"`
+
+	// Track is set only for the entries which describe tracked flights.
+	Track *storage.Track `json:"track,omitempty"`
+
+	// Airline is set only for the entries which describe known airlines.
+	Airline *metadata.Airline `json:"airline,omitempty"`
+}
+
+type topResponse struct {
+	Category string     `json:"category"`
+	From     time.Time  `json:"from"`
+	To       time.Time  `json:"to"`
+	Entries  []topEntry `json:"entries"`
+}
+
+// Top returns a leaderboard for the category specified in the URL. The time
+// range and the time zone can be specified using the same parameters as in
+// case of the statistics.
+func (h *handler) Top(r *http.Request, ps httprouter.Params) (interface{}, api.Error) {
+	category := storage.TopCategory(strings.TrimSuffix(ps.ByName("category"), ".json"))
+	if !storage.ValidTopCategory(category) {
+		return nil, api.BadRequest
+	}
+
+	limit := defaultTopLimit
+	if text := r.URL.Query().Get("limit"); text != "" {
+		l, err := strconv.Atoi(text)
+		if err != nil || l < 1 || l > maxTopLimit {
+			return nil, api.BadRequest
+		}
+		limit = l
+	}
+
+	location, err := h.statsLocation(r)
+	if err != nil {
+		return nil, api.BadRequest
+	}
+
+	from, to, err := statsTimerangeParams(r, location)
+	if err != nil {
+		return nil, api.BadRequest
+	}
+
+	top, err := h.aggr.RetrieveTop(category, from, to, limit)
+	if err != nil {
+		log.Printf("Top error: %s", err)
+		return nil, api.InternalServerError
+	}
+
+	response := topResponse{
+		Category: string(category),
+		From:     from,
+		To:       to,
+		Entries:  make([]topEntry, 0, len(top)),
+	}
+	for _, entry := range top {
+		e := topEntry{
+			Key:   entry.Key,
+			Value: entry.Value,
+			Track: entry.Track,
+		}
+		if category == storage.TopAirlines {
+			if airline, ok := h.metadata.Airlines.Lookup(entry.Key); ok {
+				e.Airline = &airline
+			}
+		}
+		response.Entries = append(response.Entries, e)
+	}
+	return response, nil
+}
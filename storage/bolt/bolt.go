@@ -3,12 +3,14 @@ package bolt
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"github.com/boltdb/bolt"
 	"github.com/boreq/flightradar-backend/logging"
 	"github.com/boreq/flightradar-backend/storage"
 	"github.com/boreq/flightradar-backend/storage/bolt/messages"
 	"github.com/golang/protobuf/proto"
 	"io"
+	"os"
 	"time"
 )
 
@@ -24,12 +26,116 @@ var planesKey = []byte("planes")
 // the verying number of nanosecond digits.
 const rfc3339NanoSortable = "2006-01-02T15:04:05.000000000Z07:00"
 
+// Length of the keys created using timeToKey.
+const timeKeyLength = 30
+
+// recordVersion is the version of the format used to encode the records.
+// Version 1 records store time in seconds, version 2 records store time in
+// nanoseconds.
+const recordVersion = 2
+
 type Bolt interface {
 	storage.Storage
+	storage.Backuper
 	io.Closer
 }
 
-func New(filepath string) (Bolt, error) {
+// Options describe how the statistics and the coverage are calculated.
+type Options struct {
+	// Station is the position of the station used to calculate the
+	// bearings and distances and the range of its receiver.
+	Station storage.Position
+
+	// Location is the time zone in which the days are determined when
+	// creating the daily statistics. The daily statistics are recreated
+	// when it changes.
+	Location *time.Location
+}
+
+// New opens the database located at the given path and applies the pending
+// migrations to it. The statistics and the coverage are recreated if the
+// position or the range of the station changed.
+func New(filepath string, options Options) (Bolt, error) {
+	db, err := open(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := migrate(db, filepath, options); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := initStation(db, options); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := initDailyStats(db, options.Location); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	rv := &blt{
+		db:      db,
+		options: options,
+	}
+	return rv, nil
+}
+
+// NewReadOnly opens the database located at the given path without modifying
+// it. It can be opened by multiple programs at the same time as long as none
+// of them writes to it. The database must be up to date as the pending
+// migrations can't be applied.
+func NewReadOnly(filepath string) (Bolt, error) {
+	db, err := openReadOnly(filepath)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := getLegacySchemaVersion(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if version < len(migrations) {
+		db.Close()
+		return nil, fmt.Errorf("Database %s has pending migrations, run the migrate command first", filepath)
+	}
+
+	location, err := getDailyStatsLocation(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	rv := &blt{
+		db:      db,
+		options: Options{Location: location},
+	}
+	return rv, nil
+}
+
+// openReadOnly opens the existing database in the read-only mode. Unlike
+// bolt.Open it doesn't create the file if it doesn't exist.
+func openReadOnly(filepath string) (*bolt.DB, error) {
+	return openExisting(filepath, true)
+}
+
+// openExisting opens the existing database without creating the buckets. An
+// error is returned instead of creating the file if it doesn't exist.
+func openExisting(filepath string, readOnly bool) (*bolt.DB, error) {
+	if _, err := os.Stat(filepath); err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("Database %s does not exist", filepath)
+		}
+		return nil, err
+	}
+	return bolt.Open(filepath, 0644, &bolt.Options{Timeout: 10 * time.Second, ReadOnly: readOnly})
+}
+
+// open opens the database and creates the buckets if needed.
+func open(filepath string) (*bolt.DB, error) {
 	// Open the database, create it if needed. Timeout ensures that the
 	// function will not block idefinietly.
 	db, err := bolt.Open(filepath, 0644, &bolt.Options{Timeout: 10 * time.Second})
@@ -39,6 +145,10 @@ func New(filepath string) (Bolt, error) {
 
 	// Precreate the buckets - makes the future writes faster.
 	err = db.Update(func(tx *bolt.Tx) error {
+		// Metadata bucket.
+		if err := initSchemaVersion(tx); err != nil {
+			return err
+		}
 		// General bucket.
 		if _, err := tx.CreateBucketIfNotExists(generalKey); err != nil {
 			return err
@@ -47,20 +157,37 @@ func New(filepath string) (Bolt, error) {
 		if _, err := tx.CreateBucketIfNotExists(planesKey); err != nil {
 			return err
 		}
+		// Statistics bucket.
+		if _, err := tx.CreateBucketIfNotExists(statsKey); err != nil {
+			return err
+		}
+		// Daily statistics bucket.
+		if _, err := tx.CreateBucketIfNotExists(dailyStatsKey); err != nil {
+			return err
+		}
+		// Coverage bucket.
+		if _, err := tx.CreateBucketIfNotExists(coverageKey); err != nil {
+			return err
+		}
+		// Index buckets.
+		for _, index := range indexes {
+			if _, err := tx.CreateBucketIfNotExists(index.Key); err != nil {
+				return err
+			}
+		}
 		return nil
 	})
 	if err != nil {
+		db.Close()
 		return nil, err
 	}
 
-	rv := &blt{
-		db: db,
-	}
-	return rv, nil
+	return db, nil
 }
 
 type blt struct {
-	db *bolt.DB
+	db      *bolt.DB
+	options Options
 }
 
 func (b *blt) Store(data storage.StoredData) error {
@@ -74,32 +201,127 @@ func (b *blt) Store(data storage.StoredData) error {
 	}
 
 	err = b.db.Batch(func(tx *bolt.Tx) error {
-		// Store the data in the general bucket.
-		generalB := tx.Bucket(generalKey)
-		if generalB == nil {
-			return errors.New("General bucket does not exist!")
+		batch := newBatch(b.options)
+		if err := batch.store(tx, data, j); err != nil {
+			return err
 		}
-		if err := generalB.Put(timeAndIcaoToKey(data.Time, *data.Data.Icao), j); err != nil {
+		return batch.flush(tx)
+	})
+
+	return err
+}
+
+// StoreBatch stores all data points in a single transaction.
+func (b *blt) StoreBatch(data []storage.StoredData) error {
+	encoded := make([][]byte, len(data))
+	for i, d := range data {
+		if d.Data.Icao == nil || *d.Data.Icao == "" {
+			return errors.New("ICAO can't be empty!")
+		}
+		j, err := encode(d)
+		if err != nil {
 			return err
 		}
+		encoded[i] = j
+	}
 
-		// Store the data in the plane specific bucket.
-		planesB := tx.Bucket(planesKey)
-		if planesB == nil {
-			return errors.New("Planes bucket does not exist!")
+	return b.db.Update(func(tx *bolt.Tx) error {
+		batch := newBatch(b.options)
+		for i, d := range data {
+			if err := batch.store(tx, d, encoded[i]); err != nil {
+				return err
+			}
 		}
-		planeB, err := planesB.CreateBucketIfNotExists([]byte(*data.Data.Icao))
+		return batch.flush(tx)
+	})
+}
+
+func (b *blt) StoreMovements(movements []storage.Movement) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return addMovementsToStats(tx, movements, b.options.Location)
+	})
+}
+
+func (b *blt) StoreRejections(rejections []storage.Rejection) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return addRejectionsToStats(tx, rejections, b.options.Location)
+	})
+}
+
+// batch accumulates the statistics and the coverage of the data points stored
+// in a single transaction. Updating them once per transaction instead of once
+// per data point avoids decoding and encoding the stored statistics for every
+// data point.
+type batch struct {
+	options  Options
+	stats    periodStats
+	coverage *storage.Coverage
+}
+
+func newBatch(options Options) *batch {
+	return &batch{
+		options:  options,
+		stats:    make(periodStats),
+		coverage: storage.NewCoverage(),
+	}
+}
+
+// store places the encoded data point in all buckets and indexes. The
+// statistics and the coverage are written when flush is called.
+func (bt *batch) store(tx *bolt.Tx, data storage.StoredData, j []byte) error {
+	key := timeAndIcaoToKey(data.Time, *data.Data.Icao)
+
+	// Store the data in the general bucket.
+	generalB := tx.Bucket(generalKey)
+	if generalB == nil {
+		return errors.New("General bucket does not exist!")
+	}
+
+	// Remove the data point which is about to be replaced from the
+	// indexes as it may have been indexed under different entries. The
+	// statistics are updated only when new data points are stored as they
+	// can't be reverted.
+	previous := generalB.Get(key)
+	if previous != nil {
+		previousData, err := decode(previous)
 		if err != nil {
 			return err
 		}
-		if err := planeB.Put(timeToKey(data.Time), j); err != nil {
+		if err := removeFromIndexes(tx, key, previousData); err != nil {
 			return err
 		}
+	} else {
+		bt.stats.get(data.Time).Add(data, bt.options.Station)
+	}
 
-		return nil
-	})
+	if err := generalB.Put(key, j); err != nil {
+		return err
+	}
 
-	return err
+	// Store the data in the plane specific bucket.
+	planesB := tx.Bucket(planesKey)
+	if planesB == nil {
+		return errors.New("Planes bucket does not exist!")
+	}
+	planeB, err := planesB.CreateBucketIfNotExists([]byte(*data.Data.Icao))
+	if err != nil {
+		return err
+	}
+	if err := planeB.Put(timeToKey(data.Time), j); err != nil {
+		return err
+	}
+
+	bt.coverage.Add(data, bt.options.Station)
+
+	return addToIndexes(tx, key, data)
+}
+
+// flush writes the accumulated statistics and coverage.
+func (bt *batch) flush(tx *bolt.Tx) error {
+	if err := mergeStats(tx, bt.stats, bt.options.Location); err != nil {
+		return err
+	}
+	return mergeCoverage(tx, bt.coverage)
 }
 
 func (b *blt) Retrieve(icao string) ([]storage.StoredData, error) {
@@ -117,17 +339,17 @@ func (b *blt) Retrieve(icao string) ([]storage.StoredData, error) {
 		}
 
 		planeB := planesB.Bucket([]byte(icao))
-		if planeB != nil {
-			planeB.ForEach(func(k, v []byte) error {
-				storedData, err := decode(v)
-				if err != nil {
-					return err
-				}
-				rv = append(rv, storedData)
-				return nil
-			})
+		if planeB == nil {
+			return nil
 		}
-		return nil
+		return planeB.ForEach(func(k, v []byte) error {
+			storedData, err := decode(v)
+			if err != nil {
+				return err
+			}
+			rv = append(rv, storedData)
+			return nil
+		})
 	})
 	if err != nil {
 		return nil, err
@@ -154,7 +376,7 @@ func (b *blt) RetrieveTimerange(from time.Time, to time.Time) ([]storage.StoredD
 		min := timeToKey(from)
 		max := timeToKey(to)
 
-		for k, v := c.Seek(min); k != nil && bytes.Compare(k[0:30], max) <= 0; k, v = c.Next() {
+		for k, v := c.Seek(min); k != nil && bytes.Compare(k[0:timeKeyLength], max) <= 0; k, v = c.Next() {
 			storedData, err := decode(v)
 			if err != nil {
 				return err
@@ -171,6 +393,56 @@ func (b *blt) RetrieveTimerange(from time.Time, to time.Time) ([]storage.StoredD
 	return rv, nil
 }
 
+func (b *blt) RetrievePoint(icao string, t time.Time) (*storage.StoredData, error) {
+	var rv *storage.StoredData
+	err := b.db.View(func(tx *bolt.Tx) error {
+		generalB := tx.Bucket(generalKey)
+		if generalB == nil {
+			return errors.New("General bucket does not exist!")
+		}
+		v := generalB.Get(timeAndIcaoToKey(t, icao))
+		if v == nil {
+			return nil
+		}
+		storedData, err := decode(v)
+		if err != nil {
+			return err
+		}
+		rv = &storedData
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// Iterate executes the function within a single read-only transaction.
+func (b *blt) Iterate(from time.Time, to time.Time, fn func(storage.StoredData) error) error {
+	return b.db.View(func(tx *bolt.Tx) error {
+		generalB := tx.Bucket(generalKey)
+		if generalB == nil {
+			return errors.New("General bucket does not exist!")
+		}
+
+		c := generalB.Cursor()
+		min := timeToKey(from)
+		max := timeToKey(to)
+
+		for k, v := c.Seek(min); k != nil && bytes.Compare(k[0:timeKeyLength], max) <= 0; k, v = c.Next() {
+			storedData, err := decode(v)
+			if err != nil {
+				return err
+			}
+			if err := fn(storedData); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
 func (b *blt) RetrieveAll() ([]storage.StoredData, error) {
 	var rv []storage.StoredData
 
@@ -185,7 +457,7 @@ func (b *blt) RetrieveAll() ([]storage.StoredData, error) {
 			return errors.New("General bucket does not exist!")
 		}
 
-		generalB.ForEach(func(k, v []byte) error {
+		return generalB.ForEach(func(k, v []byte) error {
 			storedData, err := decode(v)
 			if err != nil {
 				return err
@@ -193,8 +465,6 @@ func (b *blt) RetrieveAll() ([]storage.StoredData, error) {
 			rv = append(rv, storedData)
 			return nil
 		})
-
-		return nil
 	})
 	if err != nil {
 		return nil, err
@@ -203,6 +473,18 @@ func (b *blt) RetrieveAll() ([]storage.StoredData, error) {
 	return rv, nil
 }
 
+// Backup writes a copy of the database file. The copy is made within a read
+// transaction so it is consistent and data can be stored in the meantime.
+func (b *blt) Backup(w io.Writer) (int64, error) {
+	var n int64
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		n, err = tx.WriteTo(w)
+		return err
+	})
+	return n, err
+}
+
 func (b *blt) Close() error {
 	return b.db.Close()
 }
@@ -217,17 +499,33 @@ func timeAndIcaoToKey(t time.Time, icao string) []byte {
 	return append(timeToKey(t), []byte(icao)...)
 }
 
+// keyToTime extracts time from a key created using timeToKey or
+// timeAndIcaoToKey.
+func keyToTime(key []byte) (time.Time, error) {
+	if len(key) < timeKeyLength {
+		return time.Time{}, errors.New("Key is too short!")
+	}
+	return time.Parse(rfc3339NanoSortable, string(key[:timeKeyLength]))
+}
+
 func encode(storedData storage.StoredData) ([]byte, error) {
 	protoStoredData := &messages.StoredData{
-		Time: new(int64),
+		Time:    new(int64),
+		Version: new(uint32),
 		Data: &messages.Data{
 			Icao:         storedData.Data.Icao,
 			FlightNumber: storedData.Data.FlightNumber,
 			Latitude:     storedData.Data.Latitude,
 			Longitude:    storedData.Data.Longitude,
+			Station:      storedData.Data.Station,
 		},
 	}
-	*protoStoredData.Time = storedData.Time.Unix()
+	if storedData.Data.Quality != nil {
+		quality := uint32(*storedData.Data.Quality)
+		protoStoredData.Data.Quality = &quality
+	}
+	*protoStoredData.Time = storedData.Time.UnixNano()
+	*protoStoredData.Version = recordVersion
 	if storedData.Data.TransponderCode != nil {
 		transponderCode := int32(*storedData.Data.TransponderCode)
 		protoStoredData.Data.TransponderCode = &transponderCode
@@ -249,17 +547,24 @@ func encode(storedData storage.StoredData) ([]byte, error) {
 
 func decode(data []byte) (storage.StoredData, error) {
 	var protoStoredData messages.StoredData
-	err := proto.Unmarshal(data, &protoStoredData)
+	if err := proto.Unmarshal(data, &protoStoredData); err != nil {
+		return storage.StoredData{}, err
+	}
 
 	rv := storage.StoredData{
-		Time: time.Unix(*protoStoredData.Time, 0),
+		Time: decodeTime(&protoStoredData),
 		Data: storage.Data{
 			Icao:         protoStoredData.Data.Icao,
 			FlightNumber: protoStoredData.Data.FlightNumber,
 			Latitude:     protoStoredData.Data.Latitude,
 			Longitude:    protoStoredData.Data.Longitude,
+			Station:      protoStoredData.Data.Station,
 		},
 	}
+	if protoStoredData.Data.Quality != nil {
+		quality := int(*protoStoredData.Data.Quality)
+		rv.Data.Quality = &quality
+	}
 	if protoStoredData.Data.TransponderCode != nil {
 		transponderCode := int(*protoStoredData.Data.TransponderCode)
 		rv.Data.TransponderCode = &transponderCode
@@ -277,5 +582,12 @@ func decode(data []byte) (storage.StoredData, error) {
 		rv.Data.Heading = &heading
 	}
 
-	return rv, err
+	return rv, nil
+}
+
+func decodeTime(protoStoredData *messages.StoredData) time.Time {
+	if protoStoredData.GetVersion() < 2 {
+		return time.Unix(protoStoredData.GetTime(), 0)
+	}
+	return time.Unix(0, protoStoredData.GetTime())
 }
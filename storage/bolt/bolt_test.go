@@ -1,10 +1,41 @@
 package bolt
 
 import (
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/boreq/flightradar-backend/storage"
+	"github.com/boreq/flightradar-backend/storage/bolt/messages"
+	"github.com/boreq/flightradar-backend/storage/storagetest"
+	"github.com/golang/protobuf/proto"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 )
 
+var testOptions = Options{
+	Station:  storagetest.Station,
+	Location: time.UTC,
+}
+
+func newTestBolt(t *testing.T) Bolt {
+	b, err := New(filepath.Join(t.TempDir(), "database.bolt"), testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		b.Close()
+	})
+	return b
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return newTestBolt(t)
+	})
+}
+
 func TestTimeToKeyNoNanoseconds(t *testing.T) {
 	key := timeToKey(time.Unix(0, 0))
 	if len(key) != 30 {
@@ -19,9 +50,425 @@ func TestTimeToKeyWithNanoseconds(t *testing.T) {
 	}
 }
 
+func TestDecodeVersion1(t *testing.T) {
+	icao := "aaaaaa"
+	record, err := proto.Marshal(&messages.StoredData{
+		Time: proto.Int64(10),
+		Data: &messages.Data{Icao: &icao},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	storedData, err := decode(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !storedData.Time.Equal(time.Unix(10, 0)) {
+		t.Errorf("Wrong time %s", storedData.Time)
+	}
+}
+
+func TestEncodeDecodeNanoseconds(t *testing.T) {
+	icao := "aaaaaa"
+	storedData := storage.StoredData{
+		Time: time.Unix(10, 42),
+		Data: storage.Data{Icao: &icao},
+	}
+	record, err := encode(storedData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded, err := decode(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !decoded.Time.Equal(storedData.Time) {
+		t.Errorf("Wrong time %s", decoded.Time)
+	}
+}
+
+func TestNewDatabaseHasNoPendingMigrations(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "database.bolt")
+	b, err := New(file, testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := PendingMigrations(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("Pending migrations %v", pending)
+	}
+}
+
+func TestNewReadOnly(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "database.bolt")
+	icao := "aaaaaa"
+
+	b, err := New(file, testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Store(storage.StoredData{Time: time.Unix(1, 0), Data: storage.Data{Icao: &icao}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = NewReadOnly(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	data, err := b.Retrieve(icao)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 {
+		t.Fatalf("Invalid data %v", data)
+	}
+	if err := b.Store(storage.StoredData{Time: time.Unix(2, 0), Data: storage.Data{Icao: &icao}}); err == nil {
+		t.Fatal("Storing data in a read-only database should fail")
+	}
+}
+
+func TestPendingMigrationsMissingDatabase(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "database.bolt")
+	if _, err := PendingMigrations(file); err == nil {
+		t.Fatal("Expected an error")
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Fatalf("Database was created: %v", err)
+	}
+}
+
+func TestMissingDatabase(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "database.bolt")
+	open := map[string]func() error{
+		"info": func() error {
+			_, err := GetInfo(file)
+			return err
+		},
+		"check": func() error {
+			_, err := Check(file, false, func(p Problem) {})
+			return err
+		},
+		"repair": func() error {
+			_, err := Check(file, true, func(p Problem) {})
+			return err
+		},
+	}
+	for name, fn := range open {
+		t.Run(name, func(t *testing.T) {
+			err := fn()
+			if err == nil || err.Error() != fmt.Sprintf("Database %s does not exist", file) {
+				t.Fatalf("Wrong error %v", err)
+			}
+			if _, err := os.Stat(file); !os.IsNotExist(err) {
+				t.Fatalf("Database was created: %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "database.bolt")
+
+	// Create a database in the format used before the schema versioning
+	// was introduced.
+	icao := "aaaaaa"
+	tm := time.Unix(10, 42)
+	record, err := proto.Marshal(&messages.StoredData{
+		Time: proto.Int64(tm.Unix()),
+		Data: &messages.Data{
+			Icao:      &icao,
+			Latitude:  proto.Float64(50.5),
+			Longitude: proto.Float64(20.5),
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := bolt.Open(file, 0644, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		generalB, err := tx.CreateBucket(generalKey)
+		if err != nil {
+			return err
+		}
+		if err := generalB.Put(timeAndIcaoToKey(tm, icao), record); err != nil {
+			return err
+		}
+		planesB, err := tx.CreateBucket(planesKey)
+		if err != nil {
+			return err
+		}
+		planeB, err := planesB.CreateBucket([]byte(icao))
+		if err != nil {
+			return err
+		}
+		return planeB.Put(timeToKey(tm), record)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := PendingMigrations(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != len(migrations) {
+		t.Fatalf("Wrong number of pending migrations %d", len(pending))
+	}
+
+	if _, err := NewReadOnly(file); err == nil || !strings.Contains(err.Error(), "pending migrations") {
+		t.Fatalf("Wrong error %v", err)
+	}
+
+	b, err := New(file, testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	all, err := b.RetrieveAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plane, err := b.Retrieve(icao)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bbox := storage.BoundingBox{
+		MinLatitude:  50,
+		MaxLatitude:  51,
+		MinLongitude: 20,
+		MaxLongitude: 21,
+	}
+	area, err := b.RetrieveArea(bbox, tm, tm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, data := range [][]storage.StoredData{all, plane, area} {
+		if len(data) != 1 || !data[0].Time.Equal(tm) {
+			t.Errorf("Wrong data %v", data)
+		}
+	}
+
+	periods, err := b.RetrieveStats(time.Unix(0, 0), tm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 1 || periods[0].Stats.DataPoints != 1 {
+		t.Errorf("Wrong stats %v", periods)
+	}
+
+	backups, err := filepath.Glob(file + ".backup-v0-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 {
+		t.Errorf("Wrong number of backups %d", len(backups))
+	}
+}
+
+func TestGetInfo(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "database.bolt")
+	b, err := New(file, testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, icao := range []string{"aaaaaa", "bbbbbb", "aaaaaa"} {
+		icao := icao
+		if err := b.Store(storage.StoredData{Time: time.Unix(int64(10+i), 0), Data: storage.Data{Icao: &icao}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := GetInfo(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.FileSize == 0 {
+		t.Error("File size is zero")
+	}
+	if info.SchemaVersion != len(migrations) || info.PendingMigrations != 0 {
+		t.Errorf("Wrong schema version %d or pending migrations %d", info.SchemaVersion, info.PendingMigrations)
+	}
+	if info.DataPoints != 3 || info.Planes != 2 {
+		t.Errorf("Wrong data points %d or planes %d", info.DataPoints, info.Planes)
+	}
+	if !info.From.Equal(time.Unix(10, 0)) || !info.To.Equal(time.Unix(12, 0)) {
+		t.Errorf("Wrong time span %s - %s", info.From, info.To)
+	}
+	var names []string
+	for _, bucket := range info.Buckets {
+		names = append(names, bucket.Name)
+	}
+	if len(names) == 0 || names[0] != string(generalKey) {
+		t.Errorf("Wrong buckets %v", names)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "database.bolt")
+	b, err := New(file, testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	icao := "aaaaaa"
+	flightNumber := "ABC123"
+	for i := 0; i < 3; i++ {
+		data := storage.StoredData{
+			Time: time.Unix(int64(10+i), 0),
+			Data: storage.Data{Icao: &icao, FlightNumber: &flightNumber},
+		}
+		if err := b.Store(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	problems := func(repair bool) int {
+		summary, err := Check(file, repair, func(p Problem) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return summary.Problems
+	}
+
+	if n := problems(false); n != 0 {
+		t.Fatalf("Found %d problems in a consistent database", n)
+	}
+
+	db, err := bolt.Open(file, 0644, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		// A data point missing from the plane bucket.
+		planeB := getBucket(tx, [][]byte{planesKey, []byte(icao)})
+		if err := planeB.Delete(timeToKey(time.Unix(10, 0))); err != nil {
+			return err
+		}
+
+		// A data point missing from the general bucket and the
+		// indexes.
+		key := timeAndIcaoToKey(time.Unix(11, 0), icao)
+		if err := tx.Bucket(generalKey).Delete(key); err != nil {
+			return err
+		}
+		entryB := getBucket(tx, [][]byte{flightNumberIndex.Key, []byte(flightNumber)})
+		if err := entryB.Delete(key); err != nil {
+			return err
+		}
+
+		// A dangling index entry.
+		if err := entryB.Put(timeAndIcaoToKey(time.Unix(20, 0), icao), nil); err != nil {
+			return err
+		}
+
+		// An undecodable record.
+		return tx.Bucket(generalKey).Put(timeAndIcaoToKey(time.Unix(30, 0), icao), []byte("invalid"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := problems(false); n != 4 {
+		t.Fatalf("Found %d problems instead of 4", n)
+	}
+	if n := problems(true); n != 4 {
+		t.Fatalf("Repaired %d problems instead of 4", n)
+	}
+	if n := problems(false); n != 0 {
+		t.Fatalf("Found %d problems after repairing the database", n)
+	}
+
+	b, err = NewReadOnly(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	for _, retrieve := range []func() ([]storage.StoredData, error){
+		b.RetrieveAll,
+		func() ([]storage.StoredData, error) { return b.Retrieve(icao) },
+		func() ([]storage.StoredData, error) {
+			return b.RetrieveByFlightNumber(flightNumber, time.Unix(0, 0), time.Unix(100, 0))
+		},
+	} {
+		data, err := retrieve()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(data) != 3 {
+			t.Errorf("Wrong data %v", data)
+		}
+	}
+}
+
+func TestBackup(t *testing.T) {
+	dir := t.TempDir()
+	b := newTestBolt(t)
+	icao := "aaaaaa"
+	if err := b.Store(storage.StoredData{Time: time.Unix(1, 0), Data: storage.Data{Icao: &icao}}); err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, "backup.bolt")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Backup(f); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backup, err := NewReadOnly(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	data, err := backup.Retrieve(icao)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 {
+		t.Fatalf("Invalid data %v", data)
+	}
+}
+
 func BenchmarkReadTimerange(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		blt, err := New("/home/filip/repositories/goboreq/flightradar-backend/database.bolt")
+		blt, err := New("/home/filip/repositories/goboreq/flightradar-backend/database.bolt", testOptions)
 		if err != nil {
 			b.Fatal(err)
 		}
@@ -0,0 +1,255 @@
+package bolt
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/boltdb/bolt"
+)
+
+// Problem is an inconsistency found in the database.
+type Problem struct {
+	// Bucket is the path to the bucket in which the problem was found.
+	Bucket string
+
+	Key         []byte
+	Description string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %q: %s", p.Bucket, p.Key, p.Description)
+}
+
+// CheckSummary describes the result of a consistency check.
+type CheckSummary struct {
+	// DataPoints is the number of checked data points in the general
+	// bucket.
+	DataPoints int
+
+	// Problems is the number of found problems.
+	Problems int
+}
+
+type checker struct {
+	db      *bolt.DB
+	repair  bool
+	problem func(Problem)
+	summary CheckSummary
+}
+
+// Check verifies that all records can be decoded and that the general bucket,
+// the plane buckets and the indexes contain the same data points. The
+// provided function is called for each problem. If repair is true the
+// problems are fixed. The general bucket is treated as the source of truth,
+// undecodable records are removed and the data points missing from the
+// general bucket are restored using the plane buckets. Otherwise the database
+// is opened in read-only mode. The database mustn't have any pending
+// migrations.
+func Check(filepath string, repair bool, problem func(Problem)) (CheckSummary, error) {
+	db, err := openExisting(filepath, !repair)
+	if err != nil {
+		return CheckSummary{}, err
+	}
+	defer db.Close()
+
+	version, err := getSchemaVersion(db)
+	if err != nil {
+		return CheckSummary{}, err
+	}
+	if version < len(migrations) {
+		return CheckSummary{}, fmt.Errorf("Database %s has pending migrations, run the migrate command first", filepath)
+	}
+
+	c := &checker{
+		db:      db,
+		repair:  repair,
+		problem: problem,
+	}
+	if err := c.checkGeneral(); err != nil {
+		return c.summary, err
+	}
+	if err := c.checkPlanes(); err != nil {
+		return c.summary, err
+	}
+	if err := c.checkIndexes(); err != nil {
+		return c.summary, err
+	}
+	return c.summary, nil
+}
+
+func (c *checker) report(path [][]byte, key []byte, description string) {
+	c.summary.Problems++
+	c.problem(Problem{
+		Bucket:      string(bytes.Join(path, []byte("/"))),
+		Key:         key,
+		Description: description,
+	})
+}
+
+// checkGeneral verifies that the records in the general bucket can be
+// decoded and are present in the plane buckets and the indexes.
+func (c *checker) checkGeneral() error {
+	path := [][]byte{generalKey}
+	return forEachChunkTx(c.db, path, c.repair, func(tx *bolt.Tx, records []record) error {
+		generalB := getBucket(tx, path)
+		for _, r := range records {
+			c.summary.DataPoints++
+
+			data, err := decode(r.V)
+			if err != nil || data.Data.Icao == nil {
+				c.report(path, r.K, "record can't be decoded")
+				if c.repair {
+					if err := generalB.Delete(r.K); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+
+			planeKey := [][]byte{planesKey, []byte(*data.Data.Icao)}
+			timeKey := timeToKey(data.Time)
+			planeV := getValue(tx, planeKey, timeKey)
+			if planeV == nil || !bytes.Equal(planeV, r.V) {
+				if planeV == nil {
+					c.report(planeKey, timeKey, "record is missing")
+				} else {
+					c.report(planeKey, timeKey, "record differs from the general bucket")
+				}
+				if c.repair {
+					planeB, err := tx.Bucket(planesKey).CreateBucketIfNotExists(planeKey[1])
+					if err != nil {
+						return err
+					}
+					if err := planeB.Put(timeKey, r.V); err != nil {
+						return err
+					}
+				}
+			}
+
+			for _, index := range indexes {
+				entry := index.Entry(data)
+				if entry == nil {
+					continue
+				}
+				indexKey := [][]byte{index.Key, entry}
+				if getValue(tx, indexKey, r.K) == nil {
+					c.report(indexKey, r.K, "index entry is missing")
+					if c.repair {
+						if err := addToIndexes(tx, r.K, data); err != nil {
+							return err
+						}
+					}
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// checkPlanes verifies that the records in the plane buckets can be decoded
+// and are present in the general bucket.
+func (c *checker) checkPlanes() error {
+	planes, err := c.nestedBuckets(planesKey)
+	if err != nil {
+		return err
+	}
+
+	for _, plane := range planes {
+		path := [][]byte{planesKey, plane}
+		err := forEachChunkTx(c.db, path, c.repair, func(tx *bolt.Tx, records []record) error {
+			planeB := getBucket(tx, path)
+			generalB := tx.Bucket(generalKey)
+			for _, r := range records {
+				data, err := decode(r.V)
+				if err != nil || data.Data.Icao == nil || !bytes.Equal([]byte(*data.Data.Icao), plane) {
+					c.report(path, r.K, "record can't be decoded")
+					if c.repair {
+						if err := planeB.Delete(r.K); err != nil {
+							return err
+						}
+					}
+					continue
+				}
+
+				key := timeAndIcaoToKey(data.Time, *data.Data.Icao)
+				if generalB.Get(key) == nil {
+					c.report([][]byte{generalKey}, key, "record is missing")
+					if c.repair {
+						if err := generalB.Put(key, r.V); err != nil {
+							return err
+						}
+						if err := addToIndexes(tx, key, data); err != nil {
+							return err
+						}
+					}
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkIndexes verifies that the indexes don't contain the keys which are
+// missing from the general bucket.
+func (c *checker) checkIndexes() error {
+	for _, index := range indexes {
+		entries, err := c.nestedBuckets(index.Key)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			path := [][]byte{index.Key, entry}
+			err := forEachChunkTx(c.db, path, c.repair, func(tx *bolt.Tx, records []record) error {
+				entryB := getBucket(tx, path)
+				generalB := tx.Bucket(generalKey)
+				for _, r := range records {
+					if generalB.Get(r.K) == nil {
+						c.report(path, r.K, "indexed record doesn't exist")
+						if c.repair {
+							if err := entryB.Delete(r.K); err != nil {
+								return err
+							}
+						}
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// nestedBuckets returns the names of the buckets nested in the top level
+// bucket.
+func (c *checker) nestedBuckets(key []byte) ([][]byte, error) {
+	var rv [][]byte
+	err := c.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(key)
+		if b == nil {
+			return fmt.Errorf("Bucket %s does not exist!", key)
+		}
+		return b.ForEach(func(k, v []byte) error {
+			if v == nil {
+				rv = append(rv, append([]byte(nil), k...))
+			}
+			return nil
+		})
+	})
+	return rv, err
+}
+
+// getValue returns the value stored under the key in the bucket located at
+// the given path or nil if either of them doesn't exist.
+func getValue(tx *bolt.Tx, path [][]byte, key []byte) []byte {
+	b := getBucket(tx, path)
+	if b == nil {
+		return nil
+	}
+	return b.Get(key)
+}
@@ -0,0 +1,108 @@
+package bolt
+
+import (
+	"errors"
+	"github.com/boltdb/bolt"
+)
+
+// chunkSize specifies how many records are processed in a single
+// transaction when iterating over a bucket in chunks.
+const chunkSize = 10000
+
+// rewriteBucket calls the provided function for every key in the bucket
+// located at the given path and replaces the value with the returned one
+// unless it is nil.
+func rewriteBucket(db *bolt.DB, path [][]byte, fn func(k, v []byte) ([]byte, error)) error {
+	return forEachChunk(db, path, func(tx *bolt.Tx, records []record) error {
+		b := getBucket(tx, path)
+		for _, r := range records {
+			newV, err := fn(r.K, r.V)
+			if err != nil {
+				return err
+			}
+			if newV == nil {
+				continue
+			}
+			if err := b.Put(r.K, newV); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+type record struct {
+	K []byte
+	V []byte
+}
+
+// forEachChunk iterates over the bucket located at the given path in chunks
+// to avoid holding all changes in memory at once. Each chunk of records is
+// passed to the provided function within a separate write transaction after
+// the records are read so the function is free to modify the bucket. Nested
+// buckets are skipped.
+func forEachChunk(db *bolt.DB, path [][]byte, fn func(tx *bolt.Tx, records []record) error) error {
+	return forEachChunkTx(db, path, true, fn)
+}
+
+// forEachChunkTx works like forEachChunk but uses read-only transactions
+// unless writable is true.
+func forEachChunkTx(db *bolt.DB, path [][]byte, writable bool, fn func(tx *bolt.Tx, records []record) error) error {
+	txFn := db.View
+	if writable {
+		txFn = db.Update
+	}
+
+	var next []byte
+	for {
+		err := txFn(func(tx *bolt.Tx) error {
+			b := getBucket(tx, path)
+			if b == nil {
+				return errors.New("Bucket does not exist!")
+			}
+
+			var records []record
+			c := b.Cursor()
+			k, v := c.First()
+			if next != nil {
+				k, v = c.Seek(next)
+			}
+			for ; k != nil && len(records) < chunkSize; k, v = c.Next() {
+				if v == nil {
+					continue
+				}
+				records = append(records, record{
+					K: append([]byte(nil), k...),
+					V: append([]byte(nil), v...),
+				})
+			}
+
+			if k != nil {
+				next = append([]byte(nil), k...)
+			} else {
+				next = nil
+			}
+
+			return fn(tx, records)
+		})
+		if err != nil {
+			return err
+		}
+		if next == nil {
+			return nil
+		}
+	}
+}
+
+// getBucket returns a nested bucket located at the given path or nil if it
+// doesn't exist.
+func getBucket(tx *bolt.Tx, path [][]byte) *bolt.Bucket {
+	b := tx.Bucket(path[0])
+	for _, name := range path[1:] {
+		if b == nil {
+			return nil
+		}
+		b = b.Bucket(name)
+	}
+	return b
+}
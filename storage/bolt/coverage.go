@@ -0,0 +1,137 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"errors"
+	"github.com/boltdb/bolt"
+	"github.com/boreq/flightradar-backend/storage"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Key for the top level bucket which contains the coverage. The bucket
+// contains a nested bucket for each altitude band which maps the bearings to
+// the farthest points recorded at them.
+var coverageKey = []byte("coverage")
+
+// mergeCoverage saves the points which are farther than the ones which are
+// already stored.
+func mergeCoverage(tx *bolt.Tx, coverage *storage.Coverage) error {
+	coverageB := tx.Bucket(coverageKey)
+	if coverageB == nil {
+		return errors.New("Coverage bucket does not exist!")
+	}
+
+	for band, points := range coverage.Bands {
+		bandB, err := coverageB.CreateBucketIfNotExists([]byte(strconv.Itoa(band)))
+		if err != nil {
+			return err
+		}
+		for _, point := range points {
+			key := encodeBearing(point.Bearing)
+			if v := bandB.Get(key); v != nil {
+				current, err := decodeCoveragePoint(key, v)
+				if err != nil {
+					return err
+				}
+				if current.Distance >= point.Distance {
+					continue
+				}
+			}
+			if err := bandB.Put(key, encodeCoveragePoint(point)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// buildCoverage recreates the coverage from all data points stored in the
+// general bucket.
+func buildCoverage(db *bolt.DB, options Options) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(coverageKey); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		_, err := tx.CreateBucket(coverageKey)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return forEachChunk(db, [][]byte{generalKey}, func(tx *bolt.Tx, records []record) error {
+		coverage := storage.NewCoverage()
+		for _, r := range records {
+			storedData, err := decode(r.V)
+			if err != nil {
+				return err
+			}
+			coverage.Add(storedData, options.Station)
+		}
+		return mergeCoverage(tx, coverage)
+	})
+}
+
+func (b *blt) RetrieveCoverage() (*storage.Coverage, error) {
+	rv := storage.NewCoverage()
+
+	t := time.Now()
+	defer func() {
+		log.Debugf("Retrieve coverage: %f seconds", time.Since(t).Seconds())
+	}()
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		coverageB := tx.Bucket(coverageKey)
+		if coverageB == nil {
+			return errors.New("Coverage bucket does not exist!")
+		}
+
+		return coverageB.ForEach(func(bandKey, v []byte) error {
+			band, err := strconv.Atoi(string(bandKey))
+			if err != nil {
+				return err
+			}
+			return coverageB.Bucket(bandKey).ForEach(func(k, v []byte) error {
+				point, err := decodeCoveragePoint(k, v)
+				if err != nil {
+					return err
+				}
+				rv.AddPoint(band, point)
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rv, nil
+}
+
+func encodeBearing(bearing int) []byte {
+	k := make([]byte, 2)
+	binary.BigEndian.PutUint16(k, uint16(bearing))
+	return k
+}
+
+func encodeCoveragePoint(point storage.CoveragePoint) []byte {
+	v := make([]byte, 24)
+	binary.BigEndian.PutUint64(v[0:8], math.Float64bits(point.Latitude))
+	binary.BigEndian.PutUint64(v[8:16], math.Float64bits(point.Longitude))
+	binary.BigEndian.PutUint64(v[16:24], math.Float64bits(point.Distance))
+	return v
+}
+
+func decodeCoveragePoint(k, v []byte) (storage.CoveragePoint, error) {
+	if len(k) != 2 || len(v) != 24 {
+		return storage.CoveragePoint{}, errors.New("Invalid coverage point!")
+	}
+	return storage.CoveragePoint{
+		Bearing:   int(binary.BigEndian.Uint16(k)),
+		Latitude:  math.Float64frombits(binary.BigEndian.Uint64(v[0:8])),
+		Longitude: math.Float64frombits(binary.BigEndian.Uint64(v[8:16])),
+		Distance:  math.Float64frombits(binary.BigEndian.Uint64(v[16:24])),
+	}, nil
+}
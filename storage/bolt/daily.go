@@ -0,0 +1,264 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/boltdb/bolt"
+	"github.com/boreq/flightradar-backend/storage"
+	"time"
+)
+
+// Key for the top level bucket which contains the daily statistics. They are
+// created by merging the hourly statistics once a day is closed and stored
+// under the keys created from the midnight starting the day using timeToKey.
+// Retrieving them instead of the hourly statistics makes it possible to
+// return the statistics for long periods of time without decoding thousands
+// of hourly statistics.
+var dailyStatsKey = []byte("daily_stats")
+
+// Key under which the name of the time zone used to determine the days is
+// stored in the metadata bucket.
+var dailyStatsLocationKey = []byte("daily_stats_location")
+
+// Key under which the start of the first day which wasn't yet rolled up into
+// the daily statistics is stored in the metadata bucket. The days preceding
+// it are closed, all statistics stored for them are also merged into their
+// daily statistics.
+var dailyStatsUntilKey = []byte("daily_stats_until")
+
+// dayStart returns the midnight starting the day which contains the given
+// time in the given location.
+func dayStart(t time.Time, location *time.Location) time.Time {
+	t = t.In(location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, location)
+}
+
+// nextDayStart returns the midnight starting the day which follows the day
+// starting at the given midnight.
+func nextDayStart(day time.Time, location *time.Location) time.Time {
+	day = day.In(location)
+	return time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, location)
+}
+
+// getDailyStatsUntil returns the start of the first day which wasn't yet
+// rolled up or the zero time if no days were rolled up.
+func getDailyStatsUntil(tx *bolt.Tx) (time.Time, error) {
+	metaB := tx.Bucket(metaKey)
+	if metaB == nil {
+		return time.Time{}, errors.New("Meta bucket does not exist!")
+	}
+	v := metaB.Get(dailyStatsUntilKey)
+	if v == nil {
+		return time.Time{}, nil
+	}
+	return keyToTime(v)
+}
+
+// rollUpStats creates the daily statistics for the days which precede the
+// day containing the given time and weren't yet rolled up.
+func rollUpStats(tx *bolt.Tx, location *time.Location, t time.Time) error {
+	statsB := tx.Bucket(statsKey)
+	if statsB == nil {
+		return errors.New("Stats bucket does not exist!")
+	}
+	dailyB := tx.Bucket(dailyStatsKey)
+	if dailyB == nil {
+		return errors.New("Daily stats bucket does not exist!")
+	}
+	metaB := tx.Bucket(metaKey)
+	if metaB == nil {
+		return errors.New("Meta bucket does not exist!")
+	}
+
+	until, err := getDailyStatsUntil(tx)
+	if err != nil {
+		return err
+	}
+	before := dayStart(t, location)
+	if !until.Before(before) {
+		return nil
+	}
+
+	c := statsB.Cursor()
+	k, v := c.First()
+	if !until.IsZero() {
+		k, v = c.Seek(timeToKey(until))
+	}
+	max := timeToKey(before)
+	for k != nil && bytes.Compare(k, max) < 0 {
+		periodStart, err := keyToTime(k)
+		if err != nil {
+			return err
+		}
+		day := dayStart(periodStart, location)
+		next := timeToKey(nextDayStart(day, location))
+
+		stats := storage.NewStats()
+		for ; k != nil && bytes.Compare(k, next) < 0; k, v = c.Next() {
+			periodStats := storage.NewStats()
+			if err := json.Unmarshal(v, periodStats); err != nil {
+				return err
+			}
+			stats.Merge(periodStats)
+		}
+		if err := putStats(dailyB, timeToKey(day), stats); err != nil {
+			return err
+		}
+	}
+
+	return metaB.Put(dailyStatsUntilKey, max)
+}
+
+// resetDailyStats removes the daily statistics and recreates them using the
+// given location.
+func resetDailyStats(tx *bolt.Tx, location *time.Location) error {
+	if err := tx.DeleteBucket(dailyStatsKey); err != nil && err != bolt.ErrBucketNotFound {
+		return err
+	}
+	if _, err := tx.CreateBucket(dailyStatsKey); err != nil {
+		return err
+	}
+
+	metaB := tx.Bucket(metaKey)
+	if metaB == nil {
+		return errors.New("Meta bucket does not exist!")
+	}
+	if err := metaB.Delete(dailyStatsUntilKey); err != nil {
+		return err
+	}
+	if err := metaB.Put(dailyStatsLocationKey, []byte(location.String())); err != nil {
+		return err
+	}
+
+	statsB := tx.Bucket(statsKey)
+	if statsB == nil {
+		return errors.New("Stats bucket does not exist!")
+	}
+	if k, _ := statsB.Cursor().Last(); k != nil {
+		last, err := keyToTime(k)
+		if err != nil {
+			return err
+		}
+		return rollUpStats(tx, location, last)
+	}
+	return nil
+}
+
+// initDailyStats recreates the daily statistics if they were created using a
+// different location.
+func initDailyStats(db *bolt.DB, location *time.Location) error {
+	return db.Update(func(tx *bolt.Tx) error {
+		metaB := tx.Bucket(metaKey)
+		if metaB == nil {
+			return errors.New("Meta bucket does not exist!")
+		}
+		previous := metaB.Get(dailyStatsLocationKey)
+		if string(previous) == location.String() {
+			return nil
+		}
+		if previous != nil {
+			log.Printf("Recreating the daily statistics in the %s time zone", location)
+		}
+		return resetDailyStats(tx, location)
+	})
+}
+
+// getDailyStatsLocation returns the location in which the daily statistics
+// were created.
+func getDailyStatsLocation(db *bolt.DB) (*time.Location, error) {
+	var name string
+	err := db.View(func(tx *bolt.Tx) error {
+		metaB := tx.Bucket(metaKey)
+		if metaB == nil {
+			return errors.New("Meta bucket does not exist!")
+		}
+		name = string(metaB.Get(dailyStatsLocationKey))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return time.LoadLocation(name)
+}
+
+func (b *blt) RetrieveDailyStats(from time.Time, to time.Time) ([]storage.StatsForPeriod, error) {
+	var rv []storage.StatsForPeriod
+
+	t := time.Now()
+	defer func() {
+		log.Debugf("Retrieve daily stats: %f seconds", time.Since(t).Seconds())
+	}()
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		until, err := getDailyStatsUntil(tx)
+		if err != nil {
+			return err
+		}
+
+		// The days have to be closed and fully contained in the time
+		// range, the remaining periods are retrieved from the hourly
+		// statistics.
+		daysFrom := dayStart(from, b.options.Location)
+		if daysFrom.Before(from) {
+			daysFrom = nextDayStart(daysFrom, b.options.Location)
+		}
+		daysTo := dayStart(to.Add(storage.StatsPeriod), b.options.Location)
+		if until.Before(daysTo) {
+			daysTo = until
+		}
+		if !daysFrom.Before(daysTo) {
+			rv, err = retrieveStats(tx, from, to)
+			return err
+		}
+
+		before, err := retrieveStats(tx, from, daysFrom.Add(-1))
+		if err != nil {
+			return err
+		}
+		days, err := retrieveDailyStats(tx, daysFrom, daysTo.Add(-1), b.options.Location)
+		if err != nil {
+			return err
+		}
+		after, err := retrieveStats(tx, daysTo, to)
+		if err != nil {
+			return err
+		}
+		rv = append(append(before, days...), after...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rv, nil
+}
+
+// retrieveDailyStats returns the daily statistics for the days which start in
+// the given time range.
+func retrieveDailyStats(tx *bolt.Tx, from time.Time, to time.Time, location *time.Location) ([]storage.StatsForPeriod, error) {
+	dailyB := tx.Bucket(dailyStatsKey)
+	if dailyB == nil {
+		return nil, errors.New("Daily stats bucket does not exist!")
+	}
+
+	var rv []storage.StatsForPeriod
+	c := dailyB.Cursor()
+	max := timeToKey(to)
+	for k, v := c.Seek(timeToKey(from)); k != nil && bytes.Compare(k, max) <= 0; k, v = c.Next() {
+		day, err := keyToTime(k)
+		if err != nil {
+			return nil, err
+		}
+		stats := storage.NewStats()
+		if err := json.Unmarshal(v, stats); err != nil {
+			return nil, err
+		}
+		rv = append(rv, storage.StatsForPeriod{
+			Time:     day,
+			Duration: nextDayStart(day, location).Sub(day),
+			Stats:    stats,
+		})
+	}
+	return rv, nil
+}
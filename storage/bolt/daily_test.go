@@ -0,0 +1,113 @@
+package bolt
+
+import (
+	"github.com/boreq/flightradar-backend/storage"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRetrieveDailyStats(t *testing.T) {
+	b := newTestBolt(t)
+	icao := "aaaaaa"
+
+	// Two data points on each of the first three days of the month, the
+	// last data point closes the first two days
+	day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	var data []storage.StoredData
+	for i := 0; i < 3; i++ {
+		for _, hour := range []int{5, 17} {
+			data = append(data, storage.StoredData{
+				Time: day.AddDate(0, 0, i).Add(time.Duration(hour) * time.Hour),
+				Data: storage.Data{Icao: &icao},
+			})
+		}
+	}
+	if err := b.StoreBatch(data); err != nil {
+		t.Fatal(err)
+	}
+
+	// Data points stored for a closed day are added to its daily
+	// statistics
+	late := storage.StoredData{Time: day.Add(20 * time.Hour), Data: storage.Data{Icao: &icao}}
+	if err := b.Store(late); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first day isn't fully contained in the time range
+	from := day.Add(10 * time.Hour)
+	to := day.AddDate(0, 0, 3)
+
+	periods, err := b.RetrieveDailyStats(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		Time       time.Time
+		Duration   time.Duration
+		DataPoints int
+	}{
+		{day.Add(17 * time.Hour), storage.StatsPeriod, 1},
+		{day.Add(20 * time.Hour), storage.StatsPeriod, 1},
+		{day.AddDate(0, 0, 1), 24 * time.Hour, 2},
+		{day.AddDate(0, 0, 2).Add(5 * time.Hour), storage.StatsPeriod, 1},
+		{day.AddDate(0, 0, 2).Add(17 * time.Hour), storage.StatsPeriod, 1},
+	}
+	if len(periods) != len(expected) {
+		t.Fatalf("Wrong periods %v", periods)
+	}
+	for i, e := range expected {
+		if !periods[i].Time.Equal(e.Time) || periods[i].Duration != e.Duration || periods[i].Stats.DataPoints != e.DataPoints {
+			t.Errorf("Wrong period %d %v", i, periods[i])
+		}
+	}
+
+	periods, err = b.RetrieveDailyStats(day, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 4 || periods[0].Duration != 24*time.Hour || periods[0].Stats.DataPoints != 3 {
+		t.Errorf("Wrong periods %v", periods)
+	}
+}
+
+func TestDailyStatsRecreatedForDifferentLocation(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "database.bolt")
+	icao := "aaaaaa"
+
+	b, err := New(file, testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := []storage.StoredData{
+		{Time: day.Add(1 * time.Hour), Data: storage.Data{Icao: &icao}},
+		{Time: day.AddDate(0, 0, 2), Data: storage.Data{Icao: &icao}},
+	}
+	if err := b.StoreBatch(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The data point stored at 01:00 UTC belongs to the previous day
+	location := time.FixedZone("UTC-2", -2*60*60)
+	options := testOptions
+	options.Location = location
+	b, err = New(file, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	previous := time.Date(2019, 12, 31, 0, 0, 0, 0, location)
+	periods, err := b.RetrieveDailyStats(previous, day.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 2 || !periods[0].Time.Equal(previous) || periods[0].Duration != 24*time.Hour || periods[0].Stats.DataPoints != 1 {
+		t.Errorf("Wrong periods %v", periods)
+	}
+}
@@ -0,0 +1,319 @@
+package bolt
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/boreq/flightradar-backend/storage"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Key for the top level bucket which contains the spatial index.
+var areaKey = []byte("area")
+
+// Key for the top level bucket which contains the flight number index.
+var flightNumbersKey = []byte("flight_numbers")
+
+// Key for the top level bucket which contains the transponder code index.
+var transponderCodesKey = []byte("transponder_codes")
+
+// areaCellSize is the size of the grid cells used by the spatial index in
+// degrees.
+const areaCellSize = 0.25
+
+// maxAreaCells is the largest number of grid cells looked up in the spatial
+// index. Larger areas are retrieved by scanning the time range instead as
+// looking up a huge number of mostly empty cells would be slower.
+const maxAreaCells = 10000
+
+// index describes a secondary index. Each index is a top level bucket which
+// contains nested buckets, one for each entry. The nested buckets contain
+// the keys of the indexed data points in the general bucket mapped to empty
+// values.
+type index struct {
+	Key []byte
+
+	// Entry returns the name of the nested bucket in which the data point
+	// should be indexed or nil if it shouldn't be indexed.
+	Entry func(data storage.StoredData) []byte
+}
+
+var areaIndex = index{
+	Key: areaKey,
+	Entry: func(data storage.StoredData) []byte {
+		if data.Data.Latitude == nil || data.Data.Longitude == nil {
+			return nil
+		}
+		return areaCell(*data.Data.Latitude, *data.Data.Longitude)
+	},
+}
+
+var flightNumberIndex = index{
+	Key: flightNumbersKey,
+	Entry: func(data storage.StoredData) []byte {
+		if data.Data.FlightNumber == nil || *data.Data.FlightNumber == "" {
+			return nil
+		}
+		return []byte(*data.Data.FlightNumber)
+	},
+}
+
+var transponderCodeIndex = index{
+	Key: transponderCodesKey,
+	Entry: func(data storage.StoredData) []byte {
+		if data.Data.TransponderCode == nil {
+			return nil
+		}
+		return []byte(strconv.Itoa(*data.Data.TransponderCode))
+	},
+}
+
+// indexes lists all maintained secondary indexes.
+var indexes = []index{
+	areaIndex,
+	flightNumberIndex,
+	transponderCodeIndex,
+}
+
+func addToIndexes(tx *bolt.Tx, key []byte, data storage.StoredData) error {
+	for _, index := range indexes {
+		entry := index.Entry(data)
+		if entry == nil {
+			continue
+		}
+		indexB := tx.Bucket(index.Key)
+		if indexB == nil {
+			return fmt.Errorf("Index bucket %s does not exist!", index.Key)
+		}
+		entryB, err := indexB.CreateBucketIfNotExists(entry)
+		if err != nil {
+			return err
+		}
+		if err := entryB.Put(key, []byte{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func removeFromIndexes(tx *bolt.Tx, key []byte, data storage.StoredData) error {
+	for _, index := range indexes {
+		entry := index.Entry(data)
+		if entry == nil {
+			continue
+		}
+		indexB := tx.Bucket(index.Key)
+		if indexB == nil {
+			return fmt.Errorf("Index bucket %s does not exist!", index.Key)
+		}
+		entryB := indexB.Bucket(entry)
+		if entryB == nil {
+			continue
+		}
+		if err := entryB.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retrieveFromIndex returns the keys of the data points indexed under the
+// given entry which were recorded in the given time range.
+func retrieveFromIndex(tx *bolt.Tx, index index, entry []byte, from time.Time, to time.Time) ([][]byte, error) {
+	indexB := tx.Bucket(index.Key)
+	if indexB == nil {
+		return nil, fmt.Errorf("Index bucket %s does not exist!", index.Key)
+	}
+	entryB := indexB.Bucket(entry)
+	if entryB == nil {
+		return nil, nil
+	}
+
+	var rv [][]byte
+	c := entryB.Cursor()
+	min := timeToKey(from)
+	max := timeToKey(to)
+	for k, _ := c.Seek(min); k != nil && bytes.Compare(k[0:timeKeyLength], max) <= 0; k, _ = c.Next() {
+		rv = append(rv, k)
+	}
+	return rv, nil
+}
+
+// retrieveKeys sorts the keys and returns the data points stored under them
+// in the general bucket.
+func retrieveKeys(tx *bolt.Tx, keys [][]byte) ([]storage.StoredData, error) {
+	generalB := tx.Bucket(generalKey)
+	if generalB == nil {
+		return nil, errors.New("General bucket does not exist!")
+	}
+
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+
+	var rv []storage.StoredData
+	for _, key := range keys {
+		v := generalB.Get(key)
+		if v == nil {
+			return nil, fmt.Errorf("Indexed key %s does not exist!", key)
+		}
+		storedData, err := decode(v)
+		if err != nil {
+			return nil, err
+		}
+		rv = append(rv, storedData)
+	}
+	return rv, nil
+}
+
+// buildIndex adds all data points stored in the general bucket to the index.
+func buildIndex(db *bolt.DB, index index) error {
+	return forEachChunk(db, [][]byte{generalKey}, func(tx *bolt.Tx, records []record) error {
+		indexB := tx.Bucket(index.Key)
+		if indexB == nil {
+			return fmt.Errorf("Index bucket %s does not exist!", index.Key)
+		}
+		for _, r := range records {
+			storedData, err := decode(r.V)
+			if err != nil {
+				return err
+			}
+			entry := index.Entry(storedData)
+			if entry == nil {
+				continue
+			}
+			entryB, err := indexB.CreateBucketIfNotExists(entry)
+			if err != nil {
+				return err
+			}
+			if err := entryB.Put(r.K, []byte{}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *blt) RetrieveArea(bbox storage.BoundingBox, from time.Time, to time.Time) ([]storage.StoredData, error) {
+	var rv []storage.StoredData
+
+	t := time.Now()
+	defer func() {
+		log.Debugf("Retrieve area: %f seconds", time.Since(t).Seconds())
+	}()
+
+	if err := bbox.Validate(); err != nil {
+		return nil, err
+	}
+
+	cells, ok := areaCells(bbox)
+	if !ok {
+		err := b.Iterate(from, to, func(storedData storage.StoredData) error {
+			if storedData.Data.Latitude != nil && storedData.Data.Longitude != nil &&
+				bbox.Contains(*storedData.Data.Latitude, *storedData.Data.Longitude) {
+				rv = append(rv, storedData)
+			}
+			return nil
+		})
+		return rv, err
+	}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var keys [][]byte
+		for _, cell := range cells {
+			cellKeys, err := retrieveFromIndex(tx, areaIndex, cell, from, to)
+			if err != nil {
+				return err
+			}
+			keys = append(keys, cellKeys...)
+		}
+
+		data, err := retrieveKeys(tx, keys)
+		if err != nil {
+			return err
+		}
+
+		// The cells at the edges of the bounding box can contain data
+		// points located outside of it.
+		for _, storedData := range data {
+			if bbox.Contains(*storedData.Data.Latitude, *storedData.Data.Longitude) {
+				rv = append(rv, storedData)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rv, nil
+}
+
+func (b *blt) RetrieveByFlightNumber(flightNumber string, from time.Time, to time.Time) ([]storage.StoredData, error) {
+	t := time.Now()
+	defer func() {
+		log.Debugf("Retrieve by flight number: %f seconds", time.Since(t).Seconds())
+	}()
+
+	return b.retrieveIndexEntry(flightNumberIndex, []byte(flightNumber), from, to)
+}
+
+func (b *blt) RetrieveBySquawk(transponderCode int, from time.Time, to time.Time) ([]storage.StoredData, error) {
+	t := time.Now()
+	defer func() {
+		log.Debugf("Retrieve by squawk: %f seconds", time.Since(t).Seconds())
+	}()
+
+	return b.retrieveIndexEntry(transponderCodeIndex, []byte(strconv.Itoa(transponderCode)), from, to)
+}
+
+func (b *blt) retrieveIndexEntry(index index, entry []byte, from time.Time, to time.Time) ([]storage.StoredData, error) {
+	var rv []storage.StoredData
+	err := b.db.View(func(tx *bolt.Tx) error {
+		keys, err := retrieveFromIndex(tx, index, entry, from, to)
+		if err != nil {
+			return err
+		}
+		rv, err = retrieveKeys(tx, keys)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rv, nil
+}
+
+// areaCell returns the name of the grid cell in which the position is
+// located.
+func areaCell(latitude, longitude float64) []byte {
+	return areaCellFromIndices(areaCellIndex(latitude), areaCellIndex(longitude))
+}
+
+// areaCells returns the names of all grid cells which intersect the bounding
+// box. False is returned if the number of cells exceeds maxAreaCells. The
+// bounding box must be valid.
+func areaCells(bbox storage.BoundingBox) ([][]byte, bool) {
+	minLat, maxLat := areaCellIndex(bbox.MinLatitude), areaCellIndex(bbox.MaxLatitude)
+	minLon, maxLon := areaCellIndex(bbox.MinLongitude), areaCellIndex(bbox.MaxLongitude)
+	if (maxLat-minLat+1)*(maxLon-minLon+1) > maxAreaCells {
+		return nil, false
+	}
+
+	var rv [][]byte
+	for lat := minLat; lat <= maxLat; lat++ {
+		for lon := minLon; lon <= maxLon; lon++ {
+			rv = append(rv, areaCellFromIndices(lat, lon))
+		}
+	}
+	return rv, true
+}
+
+func areaCellIndex(degrees float64) int {
+	return int(math.Floor(degrees / areaCellSize))
+}
+
+func areaCellFromIndices(lat, lon int) []byte {
+	return []byte(fmt.Sprintf("%d,%d", lat, lon))
+}
@@ -0,0 +1,115 @@
+package bolt
+
+import (
+	"github.com/boltdb/bolt"
+	"os"
+	"sort"
+	"time"
+)
+
+// BucketInfo describes a top level bucket.
+type BucketInfo struct {
+	Name string
+
+	// Keys is the number of keys in the bucket including the keys of the
+	// nested buckets.
+	Keys int
+
+	// Size is the number of bytes used by the bucket and its nested
+	// buckets.
+	Size int
+}
+
+// Info describes the contents of a database.
+type Info struct {
+	FileSize      int64
+	SchemaVersion int
+
+	// PendingMigrations is the number of migrations which weren't yet
+	// applied to the database.
+	PendingMigrations int
+
+	// DataPoints is the number of data points in the general bucket.
+	DataPoints int
+
+	// Planes is the number of planes in the planes bucket.
+	Planes int
+
+	// From and To are the times of the oldest and the newest data points.
+	// They are zero if the database is empty.
+	From time.Time
+	To   time.Time
+
+	Buckets []BucketInfo
+}
+
+// GetInfo opens the database located at the given path in read-only mode and
+// describes its contents.
+func GetInfo(filepath string) (Info, error) {
+	var rv Info
+
+	db, err := openReadOnly(filepath)
+	if err != nil {
+		return rv, err
+	}
+	defer db.Close()
+
+	stat, err := os.Stat(filepath)
+	if err != nil {
+		return rv, err
+	}
+	rv.FileSize = stat.Size()
+
+	err = db.View(func(tx *bolt.Tx) error {
+		// Databases created before the schema versioning was introduced
+		// don't have the metadata bucket.
+		if tx.Bucket(metaKey) != nil {
+			version, err := readSchemaVersion(tx)
+			if err != nil {
+				return err
+			}
+			rv.SchemaVersion = version
+		}
+		rv.PendingMigrations = len(pendingMigrations(rv.SchemaVersion))
+
+		err := tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			stats := b.Stats()
+			rv.Buckets = append(rv.Buckets, BucketInfo{
+				Name: string(name),
+				Keys: stats.KeyN,
+				Size: stats.BranchInuse + stats.LeafInuse + stats.InlineBucketInuse,
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		sort.Slice(rv.Buckets, func(i, j int) bool { return rv.Buckets[i].Name < rv.Buckets[j].Name })
+
+		if generalB := tx.Bucket(generalKey); generalB != nil {
+			rv.DataPoints = generalB.Stats().KeyN
+			c := generalB.Cursor()
+			if k, _ := c.First(); k != nil {
+				if rv.From, err = keyToTime(k); err != nil {
+					return err
+				}
+			}
+			if k, _ := c.Last(); k != nil {
+				if rv.To, err = keyToTime(k); err != nil {
+					return err
+				}
+			}
+		}
+
+		if planesB := tx.Bucket(planesKey); planesB != nil {
+			return planesB.ForEach(func(k, v []byte) error {
+				if v == nil {
+					rv.Planes++
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return rv, err
+}
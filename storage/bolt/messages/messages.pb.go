@@ -30,6 +30,8 @@ type Data struct {
 	Heading          *int32   `protobuf:"varint,6,opt" json:"Heading,omitempty"`
 	Latitude         *float64 `protobuf:"fixed64,7,opt" json:"Latitude,omitempty"`
 	Longitude        *float64 `protobuf:"fixed64,8,opt" json:"Longitude,omitempty"`
+	Station          *string  `protobuf:"bytes,9,opt" json:"Station,omitempty"`
+	Quality          *uint32  `protobuf:"varint,10,opt" json:"Quality,omitempty"`
 	XXX_unrecognized []byte   `json:"-"`
 }
 
@@ -93,10 +95,25 @@ func (m *Data) GetLongitude() float64 {
 	return 0
 }
 
+func (m *Data) GetStation() string {
+	if m != nil && m.Station != nil {
+		return *m.Station
+	}
+	return ""
+}
+
+func (m *Data) GetQuality() uint32 {
+	if m != nil && m.Quality != nil {
+		return *m.Quality
+	}
+	return 0
+}
+
 type StoredData struct {
-	Time             *int64 `protobuf:"varint,1,req" json:"Time,omitempty"`
-	Data             *Data  `protobuf:"bytes,2,req" json:"Data,omitempty"`
-	XXX_unrecognized []byte `json:"-"`
+	Time             *int64  `protobuf:"varint,1,req" json:"Time,omitempty"`
+	Data             *Data   `protobuf:"bytes,2,req" json:"Data,omitempty"`
+	Version          *uint32 `protobuf:"varint,3,opt" json:"Version,omitempty"`
+	XXX_unrecognized []byte  `json:"-"`
 }
 
 func (m *StoredData) Reset()         { *m = StoredData{} }
@@ -116,3 +133,10 @@ func (m *StoredData) GetData() *Data {
 	}
 	return nil
 }
+
+func (m *StoredData) GetVersion() uint32 {
+	if m != nil && m.Version != nil {
+		return *m.Version
+	}
+	return 0
+}
@@ -0,0 +1,274 @@
+package bolt
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/boltdb/bolt"
+	"github.com/boreq/flightradar-backend/storage/bolt/messages"
+	"github.com/golang/protobuf/proto"
+	"time"
+)
+
+// Key for the top level bucket which contains the database metadata.
+var metaKey = []byte("meta")
+
+// Key under which the schema version is stored in the metadata bucket.
+var schemaVersionKey = []byte("schema_version")
+
+type migration struct {
+	Description string
+	Run         func(db *bolt.DB, options Options) error
+}
+
+// migrations lists all migrations in the order in which they have to be
+// applied. The schema version of the database is equal to the number of
+// migrations which were applied to it. New migrations must be appended at the
+// end of this list.
+var migrations = []migration{
+	{
+		Description: "convert records to the format storing nanoseconds",
+		Run: func(db *bolt.DB, options Options) error {
+			return upgradeRecords(db)
+		},
+	},
+	{
+		Description: "build the spatial index",
+		Run: func(db *bolt.DB, options Options) error {
+			return buildIndex(db, areaIndex)
+		},
+	},
+	{
+		Description: "build the flight number and transponder code indexes",
+		Run: func(db *bolt.DB, options Options) error {
+			if err := buildIndex(db, flightNumberIndex); err != nil {
+				return err
+			}
+			return buildIndex(db, transponderCodeIndex)
+		},
+	},
+	{
+		Description: "build the statistics",
+		Run:         buildStats,
+	},
+	{
+		Description: "build the coverage",
+		Run:         buildCoverage,
+	},
+}
+
+// Migration describes a migration which has to be applied to a database.
+type Migration struct {
+	// Version is the schema version of the database after the migration
+	// is applied.
+	Version     int
+	Description string
+}
+
+// PendingMigrations returns the migrations which weren't yet applied to the
+// database located at the given path. The database is opened in the
+// read-only mode and it isn't created if it doesn't exist.
+func PendingMigrations(filepath string) ([]Migration, error) {
+	db, err := openReadOnly(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	version, err := getLegacySchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+	return pendingMigrations(version), nil
+}
+
+// getLegacySchemaVersion works like getSchemaVersion but returns zero for
+// the databases created before the schema versioning was introduced which
+// don't have the metadata bucket. Use it if the buckets can't be created.
+func getLegacySchemaVersion(db *bolt.DB) (int, error) {
+	var version int
+	err := db.View(func(tx *bolt.Tx) error {
+		if tx.Bucket(metaKey) == nil {
+			return nil
+		}
+		v, err := readSchemaVersion(tx)
+		version = v
+		return err
+	})
+	return version, err
+}
+
+// Migrate applies the pending migrations to the database located at the
+// given path. The database is backed up before that happens. It returns the
+// applied migrations.
+func Migrate(filepath string, options Options) ([]Migration, error) {
+	db, err := open(filepath)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return migrate(db, filepath, options)
+}
+
+func migrate(db *bolt.DB, filepath string, options Options) ([]Migration, error) {
+	version, err := getSchemaVersion(db)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := pendingMigrations(version)
+	if len(pending) == 0 {
+		return nil, nil
+	}
+
+	backupFile, err := backupBeforeMigration(db, filepath, version)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Database backed up to %s", backupFile)
+
+	for _, m := range pending {
+		log.Printf("Applying migration %d: %s", m.Version, m.Description)
+		if err := migrations[m.Version-1].Run(db, options); err != nil {
+			return nil, fmt.Errorf("Migration %d failed: %s", m.Version, err)
+		}
+		err := db.Update(func(tx *bolt.Tx) error {
+			return setSchemaVersion(tx, m.Version)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return pending, nil
+}
+
+func pendingMigrations(version int) []Migration {
+	var rv []Migration
+	for i := version; i < len(migrations); i++ {
+		rv = append(rv, Migration{
+			Version:     i + 1,
+			Description: migrations[i].Description,
+		})
+	}
+	return rv
+}
+
+// backupBeforeMigration copies the database to a file placed next to it and
+// returns the path to that file.
+func backupBeforeMigration(db *bolt.DB, filepath string, version int) (string, error) {
+	backupFile := fmt.Sprintf("%s.backup-v%d-%s", filepath, version, time.Now().UTC().Format("20060102T150405"))
+	err := db.View(func(tx *bolt.Tx) error {
+		return tx.CopyFile(backupFile, 0644)
+	})
+	return backupFile, err
+}
+
+// initSchemaVersion creates the metadata bucket if it doesn't exist. It must
+// be called before creating any other buckets as a database which doesn't
+// contain any buckets is considered to be new and doesn't require migrations.
+// Databases which contain data but no metadata were created before schema
+// versioning was introduced.
+func initSchemaVersion(tx *bolt.Tx) error {
+	if tx.Bucket(metaKey) != nil {
+		return nil
+	}
+
+	version := len(migrations)
+	if tx.Bucket(generalKey) != nil {
+		version = 0
+	}
+
+	if _, err := tx.CreateBucket(metaKey); err != nil {
+		return err
+	}
+	return setSchemaVersion(tx, version)
+}
+
+func getSchemaVersion(db *bolt.DB) (int, error) {
+	var version int
+	err := db.View(func(tx *bolt.Tx) error {
+		v, err := readSchemaVersion(tx)
+		version = v
+		return err
+	})
+	return version, err
+}
+
+func readSchemaVersion(tx *bolt.Tx) (int, error) {
+	metaB := tx.Bucket(metaKey)
+	if metaB == nil {
+		return 0, errors.New("Meta bucket does not exist!")
+	}
+	v := metaB.Get(schemaVersionKey)
+	if len(v) != 8 {
+		return 0, errors.New("Invalid schema version!")
+	}
+	version := int(binary.BigEndian.Uint64(v))
+	if version > len(migrations) {
+		return 0, fmt.Errorf("Database schema version %d is newer than the supported version %d", version, len(migrations))
+	}
+	return version, nil
+}
+
+func setSchemaVersion(tx *bolt.Tx, version int) error {
+	metaB := tx.Bucket(metaKey)
+	if metaB == nil {
+		return errors.New("Meta bucket does not exist!")
+	}
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, uint64(version))
+	return metaB.Put(schemaVersionKey, v)
+}
+
+// upgradeRecords converts the records to the current record format. Records
+// stored in the version 1 format lost the sub-second part of their time which
+// is restored using their keys.
+func upgradeRecords(db *bolt.DB) error {
+	if err := rewriteBucket(db, [][]byte{generalKey}, upgradeRecord); err != nil {
+		return err
+	}
+
+	var planes [][]byte
+	err := db.View(func(tx *bolt.Tx) error {
+		planesB := tx.Bucket(planesKey)
+		if planesB == nil {
+			return errors.New("Planes bucket does not exist!")
+		}
+		return planesB.ForEach(func(k, v []byte) error {
+			planes = append(planes, append([]byte(nil), k...))
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, plane := range planes {
+		if err := rewriteBucket(db, [][]byte{planesKey, plane}, upgradeRecord); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// upgradeRecord returns the record converted to the current format or nil if
+// the record doesn't require an upgrade.
+func upgradeRecord(k, v []byte) ([]byte, error) {
+	var protoStoredData messages.StoredData
+	if err := proto.Unmarshal(v, &protoStoredData); err != nil {
+		return nil, err
+	}
+	if protoStoredData.GetVersion() >= recordVersion {
+		return nil, nil
+	}
+
+	t, err := keyToTime(k)
+	if err != nil {
+		return nil, err
+	}
+	protoStoredData.Version = proto.Uint32(recordVersion)
+	protoStoredData.Time = proto.Int64(t.UnixNano())
+	return proto.Marshal(&protoStoredData)
+}
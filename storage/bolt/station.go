@@ -0,0 +1,64 @@
+package bolt
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/boltdb/bolt"
+	"github.com/boreq/flightradar-backend/storage"
+)
+
+// Key under which the position and the range of the station used to
+// calculate the statistics and the coverage are stored in the metadata
+// bucket.
+var stationKey = []byte("station")
+
+// initStation recreates the statistics and the coverage if they were
+// calculated for a different position or range of the station. The
+// statistics and the coverage of the databases which don't contain the
+// station were calculated by the migrations using the given options.
+func initStation(db *bolt.DB, options Options) error {
+	var station *storage.Position
+	err := db.View(func(tx *bolt.Tx) error {
+		metaB := tx.Bucket(metaKey)
+		if metaB == nil {
+			return errors.New("Meta bucket does not exist!")
+		}
+		if v := metaB.Get(stationKey); v != nil {
+			station = &storage.Position{}
+			return json.Unmarshal(v, station)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if station != nil && *station != options.Station {
+		log.Print("Recreating the statistics and the coverage for the changed position or range of the station")
+		if err := buildStats(db, options); err != nil {
+			return err
+		}
+		if err := buildCoverage(db, options); err != nil {
+			return err
+		}
+	}
+
+	if station == nil || *station != options.Station {
+		return db.Update(func(tx *bolt.Tx) error {
+			return putStation(tx, options.Station)
+		})
+	}
+	return nil
+}
+
+func putStation(tx *bolt.Tx, station storage.Position) error {
+	metaB := tx.Bucket(metaKey)
+	if metaB == nil {
+		return errors.New("Meta bucket does not exist!")
+	}
+	j, err := json.Marshal(station)
+	if err != nil {
+		return err
+	}
+	return metaB.Put(stationKey, j)
+}
@@ -0,0 +1,55 @@
+package bolt
+
+import (
+	"github.com/boreq/flightradar-backend/storage"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStatsAndCoverageRecreatedForDifferentRange(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "database.bolt")
+	icao := "aaaaaa"
+	altitude := 10000
+	latitude := 55.0
+	longitude := testOptions.Station.Longitude
+
+	b, err := New(file, testOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := storage.StoredData{
+		Time: time.Unix(0, 0),
+		Data: storage.Data{Icao: &icao, Altitude: &altitude, Latitude: &latitude, Longitude: &longitude},
+	}
+	if err := b.Store(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	options := testOptions
+	options.Station.Range = 500
+	b, err = New(file, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	coverage, err := b.RetrieveCoverage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coverage.Bands) != 0 {
+		t.Errorf("Wrong coverage %v", coverage)
+	}
+
+	periods, err := b.RetrieveStats(time.Unix(0, 0), time.Unix(0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 1 || len(periods[0].Stats.Polar) != 0 || periods[0].Stats.Planes[icao].MaxDistance != 0 {
+		t.Errorf("Wrong stats %v", periods)
+	}
+}
@@ -0,0 +1,222 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/boltdb/bolt"
+	"github.com/boreq/flightradar-backend/storage"
+	"time"
+)
+
+// Key for the top level bucket which contains the statistics. The
+// statistics are stored under the keys created from the start of the period
+// using timeToKey.
+var statsKey = []byte("stats")
+
+// periodStats accumulates the statistics for multiple periods indexed by the
+// keys created from the start of each period using timeToKey. It makes it
+// possible to decode and encode the stored statistics for each period only
+// once when many data points are stored at the same time.
+type periodStats map[string]*storage.Stats
+
+// get returns the statistics for the period containing the given time.
+func (p periodStats) get(t time.Time) *storage.Stats {
+	key := string(timeToKey(storage.StatsPeriodStart(t)))
+	stats, ok := p[key]
+	if !ok {
+		stats = storage.NewStats()
+		p[key] = stats
+	}
+	return stats
+}
+
+// mergeStats adds the accumulated statistics to the stored statistics for
+// each period. The statistics for the days which were already rolled up are
+// also added to the daily statistics. Storing the statistics for a period
+// closes the days which precede it, they are rolled up.
+func mergeStats(tx *bolt.Tx, periods periodStats, location *time.Location) error {
+	statsB := tx.Bucket(statsKey)
+	if statsB == nil {
+		return errors.New("Stats bucket does not exist!")
+	}
+	dailyB := tx.Bucket(dailyStatsKey)
+	if dailyB == nil {
+		return errors.New("Daily stats bucket does not exist!")
+	}
+
+	until, err := getDailyStatsUntil(tx)
+	if err != nil {
+		return err
+	}
+
+	var latest time.Time
+	for key, periodStats := range periods {
+		stats, err := getStats(statsB, []byte(key))
+		if err != nil {
+			return err
+		}
+		stats.Merge(periodStats)
+		if err := putStats(statsB, []byte(key), stats); err != nil {
+			return err
+		}
+
+		periodStart, err := keyToTime([]byte(key))
+		if err != nil {
+			return err
+		}
+		if periodStart.After(latest) {
+			latest = periodStart
+		}
+		if day := dayStart(periodStart, location); day.Before(until) {
+			dayKey := timeToKey(day)
+			stats, err := getStats(dailyB, dayKey)
+			if err != nil {
+				return err
+			}
+			stats.Merge(periodStats)
+			if err := putStats(dailyB, dayKey, stats); err != nil {
+				return err
+			}
+		}
+	}
+
+	if latest.IsZero() {
+		return nil
+	}
+	return rollUpStats(tx, location, latest)
+}
+
+// addMovementsToStats adds the movements to the statistics for the periods
+// in which they occurred.
+func addMovementsToStats(tx *bolt.Tx, movements []storage.Movement, location *time.Location) error {
+	periods := make(periodStats)
+	for _, movement := range movements {
+		periods.get(movement.Time).AddMovement(movement)
+	}
+	return mergeStats(tx, periods, location)
+}
+
+// addRejectionsToStats adds the rejections to the statistics for the periods
+// in which they occurred.
+func addRejectionsToStats(tx *bolt.Tx, rejections []storage.Rejection, location *time.Location) error {
+	periods := make(periodStats)
+	for _, rejection := range rejections {
+		periods.get(rejection.Time).AddRejection(rejection)
+	}
+	return mergeStats(tx, periods, location)
+}
+
+func getStats(statsB *bolt.Bucket, key []byte) (*storage.Stats, error) {
+	stats := storage.NewStats()
+	v := statsB.Get(key)
+	if v == nil {
+		return stats, nil
+	}
+	if err := json.Unmarshal(v, stats); err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+func putStats(statsB *bolt.Bucket, key []byte, stats *storage.Stats) error {
+	j, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	return statsB.Put(key, j)
+}
+
+// buildStats recreates the hourly and daily statistics from all data points
+// stored in the general bucket.
+func buildStats(db *bolt.DB, options Options) error {
+	err := db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(statsKey); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		if _, err := tx.CreateBucket(statsKey); err != nil {
+			return err
+		}
+		return resetDailyStats(tx, options.Location)
+	})
+	if err != nil {
+		return err
+	}
+
+	return forEachChunk(db, [][]byte{generalKey}, func(tx *bolt.Tx, records []record) error {
+		// Records are sorted by time so the statistics for a single
+		// period are usually updated only once per chunk.
+		periods := make(periodStats)
+		for _, r := range records {
+			storedData, err := decode(r.V)
+			if err != nil {
+				return err
+			}
+			periods.get(storedData.Time).Add(storedData, options.Station)
+		}
+		return mergeStats(tx, periods, options.Location)
+	})
+}
+
+func (b *blt) RetrieveStats(from time.Time, to time.Time) ([]storage.StatsForPeriod, error) {
+	var rv []storage.StatsForPeriod
+
+	t := time.Now()
+	defer func() {
+		log.Debugf("Retrieve stats: %f seconds", time.Since(t).Seconds())
+	}()
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		var err error
+		rv, err = retrieveStats(tx, from, to)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rv, nil
+}
+
+// RetrieveTop creates the leaderboard from the daily statistics.
+func (b *blt) RetrieveTop(category storage.TopCategory, from time.Time, to time.Time, limit int) ([]storage.TopEntry, error) {
+	periods, err := b.RetrieveDailyStats(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	s := storage.NewStats()
+	for _, period := range periods {
+		s.Merge(period.Stats)
+	}
+	return s.Top(category, limit)
+}
+
+// retrieveStats returns the hourly statistics for the periods which start in
+// the given time range.
+func retrieveStats(tx *bolt.Tx, from time.Time, to time.Time) ([]storage.StatsForPeriod, error) {
+	statsB := tx.Bucket(statsKey)
+	if statsB == nil {
+		return nil, errors.New("Stats bucket does not exist!")
+	}
+
+	var rv []storage.StatsForPeriod
+	c := statsB.Cursor()
+	max := timeToKey(to)
+	for k, v := c.Seek(timeToKey(from)); k != nil && bytes.Compare(k, max) <= 0; k, v = c.Next() {
+		periodStart, err := keyToTime(k)
+		if err != nil {
+			return nil, err
+		}
+		stats := storage.NewStats()
+		if err := json.Unmarshal(v, stats); err != nil {
+			return nil, err
+		}
+		rv = append(rv, storage.StatsForPeriod{
+			Time:     periodStart,
+			Duration: storage.StatsPeriod,
+			Stats:    stats,
+		})
+	}
+	return rv, nil
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"github.com/boreq/flightradar-backend/geo"
+	"math"
+	"sort"
+)
+
+// CoverageAltitudeBands lists the upper boundaries of the altitude bands in
+// feet. The last band doesn't have an upper boundary.
+var CoverageAltitudeBands = []int{10000, 20000}
+
+// CoverageBand returns the index of the altitude band which contains the
+// given altitude.
+func CoverageBand(altitude int) int {
+	return sort.Search(len(CoverageAltitudeBands), func(i int) bool {
+		return altitude < CoverageAltitudeBands[i]
+	})
+}
+
+// CoveragePoint is the farthest position recorded at a given bearing.
+type CoveragePoint struct {
+	Bearing   int     `json:"bearing"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Distance  float64 `json:"distance"`
+}
+
+// Coverage holds the range outline of the station for each altitude band.
+// Data points without altitude or position, data points merged from other
+// stations and positions beyond the range of the receiver are not taken into
+// account.
+type Coverage struct {
+	// Bands maps the index of the altitude band to the farthest points
+	// recorded for each degree of bearing.
+	Bands map[int]map[int]CoveragePoint
+}
+
+// NewCoverage creates an empty coverage.
+func NewCoverage() *Coverage {
+	return &Coverage{
+		Bands: make(map[int]map[int]CoveragePoint),
+	}
+}
+
+// NewCoveragePoint returns the altitude band and the coverage point for the
+// data point as seen from the station. False is returned if the data point
+// lacks the required data, was merged from another station or its position
+// is beyond the range of the receiver.
+func NewCoveragePoint(data StoredData, station Position) (int, CoveragePoint, bool) {
+	if data.Data.Altitude == nil || data.Data.Latitude == nil || data.Data.Longitude == nil {
+		return 0, CoveragePoint{}, false
+	}
+	if data.Data.Station != nil {
+		return 0, CoveragePoint{}, false
+	}
+	lon1 := station.Longitude
+	lat1 := station.Latitude
+	lon2 := *data.Data.Longitude
+	lat2 := *data.Data.Latitude
+	point := CoveragePoint{
+		Bearing:   int(math.Floor(geo.Bearing(lon1, lat1, lon2, lat2)+360)) % 360,
+		Latitude:  lat2,
+		Longitude: lon2,
+		Distance:  geo.Distance(lon1, lat1, lon2, lat2),
+	}
+	if !station.InRange(point.Distance) {
+		return 0, CoveragePoint{}, false
+	}
+	return CoverageBand(*data.Data.Altitude), point, true
+}
+
+// Add adds the data point as seen from the station to the coverage.
+func (c *Coverage) Add(data StoredData, station Position) {
+	band, point, ok := NewCoveragePoint(data, station)
+	if ok {
+		c.AddPoint(band, point)
+	}
+}
+
+// AddPoint adds the point to the given altitude band if it is farther than
+// the point currently recorded at the same bearing.
+func (c *Coverage) AddPoint(band int, point CoveragePoint) {
+	points, ok := c.Bands[band]
+	if !ok {
+		points = make(map[int]CoveragePoint)
+		c.Bands[band] = points
+	}
+	if current, ok := points[point.Bearing]; !ok || point.Distance > current.Distance {
+		points[point.Bearing] = point
+	}
+}
+
+// Merge adds the other coverage to this coverage.
+func (c *Coverage) Merge(other *Coverage) {
+	for band, points := range other.Bands {
+		for _, point := range points {
+			c.AddPoint(band, point)
+		}
+	}
+}
+
+// Outline returns the points recorded in the given altitude band sorted by
+// bearing.
+func (c *Coverage) Outline(band int) []CoveragePoint {
+	rv := make([]CoveragePoint, 0, len(c.Bands[band]))
+	for _, point := range c.Bands[band] {
+		rv = append(rv, point)
+	}
+	sort.Slice(rv, func(i, j int) bool { return rv[i].Bearing < rv[j].Bearing })
+	return rv
+}
@@ -0,0 +1,45 @@
+package storage
+
+// DifferingFields returns the names of the fields which have different values
+// in the provided data. The station is ignored as it describes where the
+// data was received and not the data itself.
+func DifferingFields(a, b Data) []string {
+	var rv []string
+	if !equalString(a.Icao, b.Icao) {
+		rv = append(rv, "icao")
+	}
+	if !equalString(a.FlightNumber, b.FlightNumber) {
+		rv = append(rv, "flight_number")
+	}
+	if !equalInt(a.TransponderCode, b.TransponderCode) {
+		rv = append(rv, "transponder_code")
+	}
+	if !equalInt(a.Altitude, b.Altitude) {
+		rv = append(rv, "altitude")
+	}
+	if !equalInt(a.Speed, b.Speed) {
+		rv = append(rv, "speed")
+	}
+	if !equalInt(a.Heading, b.Heading) {
+		rv = append(rv, "heading")
+	}
+	if !equalFloat(a.Latitude, b.Latitude) {
+		rv = append(rv, "latitude")
+	}
+	if !equalFloat(a.Longitude, b.Longitude) {
+		rv = append(rv, "longitude")
+	}
+	return rv
+}
+
+func equalString(a, b *string) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func equalInt(a, b *int) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func equalFloat(a, b *float64) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestDifferingFields(t *testing.T) {
+	icao := "aaaaaa"
+	station1 := "station1"
+	station2 := "station2"
+	altitude1 := 1000
+	altitude2 := 2000
+	latitude := 50.0
+
+	testCases := []struct {
+		A        Data
+		B        Data
+		Expected []string
+	}{
+		{Data{}, Data{}, nil},
+		{Data{Icao: &icao, Station: &station1}, Data{Icao: &icao, Station: &station2}, nil},
+		{Data{Altitude: &altitude1}, Data{Altitude: &altitude2}, []string{"altitude"}},
+		{Data{Altitude: &altitude1, Latitude: &latitude}, Data{}, []string{"altitude", "latitude"}},
+	}
+
+	for i, testCase := range testCases {
+		fields := DifferingFields(testCase.A, testCase.B)
+		if fmt.Sprint(fields) != fmt.Sprint(testCase.Expected) {
+			t.Errorf("Invalid fields in test case %d: %v", i, fields)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package storage
 
 import (
+	"errors"
+	"io"
+	"math"
 	"time"
 )
 
@@ -8,10 +11,68 @@ type ReadStorage interface {
 	Retrieve(icao string) ([]StoredData, error)
 	RetrieveTimerange(from time.Time, to time.Time) ([]StoredData, error)
 	RetrieveAll() ([]StoredData, error)
+
+	// RetrievePoint returns the data point of the plane recorded at the
+	// given time or nil if it isn't stored.
+	RetrievePoint(icao string, t time.Time) (*StoredData, error)
+
+	// Iterate calls the function for each data point recorded in the given
+	// time range in chronological order without loading all of them into
+	// memory at once. The iteration is stopped if the function returns an
+	// error and that error is returned.
+	Iterate(from time.Time, to time.Time, fn func(StoredData) error) error
+
+	// RetrieveArea returns the data points with a position located within
+	// the bounding box which were recorded in the given time range.
+	RetrieveArea(bbox BoundingBox, from time.Time, to time.Time) ([]StoredData, error)
+
+	// RetrieveByFlightNumber returns the data points with the given flight
+	// number which were recorded in the given time range.
+	RetrieveByFlightNumber(flightNumber string, from time.Time, to time.Time) ([]StoredData, error)
+
+	// RetrieveBySquawk returns the data points with the given transponder
+	// code which were recorded in the given time range.
+	RetrieveBySquawk(transponderCode int, from time.Time, to time.Time) ([]StoredData, error)
+
+	// RetrieveStats returns the statistics for the periods which start in
+	// the given time range. The statistics are updated whenever data is
+	// stored. Periods without any data points may be omitted.
+	RetrieveStats(from time.Time, to time.Time) ([]StatsForPeriod, error)
+
+	// RetrieveDailyStats returns the statistics in the same way as
+	// RetrieveStats but the storage may replace the periods forming a
+	// closed day which is fully contained in the time range with a single
+	// period covering the whole day. The days start at midnight in the
+	// time zone used by the storage. Retrieving the statistics for long
+	// time ranges is considerably faster this way.
+	RetrieveDailyStats(from time.Time, to time.Time) ([]StatsForPeriod, error)
+
+	// RetrieveTop returns at most limit entries of the leaderboard for the
+	// given category created from the statistics for the periods which
+	// start in the given time range. An error is returned if the category
+	// is unknown.
+	RetrieveTop(category TopCategory, from time.Time, to time.Time, limit int) ([]TopEntry, error)
+
+	// RetrieveCoverage returns the coverage calculated using all data
+	// points ever stored. The coverage is updated whenever data is stored.
+	RetrieveCoverage() (*Coverage, error)
 }
 
 type WriteStorage interface {
 	Store(data StoredData) error
+
+	// StoreBatch stores multiple data points at once which is considerably
+	// faster than storing them one by one. If any of the data points can't
+	// be stored then none of them are.
+	StoreBatch(data []StoredData) error
+
+	// StoreMovements adds the movements to the statistics for the periods
+	// in which they occurred.
+	StoreMovements(movements []Movement) error
+
+	// StoreRejections adds the rejections to the statistics for the
+	// periods in which they occurred.
+	StoreRejections(rejections []Rejection) error
 }
 
 type Storage interface {
@@ -19,6 +80,14 @@ type Storage interface {
 	WriteStorage
 }
 
+// Backuper is implemented by the storages which can write a consistent
+// snapshot of their data while data is being stored.
+type Backuper interface {
+	// Backup writes the snapshot to the writer and returns the number of
+	// written bytes.
+	Backup(w io.Writer) (int64, error)
+}
+
 type Data struct {
 	Icao            *string  `json:"icao,omitempty"`
 	FlightNumber    *string  `json:"flight_number,omitempty"`
@@ -28,9 +97,73 @@ type Data struct {
 	Heading         *int     `json:"heading,omitempty"`
 	Latitude        *float64 `json:"latitude,omitempty"`
 	Longitude       *float64 `json:"longitude,omitempty"`
+
+	// Station is the name of the station which received the data. It is
+	// set for the data points merged from the databases of other stations.
+	Station *string `json:"station,omitempty"`
+
+	// Quality holds the flags describing the problems detected in the
+	// received data. It is nil if no problems were detected.
+	Quality *int `json:"quality,omitempty"`
 }
 
 type StoredData struct {
 	Data Data      `json:"data"`
 	Time time.Time `json:"time"`
 }
+
+// BoundingBox describes an area delimited by two parallels and two
+// meridians. Bounding boxes crossing the 180th meridian are not supported.
+type BoundingBox struct {
+	MinLatitude  float64 `json:"min_latitude"`
+	MaxLatitude  float64 `json:"max_latitude"`
+	MinLongitude float64 `json:"min_longitude"`
+	MaxLongitude float64 `json:"max_longitude"`
+}
+
+// Validate returns an error if the coordinates of the bounding box aren't
+// finite, are out of range or if its minimum is greater than its maximum.
+func (b BoundingBox) Validate() error {
+	for _, v := range []float64{b.MinLatitude, b.MaxLatitude, b.MinLongitude, b.MaxLongitude} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return errors.New("Coordinates must be finite")
+		}
+	}
+	if b.MinLatitude < -90 || b.MaxLatitude > 90 {
+		return errors.New("Latitude must be in the range [-90, 90]")
+	}
+	if b.MinLongitude < -180 || b.MaxLongitude > 180 {
+		return errors.New("Longitude must be in the range [-180, 180]")
+	}
+	if b.MinLatitude > b.MaxLatitude || b.MinLongitude > b.MaxLongitude {
+		return errors.New("Minimum is greater than maximum")
+	}
+	return nil
+}
+
+// Contains returns true if the position is located within the bounding box
+// or on its edge.
+func (b BoundingBox) Contains(latitude, longitude float64) bool {
+	return latitude >= b.MinLatitude && latitude <= b.MaxLatitude &&
+		longitude >= b.MinLongitude && longitude <= b.MaxLongitude
+}
+
+// Position describes the location of the station and the range of its
+// receiver. It is used to calculate the bearings and distances in the
+// statistics and the coverage.
+type Position struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+
+	// Range is the maximum distance from the station in kilometers at
+	// which the planes can be received. Zero disables the range check.
+	Range float64 `json:"range"`
+}
+
+// InRange returns false if the distance from the station in kilometers
+// exceeds the range of the receiver. The positions further away are
+// implausible and are ignored when calculating the statistics and the
+// coverage.
+func (p Position) InRange(distance float64) bool {
+	return p.Range <= 0 || distance <= p.Range
+}
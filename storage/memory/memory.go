@@ -0,0 +1,360 @@
+// Package memory implements a storage which holds all data in memory. It is
+// meant to be used in tests and in deployments which don't need to preserve
+// the collected data between restarts.
+package memory
+
+import (
+	"errors"
+	"github.com/boreq/flightradar-backend/storage"
+	"sort"
+	"sync"
+	"time"
+)
+
+// New creates a new in-memory storage. If maxSize is larger than zero the
+// oldest data points are evicted when the number of stored data points
+// exceeds it. The statistics for the periods preceding the period of the
+// oldest data point are then removed and the coverage is recalculated from
+// the remaining data points. The statistics and the coverage are calculated
+// from the given position of the station.
+func New(maxSize int, station storage.Position) storage.Storage {
+	rv := &memory{
+		maxSize:  maxSize,
+		station:  station,
+		planes:   make(map[string][]storage.StoredData),
+		stats:    make(map[int64]*storage.Stats),
+		coverage: storage.NewCoverage(),
+	}
+	return rv
+}
+
+type memory struct {
+	maxSize int
+	station storage.Position
+	mutex   sync.RWMutex
+
+	// all contains all data points ordered by time and ICAO.
+	all []storage.StoredData
+
+	// planes contains data points of each plane ordered by time.
+	planes map[string][]storage.StoredData
+
+	// stats contains the statistics for each period indexed by the Unix
+	// time of its start. The statistics can't be reverted when a data
+	// point is evicted, instead the statistics for a period are removed
+	// once all data points recorded in it are evicted.
+	stats map[int64]*storage.Stats
+
+	// coverage is recalculated from the remaining data points when the
+	// statistics are removed.
+	coverage *storage.Coverage
+
+	// prunedBefore is the Unix time of the start of the period preceding
+	// which all statistics were removed.
+	prunedBefore int64
+}
+
+func (m *memory) Store(data storage.StoredData) error {
+	if data.Data.Icao == nil || *data.Data.Icao == "" {
+		return errors.New("ICAO can't be empty!")
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.store(data)
+	return nil
+}
+
+func (m *memory) StoreBatch(data []storage.StoredData) error {
+	for _, d := range data {
+		if d.Data.Icao == nil || *d.Data.Icao == "" {
+			return errors.New("ICAO can't be empty!")
+		}
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, d := range data {
+		m.store(d)
+	}
+	return nil
+}
+
+func (m *memory) StoreMovements(movements []storage.Movement) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, movement := range movements {
+		m.periodStats(movement.Time).AddMovement(movement)
+	}
+	return nil
+}
+
+func (m *memory) StoreRejections(rejections []storage.Rejection) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, rejection := range rejections {
+		m.periodStats(rejection.Time).AddRejection(rejection)
+	}
+	return nil
+}
+
+// periodStats returns the statistics for the period containing the given
+// time, it has to be called with the mutex locked.
+func (m *memory) periodStats(t time.Time) *storage.Stats {
+	periodStart := storage.StatsPeriodStart(t).Unix()
+	stats, ok := m.stats[periodStart]
+	if !ok {
+		stats = storage.NewStats()
+		m.stats[periodStart] = stats
+	}
+	return stats
+}
+
+// store inserts the data point, it has to be called with the mutex locked.
+func (m *memory) store(data storage.StoredData) {
+	var replaced bool
+	m.all, replaced = insert(m.all, data)
+	icao := *data.Data.Icao
+	m.planes[icao], _ = insert(m.planes[icao], data)
+
+	// The statistics are updated only when new data points are stored as
+	// they can't be reverted. The data points recorded in the periods for
+	// which the statistics were already removed will be evicted.
+	if storage.StatsPeriodStart(data.Time).Unix() >= m.prunedBefore {
+		if !replaced {
+			m.periodStats(data.Time).Add(data, m.station)
+		}
+		m.coverage.Add(data, m.station)
+	}
+
+	if m.maxSize > 0 && len(m.all) > m.maxSize {
+		for len(m.all) > m.maxSize {
+			m.evictOldest()
+		}
+		m.prune()
+	}
+}
+
+// prune removes the statistics for the periods preceding the period of the
+// oldest data point and recalculates the coverage if that period changed,
+// it has to be called with the mutex locked.
+func (m *memory) prune() {
+	oldest := storage.StatsPeriodStart(m.all[0].Time).Unix()
+	if oldest <= m.prunedBefore {
+		return
+	}
+	m.prunedBefore = oldest
+
+	for periodStart := range m.stats {
+		if periodStart < oldest {
+			delete(m.stats, periodStart)
+		}
+	}
+
+	m.coverage = storage.NewCoverage()
+	for _, data := range m.all {
+		m.coverage.Add(data, m.station)
+	}
+}
+
+func (m *memory) Retrieve(icao string) ([]storage.StoredData, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return copyData(m.planes[icao]), nil
+}
+
+func (m *memory) RetrieveTimerange(from time.Time, to time.Time) ([]storage.StoredData, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	start := sort.Search(len(m.all), func(i int) bool {
+		return !m.all[i].Time.Before(from)
+	})
+	end := sort.Search(len(m.all), func(i int) bool {
+		return m.all[i].Time.After(to)
+	})
+	if start >= end {
+		return nil, nil
+	}
+	return copyData(m.all[start:end]), nil
+}
+
+func (m *memory) RetrievePoint(icao string, t time.Time) (*storage.StoredData, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	data := m.planes[icao]
+	i := sort.Search(len(data), func(i int) bool {
+		return !data[i].Time.Before(t)
+	})
+	if i < len(data) && data[i].Time.Equal(t) {
+		rv := data[i]
+		return &rv, nil
+	}
+	return nil, nil
+}
+
+// Iterate operates on a copy of the data points so that the lock isn't held
+// while the function is executed.
+func (m *memory) Iterate(from time.Time, to time.Time, fn func(storage.StoredData) error) error {
+	data, err := m.RetrieveTimerange(from, to)
+	if err != nil {
+		return err
+	}
+	for _, d := range data {
+		if err := fn(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *memory) RetrieveArea(bbox storage.BoundingBox, from time.Time, to time.Time) ([]storage.StoredData, error) {
+	if err := bbox.Validate(); err != nil {
+		return nil, err
+	}
+	return m.retrieveFiltered(from, to, func(d storage.StoredData) bool {
+		return d.Data.Latitude != nil && d.Data.Longitude != nil &&
+			bbox.Contains(*d.Data.Latitude, *d.Data.Longitude)
+	})
+}
+
+func (m *memory) RetrieveByFlightNumber(flightNumber string, from time.Time, to time.Time) ([]storage.StoredData, error) {
+	return m.retrieveFiltered(from, to, func(d storage.StoredData) bool {
+		return d.Data.FlightNumber != nil && *d.Data.FlightNumber == flightNumber
+	})
+}
+
+func (m *memory) RetrieveBySquawk(transponderCode int, from time.Time, to time.Time) ([]storage.StoredData, error) {
+	return m.retrieveFiltered(from, to, func(d storage.StoredData) bool {
+		return d.Data.TransponderCode != nil && *d.Data.TransponderCode == transponderCode
+	})
+}
+
+// retrieveFiltered returns the data points recorded in the given time range
+// for which the provided function returns true.
+func (m *memory) retrieveFiltered(from time.Time, to time.Time, fn func(d storage.StoredData) bool) ([]storage.StoredData, error) {
+	data, err := m.RetrieveTimerange(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	var rv []storage.StoredData
+	for _, d := range data {
+		if fn(d) {
+			rv = append(rv, d)
+		}
+	}
+	return rv, nil
+}
+
+func (m *memory) RetrieveStats(from time.Time, to time.Time) ([]storage.StatsForPeriod, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var rv []storage.StatsForPeriod
+	for periodStart, stats := range m.stats {
+		t := time.Unix(periodStart, 0).UTC()
+		if t.Before(from) || t.After(to) {
+			continue
+		}
+		statsCopy := storage.NewStats()
+		statsCopy.Merge(stats)
+		rv = append(rv, storage.StatsForPeriod{
+			Time:     t,
+			Duration: storage.StatsPeriod,
+			Stats:    statsCopy,
+		})
+	}
+	sort.Slice(rv, func(i, j int) bool { return rv[i].Time.Before(rv[j].Time) })
+	return rv, nil
+}
+
+// RetrieveDailyStats returns the hourly statistics as the memory storage
+// holds a limited amount of data and doesn't create the daily statistics.
+func (m *memory) RetrieveDailyStats(from time.Time, to time.Time) ([]storage.StatsForPeriod, error) {
+	return m.RetrieveStats(from, to)
+}
+
+func (m *memory) RetrieveTop(category storage.TopCategory, from time.Time, to time.Time, limit int) ([]storage.TopEntry, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	s := storage.NewStats()
+	for periodStart, stats := range m.stats {
+		t := time.Unix(periodStart, 0).UTC()
+		if !t.Before(from) && !t.After(to) {
+			s.Merge(stats)
+		}
+	}
+	return s.Top(category, limit)
+}
+
+func (m *memory) RetrieveCoverage() (*storage.Coverage, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	rv := storage.NewCoverage()
+	rv.Merge(m.coverage)
+	return rv, nil
+}
+
+func (m *memory) RetrieveAll() ([]storage.StoredData, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return copyData(m.all), nil
+}
+
+// evictOldest removes the oldest data point. The oldest data point is also
+// the first data point stored for the plane it belongs to.
+func (m *memory) evictOldest() {
+	oldest := m.all[0]
+	m.all = m.all[1:]
+
+	icao := *oldest.Data.Icao
+	m.planes[icao] = m.planes[icao][1:]
+	if len(m.planes[icao]) == 0 {
+		delete(m.planes, icao)
+	}
+}
+
+// insert places the data point in the slice preserving the order. A data
+// point which has the same time and ICAO as the inserted one is replaced, in
+// that case true is returned.
+func insert(s []storage.StoredData, data storage.StoredData) ([]storage.StoredData, bool) {
+	i := sort.Search(len(s), func(i int) bool {
+		return !less(s[i], data)
+	})
+	if i < len(s) && !less(data, s[i]) {
+		s[i] = data
+		return s, true
+	}
+	s = append(s, storage.StoredData{})
+	copy(s[i+1:], s[i:])
+	s[i] = data
+	return s, false
+}
+
+// less orders the data points by time and ICAO in the same way they are
+// ordered by the keys used in the bolt storage.
+func less(a, b storage.StoredData) bool {
+	if !a.Time.Equal(b.Time) {
+		return a.Time.Before(b.Time)
+	}
+	return *a.Data.Icao < *b.Data.Icao
+}
+
+func copyData(s []storage.StoredData) []storage.StoredData {
+	if len(s) == 0 {
+		return nil
+	}
+	rv := make([]storage.StoredData, len(s))
+	copy(rv, s)
+	return rv
+}
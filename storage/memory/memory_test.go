@@ -0,0 +1,161 @@
+package memory
+
+import (
+	"fmt"
+	"github.com/boreq/flightradar-backend/storage"
+	"github.com/boreq/flightradar-backend/storage/storagetest"
+	"testing"
+	"time"
+)
+
+func createData(icao string, t time.Time) storage.StoredData {
+	return storage.StoredData{
+		Data: storage.Data{Icao: &icao},
+		Time: t,
+	}
+}
+
+func TestRetrieveAllOrdered(t *testing.T) {
+	m := New(0, storage.Position{})
+	m.Store(createData("bbbbbb", time.Unix(2, 0)))
+	m.Store(createData("aaaaaa", time.Unix(3, 0)))
+	m.Store(createData("aaaaaa", time.Unix(1, 0)))
+	m.Store(createData("aaaaaa", time.Unix(2, 0)))
+
+	data, err := m.RetrieveAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"1 aaaaaa", "2 aaaaaa", "2 bbbbbb", "3 aaaaaa"}
+	if len(data) != len(expected) {
+		t.Fatalf("Wrong length %d", len(data))
+	}
+	for i, d := range data {
+		s := fmt.Sprintf("%d %s", d.Time.Unix(), *d.Data.Icao)
+		if s != expected[i] {
+			t.Errorf("Wrong data point %d: %s != %s", i, s, expected[i])
+		}
+	}
+}
+
+func TestStoreReplacesIdentical(t *testing.T) {
+	m := New(0, storage.Position{})
+	m.Store(createData("aaaaaa", time.Unix(1, 0)))
+	m.Store(createData("aaaaaa", time.Unix(1, 0)))
+
+	data, err := m.Retrieve("aaaaaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 {
+		t.Fatalf("Wrong length %d", len(data))
+	}
+}
+
+func TestStoreEmptyIcao(t *testing.T) {
+	m := New(0, storage.Position{})
+	if err := m.Store(storage.StoredData{}); err == nil {
+		t.Fatal("Expected an error")
+	}
+}
+
+func TestEviction(t *testing.T) {
+	m := New(2, storage.Position{})
+	m.Store(createData("aaaaaa", time.Unix(1, 0)))
+	m.Store(createData("bbbbbb", time.Unix(2, 0)))
+	m.Store(createData("aaaaaa", time.Unix(3, 0)))
+
+	data, err := m.RetrieveAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 2 {
+		t.Fatalf("Wrong length %d", len(data))
+	}
+	if !data[0].Time.Equal(time.Unix(2, 0)) {
+		t.Fatalf("Wrong oldest data point %s", data[0].Time)
+	}
+
+	data, err = m.Retrieve("aaaaaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 1 {
+		t.Fatalf("Wrong plane data length %d", len(data))
+	}
+}
+
+func TestEvictionRemovesStats(t *testing.T) {
+	m := New(2, storagetest.Station)
+	altitude := 10000
+	latitude := 50.5
+	longitude := storagetest.Station.Longitude
+
+	far := createData("aaaaaa", time.Unix(1, 0))
+	far.Data.Altitude = &altitude
+	far.Data.Latitude = &latitude
+	far.Data.Longitude = &longitude
+	m.Store(far)
+	m.Store(createData("bbbbbb", time.Unix(2, 0)))
+	m.Store(createData("aaaaaa", time.Unix(3, 0)))
+
+	// The oldest data point was evicted but the period didn't change
+	periods, err := m.RetrieveStats(time.Unix(0, 0), time.Unix(3600, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 1 || periods[0].Stats.DataPoints != 3 {
+		t.Fatalf("Wrong stats %v", periods)
+	}
+
+	m.Store(createData("aaaaaa", time.Unix(3600, 0)))
+	m.Store(createData("bbbbbb", time.Unix(3601, 0)))
+
+	periods, err = m.RetrieveStats(time.Unix(0, 0), time.Unix(3600, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 1 || !periods[0].Time.Equal(time.Unix(3600, 0)) || periods[0].Stats.DataPoints != 2 {
+		t.Fatalf("Wrong stats %v", periods)
+	}
+
+	coverage, err := m.RetrieveCoverage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coverage.Bands) != 0 {
+		t.Fatalf("Wrong coverage %v", coverage)
+	}
+
+	// Data points recorded in the removed periods don't recreate them
+	m.Store(createData("cccccc", time.Unix(4, 0)))
+
+	periods, err = m.RetrieveStats(time.Unix(0, 0), time.Unix(3600, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 1 {
+		t.Fatalf("Wrong stats %v", periods)
+	}
+}
+
+func TestRetrieveTimerangeInclusive(t *testing.T) {
+	m := New(0, storage.Position{})
+	for i := int64(0); i < 10; i++ {
+		m.Store(createData("aaaaaa", time.Unix(i, 0)))
+	}
+
+	data, err := m.RetrieveTimerange(time.Unix(2, 0), time.Unix(5, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != 4 {
+		t.Fatalf("Wrong length %d", len(data))
+	}
+}
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return New(0, storagetest.Station)
+	})
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"time"
+)
+
+// MovementType describes how a flight was related to an airport.
+type MovementType string
+
+const (
+	MovementDeparture  MovementType = "departure"
+	MovementArrival    MovementType = "arrival"
+	MovementOverflight MovementType = "overflight"
+)
+
+// Movement is a departure, an arrival or an overflight of an airport.
+type Movement struct {
+	// Airport is the ICAO code of the airport.
+	Airport string `json:"airport"`
+
+	Type MovementType `json:"type"`
+
+	// Runway is the designator of the runway used by the departing or
+	// arriving plane. It is empty if it couldn't be detected.
+	Runway string `json:"runway,omitempty"`
+
+	Icao         string  `json:"icao"`
+	FlightNumber *string `json:"flight_number,omitempty"`
+
+	// Time is the time of the data point closest to the movement.
+	Time time.Time `json:"time"`
+}
+
+// AirportStats holds the statistics of the movements for a single airport.
+type AirportStats struct {
+	Departures  int `json:"departures"`
+	Arrivals    int `json:"arrivals"`
+	Overflights int `json:"overflights"`
+
+	// Runways counts the departures and arrivals using each runway.
+	Runways map[string]int `json:"runways,omitempty"`
+}
+
+func (a *AirportStats) merge(other *AirportStats) {
+	a.Departures += other.Departures
+	a.Arrivals += other.Arrivals
+	a.Overflights += other.Overflights
+	for runway, n := range other.Runways {
+		if a.Runways == nil {
+			a.Runways = make(map[string]int)
+		}
+		a.Runways[runway] += n
+	}
+}
+
+// AddMovement adds the movement to the statistics.
+func (s *Stats) AddMovement(m Movement) {
+	airport := &AirportStats{}
+	switch m.Type {
+	case MovementDeparture:
+		airport.Departures = 1
+	case MovementArrival:
+		airport.Arrivals = 1
+	case MovementOverflight:
+		airport.Overflights = 1
+	}
+	if m.Runway != "" {
+		airport.Runways = map[string]int{m.Runway: 1}
+	}
+	s.mergeAirport(m.Airport, airport)
+}
+
+func (s *Stats) mergeAirport(icao string, other *AirportStats) {
+	if s.Airports == nil {
+		s.Airports = make(map[string]*AirportStats)
+	}
+	airport, ok := s.Airports[icao]
+	if !ok {
+		airport = &AirportStats{}
+		s.Airports[icao] = airport
+	}
+	airport.merge(other)
+}
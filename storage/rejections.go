@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"time"
+)
+
+// Flags stored in the quality field of the data points.
+const (
+	// QualityAltitudeRejected is set if the altitude was removed from the
+	// data point because it differed from the previous altitude of the
+	// plane more than a plane can climb or descend.
+	QualityAltitudeRejected = 1 << iota
+)
+
+// RejectionReason describes why the received data was considered
+// implausible.
+type RejectionReason string
+
+const (
+	// RejectionRange means that the position was further away from the
+	// station than the range of the receiver.
+	RejectionRange RejectionReason = "range"
+
+	// RejectionSpeed means that reaching the position from the previous
+	// position of the plane required an impossible speed.
+	RejectionSpeed RejectionReason = "speed"
+
+	// RejectionAltitude means that the altitude changed faster than a
+	// plane can climb or descend.
+	RejectionAltitude RejectionReason = "altitude"
+)
+
+// Rejection records that the data received from a plane was rejected.
+type Rejection struct {
+	Icao   string          `json:"icao"`
+	Reason RejectionReason `json:"reason"`
+	Time   time.Time       `json:"time"`
+}
+
+// AddRejection adds the rejection to the statistics.
+func (s *Stats) AddRejection(r Rejection) {
+	s.addRejections(r.Reason, 1)
+}
+
+func (s *Stats) addRejections(reason RejectionReason, n int) {
+	if s.Rejections == nil {
+		s.Rejections = make(map[RejectionReason]int)
+	}
+	s.Rejections[reason] += n
+}
@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"github.com/boreq/flightradar-backend/geo"
+	"math"
+	"sort"
+	"time"
+)
+
+// StatsPeriod is the length of the periods for which the statistics are
+// accumulated.
+const StatsPeriod = time.Hour
+
+// StatsAltitudeCrossSectionStep is the height of the altitude ranges used to
+// group the data points in the altitude cross section.
+const StatsAltitudeCrossSectionStep = 5000
+
+// StatsTrackGap is the longest period of time without any data points
+// during which a flight is still considered to be continuously tracked.
+const StatsTrackGap = 10 * time.Minute
+
+// StatsPeriodStart returns the start of the period containing the given time.
+func StatsPeriodStart(t time.Time) time.Time {
+	return t.UTC().Truncate(StatsPeriod)
+}
+
+// Stats accumulates the statistics of the data points recorded during a
+// period of time. Statistics can be merged to obtain the statistics for a
+// longer period of time.
+type Stats struct {
+	// Number of data points.
+	DataPoints int `json:"data_points"`
+
+	// Number of data points in each altitude range, -1 is used for data
+	// points without altitude.
+	AltitudeCrossSection map[int]int `json:"altitude_cross_section"`
+
+	// Statistics for each plane.
+	Planes map[string]*PlaneStats `json:"planes"`
+
+	// Statistics for each flight number.
+	Flights map[string]*FlightStats `json:"flights"`
+
+	// Maximum distance from the station in kilometers for each degree of
+	// bearing.
+	Polar map[int]float64 `json:"polar"`
+
+	// Movements for each airport identified by its ICAO code.
+	Airports map[string]*AirportStats `json:"airports,omitempty"`
+
+	// Number of rejected data points for each reason.
+	Rejections map[RejectionReason]int `json:"rejections,omitempty"`
+}
+
+// PlaneStats holds the statistics for a single plane. The maximum values are
+// set to zero if the plane never reported the corresponding data.
+type PlaneStats struct {
+	DataPoints  int     `json:"data_points"`
+	MaxAltitude int     `json:"max_altitude"`
+	MaxSpeed    int     `json:"max_speed"`
+	MaxDistance float64 `json:"max_distance"`
+}
+
+// FlightStats holds the statistics for a single flight number.
+type FlightStats struct {
+	DataPoints int `json:"data_points"`
+
+	// Tracks lists the periods of time during which the planes using the
+	// flight number were continuously tracked, sorted by their start.
+	Tracks []Track `json:"tracks"`
+}
+
+// Track is a period of time during which a plane was continuously tracked.
+type Track struct {
+	Icao string    `json:"icao"`
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+// Duration returns the length of the track.
+func (t Track) Duration() time.Duration {
+	return t.To.Sub(t.From)
+}
+
+// StatsForPeriod holds the statistics for the period which starts at the
+// given time.
+type StatsForPeriod struct {
+	Time time.Time
+
+	// Duration is the length of the period, StatsPeriod or a day.
+	Duration time.Duration
+
+	Stats *Stats
+}
+
+// NewStats creates empty statistics.
+func NewStats() *Stats {
+	return &Stats{
+		AltitudeCrossSection: make(map[int]int),
+		Planes:               make(map[string]*PlaneStats),
+		Flights:              make(map[string]*FlightStats),
+		Polar:                make(map[int]float64),
+		Airports:             make(map[string]*AirportStats),
+		Rejections:           make(map[RejectionReason]int),
+	}
+}
+
+// Add adds the data point to the statistics. The bearings and distances are
+// calculated from the given position of the station. The data points merged
+// from other stations are not taken into account when calculating them as
+// they were received from a different position, neither are the positions
+// beyond the range of the receiver.
+func (s *Stats) Add(data StoredData, station Position) {
+	s.DataPoints++
+
+	key := -1
+	if data.Data.Altitude != nil {
+		key = *data.Data.Altitude / StatsAltitudeCrossSectionStep
+	}
+	s.AltitudeCrossSection[key]++
+
+	var distance float64
+	if data.Data.Latitude != nil && data.Data.Longitude != nil && data.Data.Station == nil {
+		lon1 := station.Longitude
+		lat1 := station.Latitude
+		lon2 := *data.Data.Longitude
+		lat2 := *data.Data.Latitude
+		b := int(math.Floor(geo.Bearing(lon1, lat1, lon2, lat2)+360)) % 360
+		distance = geo.Distance(lon1, lat1, lon2, lat2)
+		if !station.InRange(distance) {
+			distance = 0
+		}
+		if distance > s.Polar[b] {
+			s.Polar[b] = distance
+		}
+	}
+
+	if data.Data.Icao != nil {
+		plane := &PlaneStats{
+			DataPoints:  1,
+			MaxDistance: distance,
+		}
+		if data.Data.Altitude != nil {
+			plane.MaxAltitude = *data.Data.Altitude
+		}
+		if data.Data.Speed != nil {
+			plane.MaxSpeed = *data.Data.Speed
+		}
+		s.mergePlane(*data.Data.Icao, plane)
+	}
+
+	if data.Data.FlightNumber != nil {
+		flight := &FlightStats{
+			DataPoints: 1,
+		}
+		if data.Data.Icao != nil {
+			flight.Tracks = []Track{{*data.Data.Icao, data.Time, data.Time}}
+		}
+		s.mergeFlight(*data.Data.FlightNumber, flight)
+	}
+}
+
+// Merge adds the other statistics to these statistics.
+func (s *Stats) Merge(other *Stats) {
+	s.DataPoints += other.DataPoints
+	for k, v := range other.AltitudeCrossSection {
+		s.AltitudeCrossSection[k] += v
+	}
+	for k, v := range other.Planes {
+		s.mergePlane(k, v)
+	}
+	for k, v := range other.Flights {
+		s.mergeFlight(k, v)
+	}
+	for k, v := range other.Polar {
+		if v > s.Polar[k] {
+			s.Polar[k] = v
+		}
+	}
+	for k, v := range other.Airports {
+		s.mergeAirport(k, v)
+	}
+	for k, v := range other.Rejections {
+		s.addRejections(k, v)
+	}
+}
+
+func (s *Stats) mergePlane(icao string, other *PlaneStats) {
+	plane, ok := s.Planes[icao]
+	if !ok {
+		plane = &PlaneStats{}
+		s.Planes[icao] = plane
+	}
+	plane.DataPoints += other.DataPoints
+	if other.MaxAltitude > plane.MaxAltitude {
+		plane.MaxAltitude = other.MaxAltitude
+	}
+	if other.MaxSpeed > plane.MaxSpeed {
+		plane.MaxSpeed = other.MaxSpeed
+	}
+	if other.MaxDistance > plane.MaxDistance {
+		plane.MaxDistance = other.MaxDistance
+	}
+}
+
+func (s *Stats) mergeFlight(flightNumber string, other *FlightStats) {
+	flight, ok := s.Flights[flightNumber]
+	if !ok {
+		flight = &FlightStats{}
+		s.Flights[flightNumber] = flight
+	}
+	flight.DataPoints += other.DataPoints
+	flight.Tracks = mergeTracks(flight.Tracks, other.Tracks)
+}
+
+// mergeTracks combines two lists of tracks. Tracks of the same plane which
+// overlap or are separated by a gap shorter than StatsTrackGap are joined.
+func mergeTracks(a, b []Track) []Track {
+	all := make([]Track, 0, len(a)+len(b))
+	all = append(all, a...)
+	all = append(all, b...)
+	sort.Slice(all, func(i, j int) bool { return all[i].From.Before(all[j].From) })
+
+	var rv []Track
+	last := make(map[string]int)
+	for _, track := range all {
+		i, ok := last[track.Icao]
+		if ok && !track.From.After(rv[i].To.Add(StatsTrackGap)) {
+			if track.To.After(rv[i].To) {
+				rv[i].To = track.To
+			}
+			continue
+		}
+		last[track.Icao] = len(rv)
+		rv = append(rv, track)
+	}
+	return rv
+}
@@ -0,0 +1,73 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMergeTracks(t *testing.T) {
+	a := []Track{
+		{"aaaaaa", time.Unix(0, 0), time.Unix(60, 0)},
+		{"bbbbbb", time.Unix(0, 0), time.Unix(60, 0)},
+	}
+	b := []Track{
+		{"aaaaaa", time.Unix(60, 0).Add(StatsTrackGap), time.Unix(3600, 0)},
+		{"bbbbbb", time.Unix(61, 0).Add(StatsTrackGap), time.Unix(3600, 0)},
+	}
+
+	tracks := mergeTracks(a, b)
+	expected := []Track{
+		{"aaaaaa", time.Unix(0, 0), time.Unix(3600, 0)},
+		{"bbbbbb", time.Unix(0, 0), time.Unix(60, 0)},
+		{"bbbbbb", time.Unix(61, 0).Add(StatsTrackGap), time.Unix(3600, 0)},
+	}
+	if len(tracks) != len(expected) {
+		t.Fatalf("Wrong number of tracks %d", len(tracks))
+	}
+	for i := range tracks {
+		if tracks[i] != expected[i] {
+			t.Errorf("Wrong track %d: %v != %v", i, tracks[i], expected[i])
+		}
+	}
+}
+
+func TestStatsAddOutOfOrder(t *testing.T) {
+	icao := "aaaaaa"
+	flightNumber := "LOT3NV"
+	s := NewStats()
+	for _, timestamp := range []int64{120, 0, 60} {
+		s.Add(StoredData{
+			Time: time.Unix(timestamp, 0),
+			Data: Data{Icao: &icao, FlightNumber: &flightNumber},
+		}, Position{})
+	}
+
+	tracks := s.Flights[flightNumber].Tracks
+	if len(tracks) != 1 || tracks[0].Duration() != 2*time.Minute {
+		t.Fatalf("Wrong tracks %v", tracks)
+	}
+	if s.Planes[icao].DataPoints != 3 {
+		t.Fatalf("Wrong number of data points %d", s.Planes[icao].DataPoints)
+	}
+}
+
+func TestStatsAddBeyondRange(t *testing.T) {
+	icao := "aaaaaa"
+	latitude := 55.0
+	longitude := 20.0
+	s := NewStats()
+	s.Add(StoredData{
+		Time: time.Unix(0, 0),
+		Data: Data{Icao: &icao, Latitude: &latitude, Longitude: &longitude},
+	}, Position{Latitude: 50, Longitude: 20, Range: 500})
+
+	if len(s.Polar) != 0 {
+		t.Errorf("Wrong polar %v", s.Polar)
+	}
+	if s.Planes[icao].MaxDistance != 0 {
+		t.Errorf("Wrong max distance %f", s.Planes[icao].MaxDistance)
+	}
+	if s.DataPoints != 1 {
+		t.Errorf("Wrong number of data points %d", s.DataPoints)
+	}
+}
@@ -0,0 +1,1065 @@
+// Package storagetest implements a conformance test suite which every storage
+// backend is expected to pass.
+package storagetest
+
+import (
+	"errors"
+	"fmt"
+	"github.com/boreq/flightradar-backend/storage"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Station is the position of the station which the storage backends under
+// test have to use to calculate the statistics and the coverage.
+var Station = storage.Position{
+	Latitude:  50.08179,
+	Longitude: 19.97605,
+}
+
+// NewStorage creates a new, empty storage. It is called once for every test
+// in the suite.
+type NewStorage func(t *testing.T) storage.Storage
+
+var tests = []struct {
+	Name string
+	Test func(t *testing.T, s storage.Storage)
+}{
+	{"EmptyIcao", testEmptyIcao},
+	{"Empty", testEmpty},
+	{"RetrieveAllOrder", testRetrieveAllOrder},
+	{"RetrieveOrder", testRetrieveOrder},
+	{"RetrieveUnknownPlane", testRetrieveUnknownPlane},
+	{"TimerangeBoundaries", testTimerangeBoundaries},
+	{"TimerangeEmpty", testTimerangeEmpty},
+	{"IdenticalTimestampsDifferentPlanes", testIdenticalTimestampsDifferentPlanes},
+	{"IdenticalTimestampsSamePlane", testIdenticalTimestampsSamePlane},
+	{"SubsecondPrecision", testSubsecondPrecision},
+	{"AllFields", testAllFields},
+	{"NilOptionalFields", testNilOptionalFields},
+	{"Area", testArea},
+	{"AreaTimerange", testAreaTimerange},
+	{"AreaReplacedPosition", testAreaReplacedPosition},
+	{"AreaLarge", testAreaLarge},
+	{"AreaInvalid", testAreaInvalid},
+	{"FlightNumber", testFlightNumber},
+	{"Squawk", testSquawk},
+	{"Stats", testStats},
+	{"StatsReplaced", testStatsReplaced},
+	{"Top", testTop},
+	{"Movements", testMovements},
+	{"Rejections", testRejections},
+	{"Coverage", testCoverage},
+	{"OtherStation", testOtherStation},
+	{"StoreBatch", testStoreBatch},
+	{"StoreBatchInvalid", testStoreBatchInvalid},
+	{"StoreBatchStats", testStoreBatchStats},
+	{"RetrievePoint", testRetrievePoint},
+	{"Iterate", testIterate},
+	{"IterateError", testIterateError},
+	{"LargeBatch", testLargeBatch},
+	{"ConcurrentWrites", testConcurrentWrites},
+}
+
+// Run runs the conformance test suite against the storage backend created by
+// the provided function.
+func Run(t *testing.T, newStorage NewStorage) {
+	for _, test := range tests {
+		test := test
+		t.Run(test.Name, func(t *testing.T) {
+			test.Test(t, newStorage(t))
+		})
+	}
+}
+
+func testEmptyIcao(t *testing.T, s storage.Storage) {
+	if err := s.Store(storage.StoredData{Time: time.Unix(1, 0)}); err == nil {
+		t.Error("Storing data without ICAO should fail")
+	}
+
+	empty := ""
+	if err := s.Store(storage.StoredData{Time: time.Unix(1, 0), Data: storage.Data{Icao: &empty}}); err == nil {
+		t.Error("Storing data with empty ICAO should fail")
+	}
+}
+
+func testEmpty(t *testing.T, s storage.Storage) {
+	data, err := s.RetrieveAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectLength(t, data, 0)
+}
+
+func testRetrieveAllOrder(t *testing.T, s storage.Storage) {
+	store(t, s,
+		createData("bbbbbb", time.Unix(2, 0)),
+		createData("aaaaaa", time.Unix(3, 0)),
+		createData("cccccc", time.Unix(1, 0)),
+		createData("aaaaaa", time.Unix(2, 0)),
+	)
+
+	data, err := s.RetrieveAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data,
+		createData("cccccc", time.Unix(1, 0)),
+		createData("aaaaaa", time.Unix(2, 0)),
+		createData("bbbbbb", time.Unix(2, 0)),
+		createData("aaaaaa", time.Unix(3, 0)),
+	)
+}
+
+func testRetrieveOrder(t *testing.T, s storage.Storage) {
+	store(t, s,
+		createData("aaaaaa", time.Unix(3, 0)),
+		createData("bbbbbb", time.Unix(2, 0)),
+		createData("aaaaaa", time.Unix(1, 0)),
+		createData("aaaaaa", time.Unix(2, 0)),
+	)
+
+	data, err := s.Retrieve("aaaaaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data,
+		createData("aaaaaa", time.Unix(1, 0)),
+		createData("aaaaaa", time.Unix(2, 0)),
+		createData("aaaaaa", time.Unix(3, 0)),
+	)
+}
+
+func testRetrieveUnknownPlane(t *testing.T, s storage.Storage) {
+	store(t, s, createData("aaaaaa", time.Unix(1, 0)))
+
+	data, err := s.Retrieve("bbbbbb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectLength(t, data, 0)
+}
+
+func testTimerangeBoundaries(t *testing.T, s storage.Storage) {
+	for i := int64(0); i < 10; i++ {
+		store(t, s, createData("aaaaaa", time.Unix(100+i, 0)))
+	}
+
+	testCases := []struct {
+		From     int64
+		To       int64
+		Expected []int64
+	}{
+		{100, 109, []int64{100, 101, 102, 103, 104, 105, 106, 107, 108, 109}},
+		{102, 104, []int64{102, 103, 104}},
+		{105, 105, []int64{105}},
+		{0, 100, []int64{100}},
+		{109, 200, []int64{109}},
+		{0, 99, nil},
+		{110, 200, nil},
+		{105, 104, nil},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(fmt.Sprintf("%d-%d", testCase.From, testCase.To), func(t *testing.T) {
+			data, err := s.RetrieveTimerange(time.Unix(testCase.From, 0), time.Unix(testCase.To, 0))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var expected []storage.StoredData
+			for _, timestamp := range testCase.Expected {
+				expected = append(expected, createData("aaaaaa", time.Unix(timestamp, 0)))
+			}
+			expectData(t, data, expected...)
+		})
+	}
+}
+
+func testTimerangeEmpty(t *testing.T, s storage.Storage) {
+	data, err := s.RetrieveTimerange(time.Unix(0, 0), time.Unix(100, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectLength(t, data, 0)
+}
+
+func testIdenticalTimestampsDifferentPlanes(t *testing.T, s storage.Storage) {
+	store(t, s,
+		createData("bbbbbb", time.Unix(1, 0)),
+		createData("aaaaaa", time.Unix(1, 0)),
+	)
+
+	data, err := s.RetrieveTimerange(time.Unix(1, 0), time.Unix(1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data,
+		createData("aaaaaa", time.Unix(1, 0)),
+		createData("bbbbbb", time.Unix(1, 0)),
+	)
+
+	for _, icao := range []string{"aaaaaa", "bbbbbb"} {
+		data, err := s.Retrieve(icao)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectData(t, data, createData(icao, time.Unix(1, 0)))
+	}
+}
+
+func testIdenticalTimestampsSamePlane(t *testing.T, s storage.Storage) {
+	first := createData("aaaaaa", time.Unix(1, 0))
+	second := createData("aaaaaa", time.Unix(1, 0))
+	altitude := 1000
+	second.Data.Altitude = &altitude
+	store(t, s, first, second)
+
+	data, err := s.RetrieveAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data, second)
+
+	data, err = s.Retrieve("aaaaaa")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data, second)
+}
+
+func testSubsecondPrecision(t *testing.T, s storage.Storage) {
+	store(t, s,
+		createData("aaaaaa", time.Unix(1, 500000000)),
+		createData("aaaaaa", time.Unix(1, 42)),
+		createData("aaaaaa", time.Unix(2, 0)),
+	)
+
+	data, err := s.RetrieveTimerange(time.Unix(1, 1), time.Unix(1, 999999999))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data,
+		createData("aaaaaa", time.Unix(1, 42)),
+		createData("aaaaaa", time.Unix(1, 500000000)),
+	)
+}
+
+func testAllFields(t *testing.T, s storage.Storage) {
+	icao := "aaaaaa"
+	flightNumber := "LOT3NV"
+	transponderCode := 7700
+	altitude := 35000
+	speed := 450
+	heading := 270
+	latitude := 50.08179
+	longitude := 19.97605
+	station := "station"
+	quality := storage.QualityAltitudeRejected
+	d := storage.StoredData{
+		Time: time.Unix(1, 0),
+		Data: storage.Data{
+			Icao:            &icao,
+			FlightNumber:    &flightNumber,
+			TransponderCode: &transponderCode,
+			Altitude:        &altitude,
+			Speed:           &speed,
+			Heading:         &heading,
+			Latitude:        &latitude,
+			Longitude:       &longitude,
+			Station:         &station,
+			Quality:         &quality,
+		},
+	}
+	store(t, s, d)
+
+	data, err := s.Retrieve(icao)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data, d)
+}
+
+func testNilOptionalFields(t *testing.T, s storage.Storage) {
+	d := createData("aaaaaa", time.Unix(1, 0))
+	store(t, s, d)
+
+	data, err := s.RetrieveAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data, d)
+}
+
+func testArea(t *testing.T, s storage.Storage) {
+	store(t, s,
+		createDataWithPosition("aaaaaa", time.Unix(1, 0), 50.0, 20.0),
+		createDataWithPosition("bbbbbb", time.Unix(1, 0), 50.5, 20.5),
+		createDataWithPosition("cccccc", time.Unix(2, 0), 51.0, 21.0),
+		createDataWithPosition("dddddd", time.Unix(2, 0), 48.0, 20.5),
+		createDataWithPosition("eeeeee", time.Unix(3, 0), 50.5, 23.0),
+		createDataWithPosition("ffffff", time.Unix(3, 0), -50.5, -20.5),
+		createData("gggggg", time.Unix(3, 0)),
+	)
+
+	bbox := storage.BoundingBox{
+		MinLatitude:  50.0,
+		MaxLatitude:  51.0,
+		MinLongitude: 20.0,
+		MaxLongitude: 21.0,
+	}
+	data, err := s.RetrieveArea(bbox, time.Unix(0, 0), time.Unix(10, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data,
+		createDataWithPosition("aaaaaa", time.Unix(1, 0), 50.0, 20.0),
+		createDataWithPosition("bbbbbb", time.Unix(1, 0), 50.5, 20.5),
+		createDataWithPosition("cccccc", time.Unix(2, 0), 51.0, 21.0),
+	)
+
+	bbox = storage.BoundingBox{
+		MinLatitude:  -51.0,
+		MaxLatitude:  -50.0,
+		MinLongitude: -21.0,
+		MaxLongitude: -20.0,
+	}
+	data, err = s.RetrieveArea(bbox, time.Unix(0, 0), time.Unix(10, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data,
+		createDataWithPosition("ffffff", time.Unix(3, 0), -50.5, -20.5),
+	)
+}
+
+func testAreaTimerange(t *testing.T, s storage.Storage) {
+	for i := int64(0); i < 10; i++ {
+		store(t, s, createDataWithPosition("aaaaaa", time.Unix(100+i, 0), 50.5, 20.5))
+	}
+
+	bbox := storage.BoundingBox{
+		MinLatitude:  50.0,
+		MaxLatitude:  51.0,
+		MinLongitude: 20.0,
+		MaxLongitude: 21.0,
+	}
+	data, err := s.RetrieveArea(bbox, time.Unix(102, 0), time.Unix(104, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data,
+		createDataWithPosition("aaaaaa", time.Unix(102, 0), 50.5, 20.5),
+		createDataWithPosition("aaaaaa", time.Unix(103, 0), 50.5, 20.5),
+		createDataWithPosition("aaaaaa", time.Unix(104, 0), 50.5, 20.5),
+	)
+}
+
+func testAreaReplacedPosition(t *testing.T, s storage.Storage) {
+	store(t, s,
+		createDataWithPosition("aaaaaa", time.Unix(1, 0), 50.5, 20.5),
+		createDataWithPosition("aaaaaa", time.Unix(1, 0), 10.5, 10.5),
+	)
+
+	bbox := storage.BoundingBox{
+		MinLatitude:  50.0,
+		MaxLatitude:  51.0,
+		MinLongitude: 20.0,
+		MaxLongitude: 21.0,
+	}
+	data, err := s.RetrieveArea(bbox, time.Unix(0, 0), time.Unix(10, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectLength(t, data, 0)
+}
+
+func testAreaLarge(t *testing.T, s storage.Storage) {
+	store(t, s,
+		createDataWithPosition("aaaaaa", time.Unix(1, 0), 50.0, 20.0),
+		createDataWithPosition("bbbbbb", time.Unix(1, 0), -50.5, -170.5),
+		createDataWithPosition("cccccc", time.Unix(2, 0), 89.0, 179.0),
+		createData("dddddd", time.Unix(3, 0)),
+	)
+
+	bbox := storage.BoundingBox{
+		MinLatitude:  -90,
+		MaxLatitude:  90,
+		MinLongitude: -180,
+		MaxLongitude: 180,
+	}
+	data, err := s.RetrieveArea(bbox, time.Unix(0, 0), time.Unix(10, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data,
+		createDataWithPosition("aaaaaa", time.Unix(1, 0), 50.0, 20.0),
+		createDataWithPosition("bbbbbb", time.Unix(1, 0), -50.5, -170.5),
+		createDataWithPosition("cccccc", time.Unix(2, 0), 89.0, 179.0),
+	)
+}
+
+func testAreaInvalid(t *testing.T, s storage.Storage) {
+	store(t, s, createDataWithPosition("aaaaaa", time.Unix(1, 0), 50.0, 20.0))
+
+	testCases := []storage.BoundingBox{
+		{MinLatitude: -1e9, MaxLatitude: 1e9, MinLongitude: 19, MaxLongitude: 21},
+		{MinLatitude: 49, MaxLatitude: 51, MinLongitude: -1e9, MaxLongitude: 1e9},
+		{MinLatitude: math.NaN(), MaxLatitude: 51, MinLongitude: 19, MaxLongitude: 21},
+		{MinLatitude: 49, MaxLatitude: math.Inf(1), MinLongitude: 19, MaxLongitude: 21},
+		{MinLatitude: 51, MaxLatitude: 49, MinLongitude: 19, MaxLongitude: 21},
+	}
+	for _, bbox := range testCases {
+		if _, err := s.RetrieveArea(bbox, time.Unix(0, 0), time.Unix(10, 0)); err == nil {
+			t.Errorf("No error for %+v", bbox)
+		}
+	}
+}
+
+func testFlightNumber(t *testing.T, s storage.Storage) {
+	store(t, s,
+		createDataWithFlightNumber("aaaaaa", time.Unix(1, 0), "LOT3NV"),
+		createDataWithFlightNumber("bbbbbb", time.Unix(2, 0), "LOT3NV"),
+		createDataWithFlightNumber("cccccc", time.Unix(2, 0), "LOT3N"),
+		createDataWithFlightNumber("aaaaaa", time.Unix(3, 0), "RYR1"),
+		createDataWithFlightNumber("dddddd", time.Unix(3, 0), "LOT3NV"),
+		createData("eeeeee", time.Unix(3, 0)),
+	)
+	// Replaced data points are no longer returned.
+	store(t, s, createDataWithFlightNumber("dddddd", time.Unix(3, 0), "LOT3NW"))
+
+	data, err := s.RetrieveByFlightNumber("LOT3NV", time.Unix(0, 0), time.Unix(10, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data,
+		createDataWithFlightNumber("aaaaaa", time.Unix(1, 0), "LOT3NV"),
+		createDataWithFlightNumber("bbbbbb", time.Unix(2, 0), "LOT3NV"),
+	)
+
+	data, err = s.RetrieveByFlightNumber("LOT3NV", time.Unix(2, 0), time.Unix(10, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data,
+		createDataWithFlightNumber("bbbbbb", time.Unix(2, 0), "LOT3NV"),
+	)
+
+	data, err = s.RetrieveByFlightNumber("UNKNOWN", time.Unix(0, 0), time.Unix(10, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectLength(t, data, 0)
+}
+
+func testSquawk(t *testing.T, s storage.Storage) {
+	store(t, s,
+		createDataWithSquawk("aaaaaa", time.Unix(1, 0), 7700),
+		createDataWithSquawk("bbbbbb", time.Unix(2, 0), 7000),
+		createDataWithSquawk("cccccc", time.Unix(3, 0), 7700),
+		createDataWithSquawk("dddddd", time.Unix(4, 0), 770),
+		createData("eeeeee", time.Unix(5, 0)),
+	)
+
+	data, err := s.RetrieveBySquawk(7700, time.Unix(0, 0), time.Unix(10, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data,
+		createDataWithSquawk("aaaaaa", time.Unix(1, 0), 7700),
+		createDataWithSquawk("cccccc", time.Unix(3, 0), 7700),
+	)
+
+	data, err = s.RetrieveBySquawk(7700, time.Unix(0, 0), time.Unix(2, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data,
+		createDataWithSquawk("aaaaaa", time.Unix(1, 0), 7700),
+	)
+}
+
+func testStats(t *testing.T, s storage.Storage) {
+	period := storage.StatsPeriodStart(time.Date(2018, 2, 8, 12, 0, 0, 0, time.UTC))
+	nextPeriod := period.Add(storage.StatsPeriod)
+	store(t, s,
+		createDataWithFlightNumber("aaaaaa", period, "LOT3NV"),
+		createDataWithFlightNumber("aaaaaa", period.Add(time.Second), "LOT3NV"),
+		createDataWithPosition("bbbbbb", period.Add(2*time.Second), 50.5, 20.5),
+		createData("aaaaaa", nextPeriod),
+	)
+
+	periods, err := s.RetrieveStats(period, nextPeriod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 2 {
+		t.Fatalf("Wrong number of periods %d", len(periods))
+	}
+	if !periods[0].Time.Equal(period) || !periods[1].Time.Equal(nextPeriod) {
+		t.Fatalf("Wrong periods %s %s", periods[0].Time, periods[1].Time)
+	}
+
+	stats := periods[0].Stats
+	if stats.DataPoints != 3 {
+		t.Errorf("Wrong number of data points %d", stats.DataPoints)
+	}
+	if len(stats.Planes) != 2 || stats.Planes["aaaaaa"].DataPoints != 2 {
+		t.Errorf("Wrong planes %v", stats.Planes)
+	}
+	if len(stats.Flights) != 1 || stats.Flights["LOT3NV"].DataPoints != 2 {
+		t.Errorf("Wrong flights %v", stats.Flights)
+	}
+	if stats.AltitudeCrossSection[-1] != 3 {
+		t.Errorf("Wrong altitude cross section %v", stats.AltitudeCrossSection)
+	}
+	if len(stats.Polar) != 1 {
+		t.Errorf("Wrong polar %v", stats.Polar)
+	}
+	if stats.Planes["bbbbbb"].MaxDistance == 0 {
+		t.Errorf("Wrong max distance %f", stats.Planes["bbbbbb"].MaxDistance)
+	}
+	if tracks := stats.Flights["LOT3NV"].Tracks; len(tracks) != 1 || tracks[0].Duration() != time.Second {
+		t.Errorf("Wrong tracks %v", tracks)
+	}
+
+	periods, err = s.RetrieveStats(nextPeriod, nextPeriod.Add(storage.StatsPeriod/2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 1 || periods[0].Stats.DataPoints != 1 {
+		t.Fatalf("Wrong periods %v", periods)
+	}
+}
+
+func testTop(t *testing.T, s storage.Storage) {
+	period := storage.StatsPeriodStart(time.Date(2018, 2, 8, 12, 0, 0, 0, time.UTC))
+	nextPeriod := period.Add(storage.StatsPeriod)
+	store(t, s,
+		createData("aaaaaa", period),
+		createData("bbbbbb", period),
+		createData("bbbbbb", period.Add(time.Second)),
+		createData("cccccc", nextPeriod),
+		createData("cccccc", nextPeriod.Add(time.Second)),
+		createData("cccccc", nextPeriod.Add(2*time.Second)),
+	)
+
+	entries, err := s.RetrieveTop(storage.TopPlanes, period, nextPeriod, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Key != "cccccc" || entries[0].Value != 3 || entries[1].Key != "bbbbbb" {
+		t.Errorf("Wrong entries %v", entries)
+	}
+
+	entries, err = s.RetrieveTop(storage.TopPlanes, period, period, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Key != "bbbbbb" || entries[1].Key != "aaaaaa" {
+		t.Errorf("Wrong entries %v", entries)
+	}
+
+	if _, err := s.RetrieveTop("unknown", period, nextPeriod, 10); err == nil {
+		t.Error("Unknown category should be rejected")
+	}
+}
+
+func testStatsReplaced(t *testing.T, s storage.Storage) {
+	store(t, s,
+		createData("aaaaaa", time.Unix(1, 0)),
+		createData("aaaaaa", time.Unix(1, 0)),
+	)
+
+	periods, err := s.RetrieveStats(time.Unix(0, 0), time.Unix(10, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 1 || periods[0].Stats.DataPoints != 1 {
+		t.Fatalf("Wrong periods %v", periods)
+	}
+}
+
+func testMovements(t *testing.T, s storage.Storage) {
+	period := storage.StatsPeriodStart(time.Date(2018, 2, 8, 12, 0, 0, 0, time.UTC))
+	nextPeriod := period.Add(storage.StatsPeriod)
+	store(t, s, createData("aaaaaa", period))
+
+	movements := []storage.Movement{
+		{Airport: "EPKK", Type: storage.MovementDeparture, Runway: "25", Icao: "aaaaaa", Time: period},
+		{Airport: "EPKK", Type: storage.MovementArrival, Runway: "25", Icao: "bbbbbb", Time: period.Add(time.Minute)},
+		{Airport: "EPKT", Type: storage.MovementOverflight, Icao: "aaaaaa", Time: period.Add(time.Minute)},
+		{Airport: "EPKK", Type: storage.MovementDeparture, Runway: "07", Icao: "cccccc", Time: nextPeriod},
+	}
+	if err := s.StoreMovements(movements[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StoreMovements(movements[2:]); err != nil {
+		t.Fatal(err)
+	}
+
+	periods, err := s.RetrieveStats(period, nextPeriod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 2 {
+		t.Fatalf("Wrong number of periods %d", len(periods))
+	}
+	if periods[0].Stats.DataPoints != 1 {
+		t.Errorf("Wrong number of data points %d", periods[0].Stats.DataPoints)
+	}
+
+	airports := periods[0].Stats.Airports
+	if len(airports) != 2 {
+		t.Fatalf("Wrong airports %v", airports)
+	}
+	if a := airports["EPKK"]; a.Departures != 1 || a.Arrivals != 1 || a.Overflights != 0 || a.Runways["25"] != 2 {
+		t.Errorf("Wrong movements %+v", a)
+	}
+	if a := airports["EPKT"]; a.Overflights != 1 || len(a.Runways) != 0 {
+		t.Errorf("Wrong movements %+v", a)
+	}
+	if a := periods[1].Stats.Airports["EPKK"]; a == nil || a.Departures != 1 || a.Runways["07"] != 1 {
+		t.Errorf("Wrong movements %+v", a)
+	}
+}
+
+func testRejections(t *testing.T, s storage.Storage) {
+	period := storage.StatsPeriodStart(time.Date(2018, 2, 8, 12, 0, 0, 0, time.UTC))
+	nextPeriod := period.Add(storage.StatsPeriod)
+	store(t, s, createData("aaaaaa", period))
+
+	rejections := []storage.Rejection{
+		{Icao: "aaaaaa", Reason: storage.RejectionRange, Time: period},
+		{Icao: "bbbbbb", Reason: storage.RejectionRange, Time: period.Add(time.Minute)},
+		{Icao: "aaaaaa", Reason: storage.RejectionSpeed, Time: period.Add(time.Minute)},
+		{Icao: "aaaaaa", Reason: storage.RejectionAltitude, Time: nextPeriod},
+	}
+	if err := s.StoreRejections(rejections[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.StoreRejections(rejections[2:]); err != nil {
+		t.Fatal(err)
+	}
+
+	periods, err := s.RetrieveStats(period, nextPeriod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 2 {
+		t.Fatalf("Wrong number of periods %d", len(periods))
+	}
+	if periods[0].Stats.DataPoints != 1 {
+		t.Errorf("Wrong number of data points %d", periods[0].Stats.DataPoints)
+	}
+
+	first := periods[0].Stats.Rejections
+	if len(first) != 2 || first[storage.RejectionRange] != 2 || first[storage.RejectionSpeed] != 1 {
+		t.Errorf("Wrong rejections %v", first)
+	}
+	second := periods[1].Stats.Rejections
+	if len(second) != 1 || second[storage.RejectionAltitude] != 1 {
+		t.Errorf("Wrong rejections %v", second)
+	}
+}
+
+func testCoverage(t *testing.T, s storage.Storage) {
+	// All points are located north of the station.
+	lon := Station.Longitude
+	near := createDataWithPosition("aaaaaa", time.Unix(1, 0), 50.5, lon)
+	far := createDataWithPosition("bbbbbb", time.Unix(2, 0), 51.5, lon)
+	high := createDataWithPosition("cccccc", time.Unix(3, 0), 52.5, lon)
+	noAltitude := createDataWithPosition("dddddd", time.Unix(4, 0), 53.5, lon)
+	low := 5000
+	higher := 35000
+	near.Data.Altitude = &low
+	far.Data.Altitude = &low
+	high.Data.Altitude = &higher
+	store(t, s, far, near, high, noAltitude)
+
+	coverage, err := s.RetrieveCoverage()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lowOutline := coverage.Outline(storage.CoverageBand(low))
+	if len(lowOutline) != 1 || lowOutline[0].Latitude != 51.5 {
+		t.Errorf("Wrong low outline %v", lowOutline)
+	}
+	highOutline := coverage.Outline(storage.CoverageBand(higher))
+	if len(highOutline) != 1 || highOutline[0].Latitude != 52.5 {
+		t.Errorf("Wrong high outline %v", highOutline)
+	}
+	if len(coverage.Bands) != 2 {
+		t.Errorf("Wrong number of bands %d", len(coverage.Bands))
+	}
+}
+
+func testOtherStation(t *testing.T, s storage.Storage) {
+	station := "other"
+	altitude := 5000
+	d := createDataWithPosition("aaaaaa", time.Unix(1, 0), 52.5, Station.Longitude)
+	d.Data.Altitude = &altitude
+	d.Data.Station = &station
+	store(t, s, d)
+
+	periods, err := s.RetrieveStats(time.Unix(0, 0), time.Unix(10, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(periods) != 1 {
+		t.Fatalf("Wrong number of periods %d", len(periods))
+	}
+	stats := periods[0].Stats
+	if stats.DataPoints != 1 || stats.Planes["aaaaaa"].DataPoints != 1 {
+		t.Errorf("Data point wasn't counted %v", stats.Planes)
+	}
+	if len(stats.Polar) != 0 {
+		t.Errorf("Wrong polar %v", stats.Polar)
+	}
+	if stats.Planes["aaaaaa"].MaxDistance != 0 {
+		t.Errorf("Wrong max distance %f", stats.Planes["aaaaaa"].MaxDistance)
+	}
+
+	coverage, err := s.RetrieveCoverage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(coverage.Bands) != 0 {
+		t.Errorf("Wrong coverage %v", coverage.Bands)
+	}
+}
+
+func testStoreBatch(t *testing.T, s storage.Storage) {
+	batch := []storage.StoredData{
+		createData("bbbbbb", time.Unix(2, 0)),
+		createDataWithFlightNumber("aaaaaa", time.Unix(1, 0), "LOT1"),
+		createDataWithFlightNumber("aaaaaa", time.Unix(1, 0), "LOT2"),
+	}
+	if err := s.StoreBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := s.RetrieveAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data,
+		createDataWithFlightNumber("aaaaaa", time.Unix(1, 0), "LOT2"),
+		createData("bbbbbb", time.Unix(2, 0)),
+	)
+
+	data, err = s.RetrieveByFlightNumber("LOT1", time.Unix(0, 0), time.Unix(10, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectLength(t, data, 0)
+}
+
+func testStoreBatchInvalid(t *testing.T, s storage.Storage) {
+	batch := []storage.StoredData{
+		createData("aaaaaa", time.Unix(1, 0)),
+		{Time: time.Unix(2, 0)},
+	}
+	if err := s.StoreBatch(batch); err == nil {
+		t.Fatal("Storing data without ICAO should fail")
+	}
+
+	data, err := s.RetrieveAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectLength(t, data, 0)
+}
+
+func testStoreBatchStats(t *testing.T, s storage.Storage) {
+	batch := []storage.StoredData{
+		createData("aaaaaa", time.Unix(1, 0)),
+		createData("aaaaaa", time.Unix(1, 0)),
+		createData("bbbbbb", time.Unix(2, 0)),
+	}
+	if err := s.StoreBatch(batch); err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := s.RetrieveStats(time.Unix(0, 0), time.Unix(10, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].Stats.DataPoints != 2 {
+		t.Fatalf("Invalid stats %+v", stats)
+	}
+}
+
+func testRetrievePoint(t *testing.T, s storage.Storage) {
+	store(t, s, createDataWithFlightNumber("aaaaaa", time.Unix(1, 500), "LOT1"))
+
+	testCases := []struct {
+		Icao     string
+		Time     time.Time
+		Expected bool
+	}{
+		{"aaaaaa", time.Unix(1, 500), true},
+		{"aaaaaa", time.Unix(1, 0), false},
+		{"aaaaaa", time.Unix(2, 0), false},
+		{"bbbbbb", time.Unix(1, 500), false},
+	}
+
+	for _, testCase := range testCases {
+		d, err := s.RetrievePoint(testCase.Icao, testCase.Time)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (d != nil) != testCase.Expected {
+			t.Errorf("Invalid result for %s %s", testCase.Icao, testCase.Time)
+		}
+		if d != nil {
+			expectData(t, []storage.StoredData{*d}, createDataWithFlightNumber("aaaaaa", time.Unix(1, 500), "LOT1"))
+		}
+	}
+}
+
+func testIterate(t *testing.T, s storage.Storage) {
+	store(t, s,
+		createData("bbbbbb", time.Unix(2, 0)),
+		createData("aaaaaa", time.Unix(3, 0)),
+		createData("aaaaaa", time.Unix(1, 0)),
+		createData("aaaaaa", time.Unix(5, 0)),
+	)
+
+	var data []storage.StoredData
+	err := s.Iterate(time.Unix(1, 0), time.Unix(3, 0), func(d storage.StoredData) error {
+		data = append(data, d)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, data,
+		createData("aaaaaa", time.Unix(1, 0)),
+		createData("bbbbbb", time.Unix(2, 0)),
+		createData("aaaaaa", time.Unix(3, 0)),
+	)
+}
+
+func testIterateError(t *testing.T, s storage.Storage) {
+	store(t, s,
+		createData("aaaaaa", time.Unix(1, 0)),
+		createData("aaaaaa", time.Unix(2, 0)),
+	)
+
+	expectedErr := errors.New("stop")
+	calls := 0
+	err := s.Iterate(time.Unix(0, 0), time.Unix(10, 0), func(d storage.StoredData) error {
+		calls++
+		return expectedErr
+	})
+	if err != expectedErr {
+		t.Fatalf("Invalid error %v", err)
+	}
+	if calls != 1 {
+		t.Fatalf("Iteration wasn't stopped after %d calls", calls)
+	}
+}
+
+func testLargeBatch(t *testing.T, s storage.Storage) {
+	const planes = 10
+	const pointsPerPlane = 200
+
+	var data []storage.StoredData
+	for i := 0; i < pointsPerPlane; i++ {
+		for j := 0; j < planes; j++ {
+			data = append(data, createData(fmt.Sprintf("%06x", j), time.Unix(int64(i), 0)))
+		}
+	}
+	storeConcurrently(t, s, data)
+
+	all, err := s.RetrieveAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, all, data...)
+
+	for j := 0; j < planes; j++ {
+		planeData, err := s.Retrieve(fmt.Sprintf("%06x", j))
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectLength(t, planeData, pointsPerPlane)
+	}
+
+	timerange, err := s.RetrieveTimerange(time.Unix(50, 0), time.Unix(99, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, timerange, data[50*planes:100*planes]...)
+}
+
+func testConcurrentWrites(t *testing.T, s storage.Storage) {
+	var data []storage.StoredData
+	for i := 0; i < 100; i++ {
+		data = append(data, createData("aaaaaa", time.Unix(int64(i), 0)))
+		data = append(data, createData("bbbbbb", time.Unix(int64(i), 0)))
+	}
+	storeConcurrently(t, s, data)
+
+	all, err := s.RetrieveAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectData(t, all, data...)
+}
+
+// storeConcurrently stores the data using a number of goroutines.
+func storeConcurrently(t *testing.T, s storage.Storage, data []storage.StoredData) {
+	const workers = 20
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(data))
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := i; j < len(data); j += workers {
+				if err := s.Store(data[j]); err != nil {
+					errs <- err
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func store(t *testing.T, s storage.Storage, data ...storage.StoredData) {
+	for _, d := range data {
+		if err := s.Store(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func createData(icao string, t time.Time) storage.StoredData {
+	return storage.StoredData{
+		Data: storage.Data{Icao: &icao},
+		Time: t,
+	}
+}
+
+func createDataWithPosition(icao string, t time.Time, latitude, longitude float64) storage.StoredData {
+	rv := createData(icao, t)
+	rv.Data.Latitude = &latitude
+	rv.Data.Longitude = &longitude
+	return rv
+}
+
+func createDataWithFlightNumber(icao string, t time.Time, flightNumber string) storage.StoredData {
+	rv := createData(icao, t)
+	rv.Data.FlightNumber = &flightNumber
+	return rv
+}
+
+func createDataWithSquawk(icao string, t time.Time, transponderCode int) storage.StoredData {
+	rv := createData(icao, t)
+	rv.Data.TransponderCode = &transponderCode
+	return rv
+}
+
+func expectLength(t *testing.T, data []storage.StoredData, length int) {
+	t.Helper()
+	if len(data) != length {
+		t.Fatalf("Wrong length %d != %d", len(data), length)
+	}
+}
+
+func expectData(t *testing.T, data []storage.StoredData, expected ...storage.StoredData) {
+	t.Helper()
+	expectLength(t, data, len(expected))
+	for i := range data {
+		if err := compare(data[i], expected[i]); err != nil {
+			t.Fatalf("Data point %d: %s", i, err)
+		}
+	}
+}
+
+// compare returns an error if the data points differ.
+func compare(a, b storage.StoredData) error {
+	if !a.Time.Equal(b.Time) {
+		return fmt.Errorf("time %s != %s", a.Time, b.Time)
+	}
+	if err := compareString("icao", a.Data.Icao, b.Data.Icao); err != nil {
+		return err
+	}
+	if err := compareString("flight number", a.Data.FlightNumber, b.Data.FlightNumber); err != nil {
+		return err
+	}
+	if err := compareInt("transponder code", a.Data.TransponderCode, b.Data.TransponderCode); err != nil {
+		return err
+	}
+	if err := compareInt("altitude", a.Data.Altitude, b.Data.Altitude); err != nil {
+		return err
+	}
+	if err := compareInt("speed", a.Data.Speed, b.Data.Speed); err != nil {
+		return err
+	}
+	if err := compareInt("heading", a.Data.Heading, b.Data.Heading); err != nil {
+		return err
+	}
+	if err := compareFloat("latitude", a.Data.Latitude, b.Data.Latitude); err != nil {
+		return err
+	}
+	if err := compareFloat("longitude", a.Data.Longitude, b.Data.Longitude); err != nil {
+		return err
+	}
+	if err := compareString("station", a.Data.Station, b.Data.Station); err != nil {
+		return err
+	}
+	if err := compareInt("quality", a.Data.Quality, b.Data.Quality); err != nil {
+		return err
+	}
+	return nil
+}
+
+func compareString(name string, a, b *string) error {
+	if (a == nil) != (b == nil) || (a != nil && *a != *b) {
+		return fmt.Errorf("%s %s != %s", name, formatPointer(a), formatPointer(b))
+	}
+	return nil
+}
+
+func compareInt(name string, a, b *int) error {
+	if (a == nil) != (b == nil) || (a != nil && *a != *b) {
+		return fmt.Errorf("%s %s != %s", name, formatPointer(a), formatPointer(b))
+	}
+	return nil
+}
+
+func compareFloat(name string, a, b *float64) error {
+	if (a == nil) != (b == nil) || (a != nil && *a != *b) {
+		return fmt.Errorf("%s %s != %s", name, formatPointer(a), formatPointer(b))
+	}
+	return nil
+}
+
+func formatPointer(v interface{}) string {
+	switch v := v.(type) {
+	case *string:
+		if v != nil {
+			return *v
+		}
+	case *int:
+		if v != nil {
+			return fmt.Sprint(*v)
+		}
+	case *float64:
+		if v != nil {
+			return fmt.Sprint(*v)
+		}
+	}
+	return "nil"
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"fmt"
+	"github.com/boreq/flightradar-backend/metadata"
+	"sort"
+)
+
+// TopCategory identifies a leaderboard.
+type TopCategory string
+
+const (
+	// TopPlanes lists the airframes sorted by the number of data points.
+	TopPlanes TopCategory = "planes"
+
+	// TopAltitude lists the airframes sorted by the maximum altitude.
+	TopAltitude TopCategory = "altitude"
+
+	// TopSpeed lists the airframes sorted by the maximum speed.
+	TopSpeed TopCategory = "speed"
+
+	// TopDistance lists the airframes sorted by the maximum distance from
+	// the station.
+	TopDistance TopCategory = "distance"
+
+	// TopAirlines lists the airline designators sorted by the number of
+	// tracked flights.
+	TopAirlines TopCategory = "airlines"
+
+	// TopDuration lists the tracked flights sorted by their duration in
+	// seconds.
+	TopDuration TopCategory = "duration"
+)
+
+// topCategories lists the functions which create the unsorted leaderboard
+// entries for each category.
+var topCategories = map[TopCategory]func(s *Stats) []TopEntry{
+	TopPlanes: func(s *Stats) []TopEntry {
+		return topPlanes(s, func(p *PlaneStats) float64 { return float64(p.DataPoints) })
+	},
+	TopAltitude: func(s *Stats) []TopEntry {
+		return topPlanes(s, func(p *PlaneStats) float64 { return float64(p.MaxAltitude) })
+	},
+	TopSpeed: func(s *Stats) []TopEntry {
+		return topPlanes(s, func(p *PlaneStats) float64 { return float64(p.MaxSpeed) })
+	},
+	TopDistance: func(s *Stats) []TopEntry {
+		return topPlanes(s, func(p *PlaneStats) float64 { return p.MaxDistance })
+	},
+	TopAirlines: topAirlines,
+	TopDuration: topDuration,
+}
+
+// TopEntry is a single entry of a leaderboard.
+type TopEntry struct {
+	Key   string
+	Value float64
+
+	// Track is set only for the entries which describe tracked flights.
+	Track *Track
+}
+
+// ValidTopCategory returns true if the category is known.
+func ValidTopCategory(category TopCategory) bool {
+	_, ok := topCategories[category]
+	return ok
+}
+
+// Top returns at most limit entries of the leaderboard for the given category
+// sorted by value in descending order. The entries with equal values are
+// sorted by key.
+func (s *Stats) Top(category TopCategory, limit int) ([]TopEntry, error) {
+	fn, ok := topCategories[category]
+	if !ok {
+		return nil, fmt.Errorf("Unknown top category: %s", category)
+	}
+
+	entries := fn(s)
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Value != entries[j].Value {
+			return entries[i].Value > entries[j].Value
+		}
+		return entries[i].Key < entries[j].Key
+	})
+	if len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries, nil
+}
+
+// FlightsByAirline counts the tracked flights of each airline identified by
+// its ICAO designator.
+func (s *Stats) FlightsByAirline() map[string]int {
+	rv := make(map[string]int)
+	for flightNumber, flight := range s.Flights {
+		if callsign, ok := metadata.ParseCallsign(flightNumber); ok {
+			rv[callsign.Airline] += len(flight.Tracks)
+		}
+	}
+	return rv
+}
+
+func topPlanes(s *Stats, value func(p *PlaneStats) float64) []TopEntry {
+	rv := make([]TopEntry, 0, len(s.Planes))
+	for icao, plane := range s.Planes {
+		if v := value(plane); v > 0 {
+			rv = append(rv, TopEntry{Key: icao, Value: v})
+		}
+	}
+	return rv
+}
+
+func topAirlines(s *Stats) []TopEntry {
+	airlines := s.FlightsByAirline()
+	rv := make([]TopEntry, 0, len(airlines))
+	for designator, n := range airlines {
+		rv = append(rv, TopEntry{Key: designator, Value: float64(n)})
+	}
+	return rv
+}
+
+func topDuration(s *Stats) []TopEntry {
+	rv := make([]TopEntry, 0)
+	for flightNumber, flight := range s.Flights {
+		for i := range flight.Tracks {
+			track := flight.Tracks[i]
+			rv = append(rv, TopEntry{
+				Key:   flightNumber,
+				Value: track.Duration().Seconds(),
+				Track: &track,
+			})
+		}
+	}
+	return rv
+}
@@ -0,0 +1,105 @@
+package storage
+
+import (
+	"sort"
+	"time"
+)
+
+// SplitTracks groups the data points by plane and splits them into tracks
+// whenever the plane wasn't seen for longer than StatsTrackGap or changed its
+// flight number. The data points in each track are sorted by time and the
+// tracks are sorted by the time of their first data point.
+func SplitTracks(data []StoredData) [][]StoredData {
+	sorted := make([]StoredData, len(data))
+	copy(sorted, data)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	splitter := NewTrackSplitter()
+	var rv [][]StoredData
+	for _, d := range sorted {
+		rv = append(rv, splitter.Add(d)...)
+	}
+	rv = append(rv, splitter.Flush()...)
+	sortTracks(rv)
+	return rv
+}
+
+// TrackSplitter splits a stream of data points into tracks in the same way as
+// SplitTracks. Only the tracks which can still be extended are kept in memory.
+type TrackSplitter struct {
+	open      map[string][]StoredData
+	lastCheck time.Time
+}
+
+// NewTrackSplitter creates a splitter without any open tracks.
+func NewTrackSplitter() *TrackSplitter {
+	return &TrackSplitter{
+		open: make(map[string][]StoredData),
+	}
+}
+
+// Add adds a data point to the track of its plane. The data points must be
+// added in chronological order. The tracks which were completed are
+// returned sorted by the time of their first data point. Data points without
+// ICAO are ignored.
+func (s *TrackSplitter) Add(d StoredData) [][]StoredData {
+	if d.Data.Icao == nil {
+		return nil
+	}
+
+	var rv [][]StoredData
+	icao := *d.Data.Icao
+	if track, ok := s.open[icao]; ok {
+		last := track[len(track)-1]
+		if d.Time.Sub(last.Time) <= StatsTrackGap && !flightNumberChanged(last.Data, d.Data) {
+			s.open[icao] = append(track, d)
+			return nil
+		}
+		rv = append(rv, track)
+	}
+	s.open[icao] = []StoredData{d}
+
+	// Checking the other tracks after every data point would be wasteful
+	// as they can only be completed once StatsTrackGap elapses.
+	if d.Time.Sub(s.lastCheck) > StatsTrackGap {
+		s.lastCheck = d.Time
+		for icao, track := range s.open {
+			if d.Time.Sub(track[len(track)-1].Time) > StatsTrackGap {
+				rv = append(rv, track)
+				delete(s.open, icao)
+			}
+		}
+	}
+
+	sortTracks(rv)
+	return rv
+}
+
+// Flush returns all open tracks sorted by the time of their first data point
+// and resets the splitter.
+func (s *TrackSplitter) Flush() [][]StoredData {
+	var rv [][]StoredData
+	for _, track := range s.open {
+		rv = append(rv, track)
+	}
+	s.open = make(map[string][]StoredData)
+	sortTracks(rv)
+	return rv
+}
+
+func sortTracks(tracks [][]StoredData) {
+	sort.SliceStable(tracks, func(i, j int) bool {
+		a := tracks[i][0]
+		b := tracks[j][0]
+		if !a.Time.Equal(b.Time) {
+			return a.Time.Before(b.Time)
+		}
+		return *a.Data.Icao < *b.Data.Icao
+	})
+}
+
+// flightNumberChanged returns true if both data points contain a flight
+// number and the flight numbers differ.
+func flightNumberChanged(a, b Data) bool {
+	return a.FlightNumber != nil && b.FlightNumber != nil && *a.FlightNumber != *b.FlightNumber
+}
@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSplitTracks(t *testing.T) {
+	a := "aaaaaa"
+	b := "bbbbbb"
+	lot := "LOT3NV"
+	ryr := "RYR1"
+	data := []StoredData{
+		{Time: time.Unix(60, 0), Data: Data{Icao: &a, FlightNumber: &lot}},
+		{Time: time.Unix(0, 0), Data: Data{Icao: &a}},
+		{Time: time.Unix(30, 0), Data: Data{Icao: &b}},
+		{Time: time.Unix(120, 0), Data: Data{Icao: &a, FlightNumber: &ryr}},
+		{Time: time.Unix(120, 0).Add(StatsTrackGap + time.Second), Data: Data{Icao: &a, FlightNumber: &ryr}},
+		{Time: time.Unix(0, 0)},
+	}
+
+	tracks := SplitTracks(data)
+	expected := [][]int64{{0, 60}, {30}, {120}, {int64(121 + StatsTrackGap/time.Second)}}
+	if len(tracks) != len(expected) {
+		t.Fatalf("Wrong number of tracks %d", len(tracks))
+	}
+	for i, track := range tracks {
+		if len(track) != len(expected[i]) {
+			t.Fatalf("Wrong length of track %d: %d", i, len(track))
+		}
+		for j, d := range track {
+			if d.Time.Unix() != expected[i][j] {
+				t.Errorf("Wrong data point %d in track %d: %d", j, i, d.Time.Unix())
+			}
+		}
+	}
+}
+
+func TestTrackSplitter(t *testing.T) {
+	a := "aaaaaa"
+	b := "bbbbbb"
+	splitter := NewTrackSplitter()
+
+	if tracks := splitter.Add(StoredData{Time: time.Unix(0, 0), Data: Data{Icao: &a}}); len(tracks) != 0 {
+		t.Fatalf("No tracks should be completed %v", tracks)
+	}
+	if tracks := splitter.Add(StoredData{Time: time.Unix(60, 0), Data: Data{Icao: &a}}); len(tracks) != 0 {
+		t.Fatalf("No tracks should be completed %v", tracks)
+	}
+
+	tracks := splitter.Add(StoredData{Time: time.Unix(61, 0).Add(StatsTrackGap), Data: Data{Icao: &b}})
+	if len(tracks) != 1 || len(tracks[0]) != 2 {
+		t.Fatalf("Track of the first plane should be completed %v", tracks)
+	}
+
+	tracks = splitter.Flush()
+	if len(tracks) != 1 || *tracks[0][0].Data.Icao != b {
+		t.Fatalf("Track of the second plane should be flushed %v", tracks)
+	}
+	if tracks := splitter.Flush(); len(tracks) != 0 {
+		t.Fatalf("Splitter should be empty %v", tracks)
+	}
+}